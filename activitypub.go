@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/activitypub"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+)
+
+// Every local user is also an ActivityPub actor, identified by URLs under
+// /ap/. Remote actors can follow them, and their chirps are delivered to
+// those followers' servers as Notes as they are created and deleted.
+
+func (cfg *apiConfig) actorID(userID uuid.UUID) string {
+	return cfg.baseURL + "/ap/users/" + userID.String()
+}
+
+func (cfg *apiConfig) noteID(chirpID uuid.UUID) string {
+	return cfg.baseURL + "/ap/chirps/" + chirpID.String()
+}
+
+// actorKey returns the key userID's actor signs with, generating it the
+// first time it is needed.
+func (cfg *apiConfig) actorKey(ctx context.Context, userID uuid.UUID) (database.ActivitypubKey, error) {
+	key, err := cfg.dbQueries.GetActorKey(ctx, userID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return key, err
+	}
+
+	private, err := activitypub.GenerateKey()
+	if err != nil {
+		return database.ActivitypubKey{}, err
+	}
+	privatePem, err := activitypub.EncodePrivateKey(private)
+	if err != nil {
+		return database.ActivitypubKey{}, err
+	}
+	publicPem, err := activitypub.EncodePublicKey(&private.PublicKey)
+	if err != nil {
+		return database.ActivitypubKey{}, err
+	}
+	return cfg.dbQueries.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userID,
+		PublicKeyPem:  publicPem,
+		PrivateKeyPem: privatePem,
+	})
+}
+
+func (cfg *apiConfig) actorSigner(ctx context.Context, userID uuid.UUID) (activitypub.Signer, error) {
+	key, err := cfg.actorKey(ctx, userID)
+	if err != nil {
+		return activitypub.Signer{}, err
+	}
+	private, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return activitypub.Signer{}, err
+	}
+	return activitypub.Signer{KeyID: cfg.actorID(userID) + "#main-key", Key: private}, nil
+}
+
+// newNote describes a chirp as an ActivityPub Note addressed to everyone
+// and copied to its author's followers.
+func (cfg *apiConfig) newNote(chirp ChirpResponse) activitypub.Note {
+	actorID := cfg.actorID(chirp.UserID)
+	note := activitypub.Note{
+		ID:           cfg.noteID(chirp.ID),
+		Type:         "Note",
+		AttributedTo: actorID,
+		Content:      activitypub.Content(chirp.Body),
+		Published:    chirp.CreatedAt.UTC(),
+		URL:          cfg.baseURL + "/api/chirps/" + chirp.ID.String(),
+		To:           []string{activitypub.Public},
+		Cc:           []string{actorID + "/followers"},
+	}
+	if chirp.UpdatedAt.After(chirp.CreatedAt) {
+		updated := chirp.UpdatedAt.UTC()
+		note.Updated = &updated
+	}
+	if chirp.ReplyToID != nil {
+		note.InReplyTo = cfg.noteID(*chirp.ReplyToID)
+	}
+	for _, media := range chirp.Media {
+		note.Attachment = append(note.Attachment, activitypub.Attachment{Type: "Image", URL: media})
+	}
+	return note
+}
+
+// federateChirp delivers a Create or Delete of the chirp to the inboxes
+// of its author's remote followers. Deliveries are not retried: the
+// activity would be sent again to every server that already has it, and
+// one unreachable server would hold up the rest.
+func (cfg *apiConfig) federateChirp(ctx context.Context, event outbox.Event) error {
+	var userID uuid.UUID
+	var activity activitypub.Activity
+	var err error
+	switch event.Type {
+	case eventChirpCreated:
+		var chirp ChirpResponse
+		if err := json.Unmarshal(event.Payload, &chirp); err != nil {
+			return err
+		}
+		userID = chirp.UserID
+		note := cfg.newNote(chirp)
+		activity, err = activitypub.NewActivity(note.ID+"/activity", activitypub.TypeCreate, note.AttributedTo, note)
+		activity.To, activity.Cc = note.To, note.Cc
+	case eventChirpDeleted:
+		var data deletedChirpData
+		if err := json.Unmarshal(event.Payload, &data); err != nil {
+			return err
+		}
+		userID = data.UserID
+		id := cfg.noteID(data.ID)
+		activity, err = activitypub.NewActivity(id+"#delete", activitypub.TypeDelete, cfg.actorID(userID), activitypub.Tombstone{ID: id, Type: "Tombstone"})
+		activity.To = []string{activitypub.Public}
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	inboxes, err := cfg.dbQueries.GetRemoteFollowerInboxes(ctx, userID)
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+	signer, err := cfg.actorSigner(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := cfg.apClient.DeliverAll(ctx, inboxes, activity, signer); err != nil {
+		log.Printf("error federating %s: %v\n", activity.ID, err)
+	}
+	return nil
+}
+
+// federationStore keeps remote followers in the database.
+type federationStore struct {
+	q *database.Queries
+}
+
+func (s federationStore) AddFollower(ctx context.Context, userID uuid.UUID, follower activitypub.Follower) error {
+	return s.q.AddRemoteFollower(ctx, database.AddRemoteFollowerParams{
+		UserID:  userID,
+		ActorID: follower.ActorID,
+		Inbox:   follower.Inbox,
+	})
+}
+
+func (s federationStore) RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error {
+	_, err := s.q.RemoveRemoteFollower(ctx, database.RemoveRemoteFollowerParams{
+		UserID:  userID,
+		ActorID: actorID,
+	})
+	return err
+}
@@ -1,35 +1,131 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
 )
 
 type ChirpRequest struct {
-	Body string `json:"body"`
+	Body  string   `json:"body"`
+	Media []string `json:"media"`
+	// ReplyToID is only read when creating a chirp.
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
 }
 
 type ChirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Media     []string   `json:"media"`
+	ReplyToID *uuid.UUID `json:"reply_to_id"`
 }
 
-func validateAndCleanChirp(chirp string) (string, error) {
-	if len(chirp) > 140 {
-		log.Printf("bad request: chirp length > 140")
-		return "", errors.New("chirp is too long - max char: 140")
+func newChirpResponse(chirp database.Chirp) ChirpResponse {
+	media := chirp.MediaUrls
+	if media == nil {
+		media = []string{}
+	}
+	res := ChirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		Media:     media,
+	}
+	if chirp.ReplyToID.Valid {
+		res.ReplyToID = &chirp.ReplyToID.UUID
+	}
+	return res
+}
+
+func validateAndCleanChirp(chirp string, maxLength int) (string, error) {
+	if len(chirp) > maxLength {
+		log.Printf("bad request: chirp length > %d", maxLength)
+		return "", fmt.Errorf("chirp is too long - max char: %d", maxLength)
 	}
 
 	return filter(chirp), nil
 }
 
+// maxMediaURLLength caps each media URL, which is copied into every feed,
+// stream event, webhook and ActivityPub delivery of the chirp.
+const maxMediaURLLength = 2048
+
+// validateMedia checks media attachments, which are links to images
+// hosted elsewhere. The result is never nil, because a nil slice would be
+// stored as NULL.
+func validateMedia(media []string, maxMedia int) ([]string, error) {
+	if len(media) > maxMedia {
+		return nil, fmt.Errorf("too many media attachments - max: %d", maxMedia)
+	}
+	for _, raw := range media {
+		if len(raw) > maxMediaURLLength {
+			return nil, fmt.Errorf("media URLs are too long - max: %d bytes", maxMediaURLLength)
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, errors.New("media must be https URLs")
+		}
+	}
+	if media == nil {
+		media = []string{}
+	}
+	return media, nil
+}
+
+// hashtagPattern is what a hashtag may contain, both in chirp bodies and in
+// the /hashtags/{tag} feeds.
+var hashtagPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,50}$`)
+
+// bodyHashtagPattern finds the word after each # that starts a word. Words
+// are runs of ASCII letters, digits and underscores and of any non-ASCII
+// character, so "#café" is the word "café" rather than the tag "caf". Only
+// words matching hashtagPattern are tags. The 025_chirp_hashtags migration
+// indexed existing chirps with the same patterns.
+var bodyHashtagPattern = regexp.MustCompile(`(?:^|[\x00-\x22\x24-\x2F\x3A-\x40\x5B-\x5E\x60\x7B-\x7F])#([^\x00-\x2F\x3A-\x40\x5B-\x5E\x60\x7B-\x7F]+)`)
+
+// extractHashtags returns the distinct lowercased hashtags in a chirp body.
+func extractHashtags(body string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, match := range bodyHashtagPattern.FindAllStringSubmatch(body, -1) {
+		if !hashtagPattern.MatchString(match[1]) {
+			continue
+		}
+		tag := strings.ToLower(match[1])
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// setChirpHashtags replaces the hashtags a chirp is found under with those
+// in its body.
+func setChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	tags := extractHashtags(chirp.Body)
+	if len(tags) == 0 {
+		return nil
+	}
+	return q.AddChirpHashtags(ctx, database.AddChirpHashtagsParams{Tags: tags, ChirpID: chirp.ID})
+}
+
 func filter(profaneString string) string {
 	chirpWords := strings.Split(profaneString, " ")
 	for i, word := range chirpWords {
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+)
+
+// chirpStreamChannel is the Postgres channel that carries chirp events
+// between server instances.
+const chirpStreamChannel = "chirp_stream"
+
+// chirpStreamBuffer is how many events a slow client may fall behind by
+// before it is disconnected.
+const chirpStreamBuffer = 64
+
+// notifyChirpStream broadcasts a chirp event to every server instance,
+// each of which passes it to its own connected clients. Streams are best
+// effort: a failure is logged rather than returned, since retrying the
+// outbox event would run its other subscribers again.
+func (cfg *apiConfig) notifyChirpStream(ctx context.Context, event outbox.Event) error {
+	err := stream.Notify(ctx, cfg.dbQueries, chirpStreamChannel, stream.Event{
+		ID:     event.ID.String(),
+		Type:   event.Type,
+		UserID: event.UserID.UUID,
+		Data:   event.Payload,
+	})
+	if err != nil {
+		log.Printf("error streaming %s event %s: %v\n", event.Type, event.ID, err)
+	}
+	return nil
+}
+
+// runChirpStreamListener feeds chirp events notified by any instance into
+// this instance's hub.
+func (cfg *apiConfig) runChirpStreamListener(ctx context.Context, dbURL string) {
+	if err := stream.Listen(ctx, dbURL, chirpStreamChannel, cfg.chirpStream); err != nil {
+		log.Printf("error listening for chirp events: %v\n", err)
+	}
+}
+
+// handlerChirpStream sends chirp.created and chirp.deleted events as
+// Server-Sent Events. author_id narrows the stream as in handlerGetChirps.
+// A client that reconnects with Last-Event-ID is sent what it missed, or a
+// reset event if that is no longer known, after which it should reload
+// with GET /api/chirps.
+func (cfg *apiConfig) handlerChirpStream(w http.ResponseWriter, req *http.Request) {
+	var filter stream.Filter
+	if req.URL.Query().Has("author_id") {
+		authorID := req.URL.Query().Get("author_id")
+		if principal, ok := principalFromContext(req.Context()); ok && authorID == "me" {
+			authorID = principal.UserID.String()
+		}
+		queryUserID, err := uuid.Parse(authorID)
+		if err != nil {
+			log.Printf("invalid author_id: %v", err)
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter = func(event stream.Event) bool { return event.UserID == queryUserID }
+	}
+
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = req.URL.Query().Get("last_event_id")
+	}
+	sub, replay, resumed := cfg.chirpStream.Subscribe(lastEventID, filter)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", cfg.chirpStreamRetry.Milliseconds())
+	if !resumed {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeStreamEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("error flushing chirp stream: %v\n", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(cfg.chirpStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// resumes from its last event.
+				return
+			}
+			writeStreamEvent(w, event)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "#golang is fun", want: []string{"golang"}},
+		{body: "learning #Go and #go_lang, #GO again", want: []string{"go", "go_lang"}},
+		{body: "(#chirpy)", want: []string{"chirpy"}},
+		{body: "email a#b and ##double", want: nil},
+		{body: "#café au lait", want: nil},
+		{body: "é#tag and #tag!", want: []string{"tag"}},
+		{body: "#" + strings.Repeat("a", 50) + " #" + strings.Repeat("b", 51), want: []string{strings.Repeat("a", 50)}},
+		{body: "no tags here #", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			if got := extractHashtags(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("extractHashtags(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
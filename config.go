@@ -0,0 +1,201 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/entitlements"
+	"github.com/mu7ammad1951/chirpy/internal/lockout"
+	"github.com/mu7ammad1951/chirpy/internal/mailer"
+	"github.com/mu7ammad1951/chirpy/internal/oidc"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+	"github.com/mu7ammad1951/chirpy/internal/webhook"
+)
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return n
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return d
+}
+
+func envList(name string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func loadPasswordPolicy() auth.PasswordPolicy {
+	policy := auth.PasswordPolicy{
+		MinLength: envInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength: envInt("PASSWORD_MAX_LENGTH", 128),
+		Banned:    map[string]bool{},
+	}
+	for _, word := range envList("PASSWORD_BANNED") {
+		policy.Banned[strings.ToLower(word)] = true
+	}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			log.Fatalf("error loading breached passwords: %v", err)
+		}
+		policy.Breached = breached
+	}
+	return policy
+}
+
+// loadLoginLimiters builds the per-account and per-IP login throttles. IPs
+// get more free attempts than accounts since many users can share one
+// address.
+func loadLoginLimiters(db *database.Queries) (account, ip *lockout.Limiter) {
+	var store lockout.Store
+	switch os.Getenv("LOGIN_THROTTLE_STORE") {
+	case "", "memory":
+		store = lockout.NewMemoryStore()
+	case "postgres":
+		store = lockout.NewPostgresStore(db)
+	default:
+		log.Fatalf("invalid LOGIN_THROTTLE_STORE: %q", os.Getenv("LOGIN_THROTTLE_STORE"))
+	}
+
+	account = lockout.NewLimiter(store, lockout.Policy{
+		FreeAttempts: envInt("LOGIN_ACCOUNT_FREE_ATTEMPTS", 5),
+		BaseDelay:    envDuration("LOGIN_BASE_DELAY", time.Second),
+		MaxDelay:     envDuration("LOGIN_MAX_LOCKOUT", 15*time.Minute),
+		Window:       envDuration("LOGIN_FAILURE_WINDOW", 24*time.Hour),
+	})
+	ip = lockout.NewLimiter(store, lockout.Policy{
+		FreeAttempts: envInt("LOGIN_IP_FREE_ATTEMPTS", 20),
+		BaseDelay:    envDuration("LOGIN_BASE_DELAY", time.Second),
+		MaxDelay:     envDuration("LOGIN_MAX_LOCKOUT", 15*time.Minute),
+		Window:       envDuration("LOGIN_FAILURE_WINDOW", 24*time.Hour),
+	})
+	return account, ip
+}
+
+func loadMailer() mailer.Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "", "log":
+		return mailer.LogMailer{}
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return &mailer.FileMailer{Dir: dir, From: from}
+	case "smtp":
+		return &mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	default:
+		log.Fatalf("invalid MAILER: %q", os.Getenv("MAILER"))
+		return nil
+	}
+}
+
+// loadOIDCProvider returns nil when OIDC_ISSUER is unset, which disables
+// external login.
+func loadOIDCProvider(baseURL string) *oidc.Provider {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	if clientID == "" {
+		log.Fatal("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+	return oidc.NewProvider(oidc.Config{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  baseURL + "/api/login/oidc/callback",
+	})
+}
+
+// loadPolkaVerifier reads the Polka webhook secrets. POLKA_KEY is accepted
+// as a single secret for deployments that predate signed webhooks.
+func loadPolkaVerifier() *webhook.Verifier {
+	secrets := envList("POLKA_WEBHOOK_SECRETS")
+	if len(secrets) == 0 && os.Getenv("POLKA_KEY") != "" {
+		secrets = []string{os.Getenv("POLKA_KEY")}
+	}
+	if len(secrets) == 0 {
+		log.Printf("POLKA_WEBHOOK_SECRETS is not set; Polka webhooks will be rejected")
+	}
+	return &webhook.Verifier{
+		Secrets:   secrets,
+		Tolerance: envDuration("POLKA_WEBHOOK_TOLERANCE", 5*time.Minute),
+	}
+}
+
+// loadEntitlements reads plan entitlements from ENTITLEMENTS_FILE, falling
+// back to the built-in plans.
+func loadEntitlements() entitlements.Plans {
+	path := os.Getenv("ENTITLEMENTS_FILE")
+	if path == "" {
+		return entitlements.Defaults()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("error opening entitlements file: %v", err)
+	}
+	defer f.Close()
+	plans, err := entitlements.Load(f)
+	if err != nil {
+		log.Fatalf("invalid entitlements file: %v", err)
+	}
+	return plans
+}
+
+func loadWebhookRetryPolicy() webhook.RetryPolicy {
+	return webhook.RetryPolicy{
+		MaxAttempts: envInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseDelay:   envDuration("WEBHOOK_RETRY_BASE_DELAY", 30*time.Second),
+		MaxDelay:    envDuration("WEBHOOK_RETRY_MAX_DELAY", 6*time.Hour),
+	}
+}
+
+func loadOutboxPolicy() outbox.Policy {
+	return outbox.Policy{
+		MaxAttempts: envInt("OUTBOX_MAX_ATTEMPTS", 10),
+		BaseDelay:   envDuration("OUTBOX_RETRY_BASE_DELAY", 10*time.Second),
+		MaxDelay:    envDuration("OUTBOX_RETRY_MAX_DELAY", 10*time.Minute),
+		Lease:       5 * time.Minute,
+	}
+}
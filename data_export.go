@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/export"
+)
+
+// wakeDataExporter asks the exporter to look for pending jobs now rather
+// than at its next tick.
+func (cfg *apiConfig) wakeDataExporter() {
+	select {
+	case cfg.exportWake <- struct{}{}:
+	default:
+	}
+}
+
+// runDataExporter builds pending export archives and deletes expired ones.
+// Jobs are claimed with FOR UPDATE SKIP LOCKED, so several instances can
+// run it against the same database as long as they share exportDir.
+func (cfg *apiConfig) runDataExporter(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.processDataExports(ctx)
+		cfg.expireDataExports(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.exportWake:
+		}
+	}
+}
+
+func (cfg *apiConfig) processDataExports(ctx context.Context) {
+	for {
+		job, err := cfg.dbQueries.ClaimPendingDataExport(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			log.Printf("error claiming data export: %v\n", err)
+			return
+		}
+
+		path, err := cfg.buildDataExport(ctx, job)
+		if err != nil {
+			log.Printf("error building data export %s: %v\n", job.ID, err)
+			err = cfg.dbQueries.FailDataExport(ctx, database.FailDataExportParams{
+				ID:    job.ID,
+				Error: sql.NullString{String: "could not build the export", Valid: true},
+			})
+			if err != nil {
+				log.Printf("error marking data export failed: %v\n", err)
+			}
+			continue
+		}
+
+		completed, err := cfg.dbQueries.CompleteDataExport(ctx, database.CompleteDataExportParams{
+			ID:       job.ID,
+			FilePath: sql.NullString{String: path, Valid: true},
+		})
+		if err != nil {
+			log.Printf("error completing data export: %v\n", err)
+		} else if completed == 0 {
+			// The account was purged while the archive was being built.
+			if err := removeExportFile(path); err != nil {
+				log.Printf("error removing data export %s: %v\n", job.ID, err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) buildDataExport(ctx context.Context, job database.DataExport) (string, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	chirps, err := cfg.dbQueries.GetChirpsByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	sessions, err := cfg.dbQueries.GetRefreshTokensByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	var sub *database.Subscription
+	stored, err := cfg.dbQueries.GetSubscription(ctx, job.UserID)
+	if err == nil {
+		sub = &stored
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	subEvents, err := cfg.dbQueries.GetSubscriptionEvents(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	conversations, err := cfg.dbQueries.GetConversationsByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	messages, err := cfg.dbQueries.GetMessagesByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	following, err := cfg.dbQueries.GetFollowsByFollowerID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	followers, err := cfg.dbQueries.GetFollowsByFolloweeID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	blocks, err := cfg.dbQueries.GetUserBlocksByBlockerID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	likes, err := cfg.dbQueries.GetChirpLikesByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	notifications, err := cfg.dbQueries.GetNotificationsByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	accessTokens, err := cfg.dbQueries.GetPersonalAccessTokensByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	oauthGrants, err := cfg.dbQueries.GetOAuthGrantsByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+	identities, err := cfg.dbQueries.GetOIDCIdentitiesByUserID(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(cfg.exportDir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(cfg.exportDir, job.ID.String()+".zip")
+	tmp, err := os.CreateTemp(cfg.exportDir, job.ID.String()+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = export.Write(tmp, export.Data{
+		User:               user,
+		Chirps:             chirps,
+		Sessions:           sessions,
+		Subscription:       sub,
+		SubscriptionEvents: subEvents,
+		Conversations:      conversations,
+		Messages:           messages,
+		Following:          following,
+		Followers:          followers,
+		Blocks:             blocks,
+		Likes:              likes,
+		Notifications:      notifications,
+		AccessTokens:       accessTokens,
+		OAuthGrants:        oauthGrants,
+		Identities:         identities,
+	})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+func (cfg *apiConfig) expireDataExports(ctx context.Context) {
+	cutoff := time.Now().UTC().Add(-cfg.exportRetention)
+	expired, err := cfg.dbQueries.GetExpiredDataExports(ctx, sql.NullTime{Time: cutoff, Valid: true})
+	if err != nil {
+		log.Printf("error listing expired data exports: %v\n", err)
+		return
+	}
+	for _, job := range expired {
+		if job.FilePath.Valid {
+			if err := removeExportFile(job.FilePath.String); err != nil {
+				log.Printf("error removing data export %s: %v\n", job.ID, err)
+				continue
+			}
+		}
+		if err := cfg.dbQueries.ExpireDataExport(ctx, job.ID); err != nil {
+			log.Printf("error expiring data export %s: %v\n", job.ID, err)
+		}
+	}
+}
+
+// removeExportFile deletes an export archive, treating one that is already
+// gone as removed.
+func removeExportFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func dataExportDownloadPath(id uuid.UUID) string {
+	return "/api/exports/" + id.String() + "/download"
+}
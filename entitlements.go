@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/entitlements"
+)
+
+// entitlementsFor returns what the user's plan allows. Users without a
+// live subscription get the free plan.
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	sub, err := cfg.dbQueries.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.entitlements.For(entitlements.Free), nil
+	}
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	if !toSubscription(sub).Active(time.Now().UTC()) {
+		return cfg.entitlements.For(entitlements.Free), nil
+	}
+	return cfg.entitlements.For(sub.Plan), nil
+}
+
+// subscriptionActive reports whether the user's subscription grants its
+// plan's benefits now.
+func (cfg *apiConfig) subscriptionActive(ctx context.Context, userID uuid.UUID) (bool, error) {
+	sub, err := cfg.dbQueries.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return toSubscription(sub).Active(time.Now().UTC()), nil
+}
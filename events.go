@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+)
+
+// Domain events written to the outbox.
+const (
+	eventChirpCreated   = "chirp.created"
+	eventChirpUpdated   = "chirp.updated"
+	eventChirpDeleted   = "chirp.deleted"
+	eventChirpLiked     = "chirp.liked"
+	eventUserFollowed   = "user.followed"
+	eventUserUpgraded   = "user.upgraded"
+	eventUserDowngraded = "user.downgraded"
+	eventMessageCreated = "message.created"
+	eventMessageDeleted = "message.deleted"
+)
+
+type userEventData struct {
+	UserID uuid.UUID `json:"user_id"`
+	Plan   string    `json:"plan"`
+}
+
+type deletedChirpData struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type likedChirpData struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+}
+
+type followData struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+// withTx runs fn against queries bound to a single transaction, committing
+// if fn succeeds. Events published inside fn are dispatched once the
+// transaction commits.
+func (cfg *apiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(cfg.dbQueries.WithTx(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	cfg.wakeOutboxDispatcher()
+	return nil
+}
+
+// publishEvent records an event about userID in the outbox. q must be
+// bound to the transaction making the change the event describes.
+func publishEvent(ctx context.Context, q *database.Queries, eventType string, userID uuid.UUID, data any) error {
+	return outbox.Enqueue(ctx, q, eventType, uuid.NullUUID{UUID: userID, Valid: true}, data)
+}
+
+// subscribeOutbox registers the in-process consumers of domain events.
+func (cfg *apiConfig) subscribeOutbox() {
+	for eventType := range outboundEventTypes {
+		cfg.outbox.Subscribe(eventType, cfg.queueWebhookDeliveries)
+	}
+	cfg.outbox.Subscribe(eventChirpCreated, cfg.notifyChirpStream)
+	cfg.outbox.Subscribe(eventChirpDeleted, cfg.notifyChirpStream)
+	cfg.outbox.Subscribe(eventUserUpgraded, cfg.notifyUserStream)
+	cfg.outbox.Subscribe(eventUserDowngraded, cfg.notifyUserStream)
+	cfg.outbox.Subscribe(eventMessageCreated, cfg.notifyUserStream)
+	cfg.outbox.Subscribe(eventMessageDeleted, cfg.notifyUserStream)
+	cfg.outbox.Subscribe(eventChirpCreated, cfg.createNotifications)
+	cfg.outbox.Subscribe(eventChirpLiked, cfg.createNotifications)
+	cfg.outbox.Subscribe(eventUserFollowed, cfg.createNotifications)
+	cfg.outbox.Subscribe(eventChirpCreated, cfg.federateChirp)
+	cfg.outbox.Subscribe(eventChirpDeleted, cfg.federateChirp)
+}
+
+// wakeOutboxDispatcher asks the dispatcher to hand out new events now
+// rather than at its next tick.
+func (cfg *apiConfig) wakeOutboxDispatcher() {
+	select {
+	case cfg.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// runOutboxDispatcher hands stored events to their subscribers and clears
+// out dispatched events once they are older than retention.
+func (cfg *apiConfig) runOutboxDispatcher(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := cfg.outbox.DispatchPending(ctx); err != nil {
+			log.Printf("error dispatching outbox events: %v\n", err)
+		}
+		cutoff := sql.NullTime{Time: time.Now().UTC().Add(-retention), Valid: true}
+		if _, err := cfg.dbQueries.PurgeDispatchedOutboxEvents(ctx, cutoff); err != nil {
+			log.Printf("error purging outbox events: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.outboxWake:
+		}
+	}
+}
+
+// queueWebhookDeliveries fans an event out to every webhook subscription
+// that wants it. The envelope reuses the outbox event's id, so receivers
+// can recognise an event queued twice after a retry.
+func (cfg *apiConfig) queueWebhookDeliveries(ctx context.Context, event outbox.Event) error {
+	payload, err := json.Marshal(outboundEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt.UTC(),
+		Data:      event.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	queued, err := cfg.dbQueries.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: event.Type,
+		Payload:   payload,
+		UserID:    event.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	if queued > 0 {
+		cfg.wakeWebhookDispatcher()
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// handlerDeleteMe schedules the caller's account for deletion. The account
+// is signed out everywhere and its chirps are hidden straight away, but
+// nothing is removed until the grace period has passed; logging in again
+// before then cancels the deletion.
+func (cfg *apiConfig) handlerDeleteMe(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Password string `json:"password"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+	if err := cfg.checkCurrentPassword(req.Context(), user, reqJSON.Password); err != nil {
+		respondWithPasswordError(w, err)
+		return
+	}
+
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		if err := q.RequestUserDeletion(req.Context(), user.ID); err != nil {
+			return err
+		}
+		return revokeUserCredentials(req.Context(), q, user.ID)
+	})
+	if err != nil {
+		log.Printf("error scheduling deletion: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, struct {
+		DeleteAfter time.Time `json:"delete_after"`
+	}{
+		DeleteAfter: time.Now().UTC().Add(cfg.deletionGracePeriod),
+	})
+}
+
+// runAccountPurger hard-deletes accounts whose grace period has passed,
+// checking every interval until ctx is cancelled. Chirps and tokens go with
+// them through ON DELETE CASCADE; data export archives are removed from disk
+// first.
+func (cfg *apiConfig) runAccountPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().UTC().Add(-cfg.deletionGracePeriod)
+		files, err := cfg.dbQueries.GetDataExportFilesOfDeletedUsers(ctx, sql.NullTime{Time: cutoff, Valid: true})
+		if err != nil {
+			log.Printf("error listing data exports of deleted accounts: %v\n", err)
+		}
+		for _, file := range files {
+			if err := removeExportFile(file.String); err != nil {
+				log.Printf("error removing data export %s: %v\n", file.String, err)
+			}
+		}
+		purged, err := cfg.dbQueries.PurgeDeletedUsers(ctx, sql.NullTime{Time: cutoff, Valid: true})
+		if err != nil {
+			log.Printf("error purging deleted accounts: %v\n", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted accounts\n", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
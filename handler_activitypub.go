@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/activitypub"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/notification"
+)
+
+const (
+	// maxInboxBytes caps the size of activities posted to an inbox.
+	maxInboxBytes = 1 << 20
+	// outboxSize is how many of the newest chirps an outbox lists.
+	outboxSize = 20
+)
+
+type webFingerLink struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href"`
+}
+
+type webFingerResponse struct {
+	Subject string          `json:"subject"`
+	Aliases []string        `json:"aliases"`
+	Links   []webFingerLink `json:"links"`
+}
+
+// handlerWebFinger resolves acct:name@host, where name is a username or a
+// user id, to the user's actor.
+func (cfg *apiConfig) handlerWebFinger(w http.ResponseWriter, req *http.Request) {
+	account, ok := strings.CutPrefix(req.URL.Query().Get("resource"), "acct:")
+	name, host, found := strings.Cut(account, "@")
+	if !ok || !found || name == "" {
+		respondWithError(w, http.StatusBadRequest, "resource must be acct:user@host")
+		return
+	}
+	base, err := url.Parse(cfg.baseURL)
+	if err != nil || !strings.EqualFold(host, base.Host) {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	userID, err := uuid.Parse(name)
+	if err != nil {
+		if !notification.ValidUsername(name) {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		ids, err := cfg.dbQueries.GetUserIDsByUsernames(req.Context(), []string{strings.ToLower(name)})
+		if err != nil {
+			log.Printf("error retrieving user: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		if len(ids) == 0 {
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		userID = ids[0]
+	}
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil || user.DeletionRequestedAt.Valid {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	actorID := cfg.actorID(user.ID)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	respondWithDocument(w, http.StatusOK, "application/jrd+json", webFingerResponse{
+		Subject: "acct:" + name + "@" + base.Host,
+		Aliases: []string{actorID},
+		Links: []webFingerLink{
+			{Rel: "self", Type: activitypub.ContentType, Href: actorID},
+		},
+	})
+}
+
+// handlerGetActor serves a user's actor document.
+func (cfg *apiConfig) handlerGetActor(w http.ResponseWriter, req *http.Request) {
+	user, ok := cfg.federatedUser(w, req)
+	if !ok {
+		return
+	}
+	key, err := cfg.actorKey(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("error retrieving actor key: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	actorID := cfg.actorID(user.ID)
+	actor := activitypub.Actor{
+		Context:           activitypub.Context,
+		ID:                actorID,
+		Type:              "Person",
+		PreferredUsername: user.ID.String(),
+		Inbox:             actorID + "/inbox",
+		Outbox:            actorID + "/outbox",
+		Followers:         actorID + "/followers",
+		PublicKey: activitypub.PublicKey{
+			ID:           actorID + "#main-key",
+			Owner:        actorID,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	}
+	if user.Username.Valid {
+		actor.PreferredUsername = user.Username.String
+	}
+	if user.DisplayName.Valid {
+		actor.Name = user.DisplayName.String
+	}
+	if user.Bio.Valid {
+		actor.Summary = activitypub.Content(user.Bio.String)
+	}
+	respondWithDocument(w, http.StatusOK, activitypub.ContentType, actor)
+}
+
+// handlerGetOutbox lists Creates of a user's newest chirps.
+func (cfg *apiConfig) handlerGetOutbox(w http.ResponseWriter, req *http.Request) {
+	user, ok := cfg.federatedUser(w, req)
+	if !ok {
+		return
+	}
+	chirps, err := cfg.dbQueries.GetChirpsByUserID(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("error fetching chirps: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
+	})
+
+	outbox := activitypub.OrderedCollection{
+		Context:    activitypub.Context,
+		ID:         cfg.actorID(user.ID) + "/outbox",
+		Type:       "OrderedCollection",
+		TotalItems: len(chirps),
+	}
+	for _, chirp := range chirps[:min(len(chirps), outboxSize)] {
+		note := cfg.newNote(newChirpResponse(chirp))
+		create, err := activitypub.NewActivity(note.ID+"/activity", activitypub.TypeCreate, note.AttributedTo, note)
+		if err != nil {
+			log.Printf("error building activity: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		create.Context = nil
+		create.To, create.Cc = note.To, note.Cc
+		outbox.OrderedItems = append(outbox.OrderedItems, create)
+	}
+	respondWithDocument(w, http.StatusOK, activitypub.ContentType, outbox)
+}
+
+// handlerGetFollowers gives the number of a user's remote followers
+// without listing them.
+func (cfg *apiConfig) handlerGetFollowers(w http.ResponseWriter, req *http.Request) {
+	user, ok := cfg.federatedUser(w, req)
+	if !ok {
+		return
+	}
+	count, err := cfg.dbQueries.CountRemoteFollowers(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("error counting followers: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	respondWithDocument(w, http.StatusOK, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:    activitypub.Context,
+		ID:         cfg.actorID(user.ID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: int(count),
+	})
+}
+
+// handlerPostInbox receives an activity from another server. It must be
+// signed by its actor; Follows are accepted at once and Undos of them
+// honoured, and anything else is acknowledged and dropped.
+func (cfg *apiConfig) handlerPostInbox(w http.ResponseWriter, req *http.Request) {
+	user, ok := cfg.federatedUser(w, req)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxInboxBytes))
+	if err != nil {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "activity too large")
+		return
+	}
+	signer, err := cfg.actorSigner(req.Context(), user.ID)
+	if err != nil {
+		log.Printf("error retrieving actor key: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	err = cfg.apInbox.Receive(req.Context(), req, body, activitypub.Local{
+		UserID:  user.ID,
+		ActorID: cfg.actorID(user.ID),
+		Signer:  signer,
+	})
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, activitypub.ErrInvalidActivity):
+		respondWithError(w, http.StatusBadRequest, "invalid activity")
+	case errors.Is(err, activitypub.ErrMissingSignature),
+		errors.Is(err, activitypub.ErrInvalidSignature),
+		errors.Is(err, activitypub.ErrDateOutOfRange),
+		errors.Is(err, activitypub.ErrDigestMismatch),
+		errors.Is(err, activitypub.ErrActorMismatch),
+		errors.Is(err, activitypub.ErrUnknownActor):
+		log.Printf("error verifying activity: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid signature")
+	default:
+		log.Printf("error receiving activity: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+	}
+}
+
+// handlerGetNote serves a chirp as a Note.
+func (cfg *apiConfig) handlerGetNote(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpID)
+	if err != nil {
+		log.Printf("error retrieving chirp: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	note := cfg.newNote(newChirpResponse(chirp))
+	note.Context = activitypub.Context
+	respondWithDocument(w, http.StatusOK, activitypub.ContentType, note)
+}
+
+// federatedUser loads the user named in the path, responding with 404 if
+// they don't exist or are awaiting deletion.
+func (cfg *apiConfig) federatedUser(w http.ResponseWriter, req *http.Request) (database.User, bool) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return database.User{}, false
+	}
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil || user.DeletionRequestedAt.Valid {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// respondWithDocument is respondWithJSON for the JSON media types of
+// WebFinger and ActivityPub.
+func respondWithDocument(w http.ResponseWriter, status int, contentType string, v any) {
+	res, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("error marshalling response: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(res)
+}
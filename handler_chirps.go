@@ -2,12 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"sort"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/mu7ammad1951/chirpy/internal/auth"
 	"github.com/mu7ammad1951/chirpy/internal/database"
 )
 
@@ -16,7 +17,12 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Request)
 	var responseData []database.Chirp
 	var err error
 	if req.URL.Query().Has("author_id") {
-		queryUserID, err := uuid.Parse(req.URL.Query().Get("author_id"))
+		authorID := req.URL.Query().Get("author_id")
+		// author_id=me lists the caller's own chirps.
+		if principal, ok := principalFromContext(req.Context()); ok && authorID == "me" {
+			authorID = principal.UserID.String()
+		}
+		queryUserID, err := uuid.Parse(authorID)
 		if err != nil {
 			log.Printf("invalid author_id: %v", err)
 			respondWithError(w, http.StatusBadRequest, err.Error())
@@ -39,13 +45,7 @@ func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, req *http.Request)
 	var formattedResponseData []ChirpResponse
 
 	for _, chirpResponse := range responseData {
-		formattedResponseData = append(formattedResponseData, ChirpResponse{
-			ID:        chirpResponse.ID,
-			CreatedAt: chirpResponse.CreatedAt,
-			UpdatedAt: chirpResponse.UpdatedAt,
-			Body:      chirpResponse.Body,
-			UserID:    chirpResponse.UserID,
-		})
+		formattedResponseData = append(formattedResponseData, newChirpResponse(chirpResponse))
 	}
 
 	switch req.URL.Query().Get("sort") {
@@ -75,13 +75,7 @@ func (cfg *apiConfig) handlerGetChirpByID(w http.ResponseWriter, req *http.Reque
 		respondWithError(w, http.StatusNotFound, "chirp not found")
 		return
 	}
-	respondWithJSON(w, http.StatusOK, ChirpResponse{
-		ID:        responseData.ID,
-		CreatedAt: responseData.CreatedAt,
-		UpdatedAt: responseData.UpdatedAt,
-		Body:      responseData.Body,
-		UserID:    responseData.UserID,
-	})
+	respondWithJSON(w, http.StatusOK, newChirpResponse(responseData))
 
 }
 
@@ -96,29 +90,79 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	cleanedChirp, err := validateAndCleanChirp(chirpData.Body)
+	userID := requestPrincipal(req).UserID
+
+	ent, err := cfg.entitlementsFor(req.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
+		log.Printf("error retrieving entitlements: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
 		return
 	}
 
-	tokenString, err := auth.GetBearerToken(req.Header)
+	if ent.ChirpsPerHour > 0 {
+		recent, err := cfg.dbQueries.CountChirpsByUserSince(req.Context(), database.CountChirpsByUserSinceParams{
+			UserID:    userID,
+			CreatedAt: time.Now().UTC().Add(-time.Hour),
+		})
+		if err != nil {
+			log.Printf("error counting chirps: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		if recent >= int64(ent.ChirpsPerHour) {
+			respondWithError(w, http.StatusTooManyRequests, fmt.Sprintf("chirp limit reached - max per hour: %d", ent.ChirpsPerHour))
+			return
+		}
+	}
+
+	cleanedChirp, err := validateAndCleanChirp(chirpData.Body, ent.MaxChirpLength)
 	if err != nil {
-		log.Printf("error getting bearer token: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "permission denied")
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	userID, err := auth.ValidateJWT(tokenString, cfg.secretString)
+	media, err := validateMedia(chirpData.Media, ent.MaxMediaPerChirp)
 	if err != nil {
-		log.Printf("error validating token: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "permission denied")
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if chirpData.ReplyToID.Valid {
+		parent, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpData.ReplyToID.UUID)
+		if err != nil {
+			log.Printf("error retrieving chirp: %v\n", err)
+			respondWithError(w, http.StatusBadRequest, "reply_to_id does not match a chirp")
+			return
+		}
+		blocked, err := cfg.dbQueries.HasBlockWithAny(req.Context(), database.HasBlockWithAnyParams{
+			UserID:   userID,
+			OtherIds: []uuid.UUID{parent.UserID},
+		})
+		if err != nil {
+			log.Printf("error checking blocks: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		if blocked {
+			respondWithError(w, http.StatusForbidden, "you cannot reply to this chirp")
+			return
+		}
+	}
 
-	res, err := cfg.dbQueries.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   cleanedChirp,
-		UserID: userID,
+	var chirp ChirpResponse
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		res, err := q.CreateChirp(req.Context(), database.CreateChirpParams{
+			Body:      cleanedChirp,
+			UserID:    userID,
+			MediaUrls: media,
+			ReplyToID: chirpData.ReplyToID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := setChirpHashtags(req.Context(), q, res); err != nil {
+			return err
+		}
+		chirp = newChirpResponse(res)
+		return publishEvent(req.Context(), q, eventChirpCreated, userID, chirp)
 	})
 	if err != nil {
 		log.Printf("error creating chirp: %v\n", err)
@@ -126,17 +170,13 @@ func (cfg *apiConfig) handlerCreateChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, ChirpResponse{
-		ID:        res.ID,
-		CreatedAt: res.CreatedAt,
-		UpdatedAt: res.UpdatedAt,
-		Body:      res.Body,
-		UserID:    res.UserID,
-	})
+	respondWithJSON(w, http.StatusCreated, chirp)
 
 }
 
-func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Request) {
+// handlerUpdateChirp replaces the body and media of one of the caller's
+// chirps, if their plan allows editing.
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, req *http.Request) {
 	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
 		log.Printf("error parsing chirpID: %v\n", err)
@@ -144,20 +184,82 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	tokenString, err := auth.GetBearerToken(req.Header)
+	var chirpData ChirpRequest
+	if err := json.NewDecoder(req.Body).Decode(&chirpData); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID := requestPrincipal(req).UserID
+
+	chirpInfo, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpID)
+	if err != nil {
+		log.Printf("error retrieving chirp: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "could not find chirp")
+		return
+	}
+	if chirpInfo.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "you are not authorized to edit this chirp")
+		return
+	}
+
+	ent, err := cfg.entitlementsFor(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving entitlements: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if !ent.EditChirps {
+		respondWithError(w, http.StatusForbidden, "your plan does not include editing chirps")
+		return
+	}
+
+	cleanedChirp, err := validateAndCleanChirp(chirpData.Body, ent.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	media, err := validateMedia(chirpData.Media, ent.MaxMediaPerChirp)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var chirp ChirpResponse
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		res, err := q.UpdateChirp(req.Context(), database.UpdateChirpParams{
+			ID:        chirpID,
+			Body:      cleanedChirp,
+			MediaUrls: media,
+		})
+		if err != nil {
+			return err
+		}
+		if err := setChirpHashtags(req.Context(), q, res); err != nil {
+			return err
+		}
+		chirp = newChirpResponse(res)
+		return publishEvent(req.Context(), q, eventChirpUpdated, userID, chirp)
+	})
 	if err != nil {
-		log.Printf("error getting bearer token: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "permission denied")
+		log.Printf("error updating chirp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
 		return
 	}
+	respondWithJSON(w, http.StatusOK, chirp)
+}
 
-	userID, err := auth.ValidateJWT(tokenString, cfg.secretString)
+func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
 	if err != nil {
-		log.Printf("error validating token: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "permission denied")
+		log.Printf("error parsing chirpID: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
 		return
 	}
 
+	userID := requestPrincipal(req).UserID
+
 	chirpInfo, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpID)
 	if err != nil {
 		log.Printf("error retrieving chirp: %v\n", err)
@@ -171,7 +273,12 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	err = cfg.dbQueries.DeleteChirpByID(req.Context(), chirpID)
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		if err := q.DeleteChirpByID(req.Context(), chirpID); err != nil {
+			return err
+		}
+		return publishEvent(req.Context(), q, eventChirpDeleted, userID, deletedChirpData{ID: chirpID, UserID: userID})
+	})
 	if err != nil {
 		log.Printf("chirp not found: %v", err)
 		respondWithError(w, http.StatusNotFound, "chirp does not exist")
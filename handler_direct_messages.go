@@ -0,0 +1,649 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+const (
+	// maxConversationParticipants includes the user starting the
+	// conversation.
+	maxConversationParticipants = 10
+	maxMessageLength            = 1000
+	defaultMessageLimit         = 50
+	maxMessageLimit             = 100
+)
+
+// Participant statuses. A conversation started by someone the user doesn't
+// follow is a message request until the user accepts it or replies. A
+// declined request keeps the user's row so that it cannot be sent again,
+// but the user is otherwise treated as having left.
+const (
+	participantAccepted  = "accepted"
+	participantRequested = "requested"
+	participantDeclined  = "declined"
+)
+
+var (
+	errBlocked      = errors.New("blocked")
+	errNoRecipients = errors.New("no other participants")
+)
+
+type ConversationResponse struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	IsGroup        bool        `json:"is_group"`
+	ParticipantIDs []uuid.UUID `json:"participant_ids"`
+	Status         string      `json:"status"`
+	LastMessageAt  time.Time   `json:"last_message_at"`
+	UnreadCount    int64       `json:"unread_count"`
+}
+
+type MessageResponse struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	Deleted        bool      `json:"deleted"`
+}
+
+func newMessageResponse(msg database.Message) MessageResponse {
+	return MessageResponse{
+		ID:             msg.ID,
+		CreatedAt:      msg.CreatedAt,
+		ConversationID: msg.ConversationID,
+		SenderID:       msg.SenderID,
+		Body:           msg.Body,
+		Deleted:        msg.DeletedAt.Valid,
+	}
+}
+
+type deletedMessageData struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+// validateMessage applies the same profanity filter as chirps.
+func validateMessage(body string) (string, error) {
+	if strings.TrimSpace(body) == "" {
+		return "", errors.New("message body is required")
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		return "", fmt.Errorf("message is too long - max char: %d", maxMessageLength)
+	}
+	return filter(body), nil
+}
+
+// conversationParticipant loads the caller's membership of the
+// conversation in the path, responding with an error and returning false
+// if they are not in it or declined it.
+func (cfg *apiConfig) conversationParticipant(w http.ResponseWriter, req *http.Request) (database.ConversationParticipant, bool) {
+	conversationID, err := uuid.Parse(req.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid conversation id")
+		return database.ConversationParticipant{}, false
+	}
+	participant, err := cfg.dbQueries.GetConversationParticipant(req.Context(), database.GetConversationParticipantParams{
+		ConversationID: conversationID,
+		UserID:         requestPrincipal(req).UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && participant.Status == participantDeclined) {
+		respondWithError(w, http.StatusNotFound, "conversation not found")
+		return database.ConversationParticipant{}, false
+	}
+	if err != nil {
+		log.Printf("error retrieving conversation participant: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return database.ConversationParticipant{}, false
+	}
+	return participant, true
+}
+
+// otherParticipants lists everyone in a conversation except userID and
+// those who declined it.
+func otherParticipants(ctx context.Context, q *database.Queries, conversationID, userID uuid.UUID) ([]uuid.UUID, error) {
+	participants, err := q.GetConversationParticipants(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	var others []uuid.UUID
+	for _, p := range participants {
+		if p.UserID != userID && p.Status != participantDeclined {
+			others = append(others, p.UserID)
+		}
+	}
+	return others, nil
+}
+
+// sendMessage stores a message and tells the other participants about it.
+// Replying to a message request accepts it.
+func sendMessage(ctx context.Context, q *database.Queries, sender database.ConversationParticipant, others []uuid.UUID, body string) (database.Message, error) {
+	if sender.Status == participantRequested {
+		if _, err := q.AcceptConversation(ctx, database.AcceptConversationParams{
+			ConversationID: sender.ConversationID,
+			UserID:         sender.UserID,
+		}); err != nil {
+			return database.Message{}, err
+		}
+	}
+	msg, err := q.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: sender.ConversationID,
+		SenderID:       sender.UserID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	if err := q.TouchConversation(ctx, database.TouchConversationParams{
+		ID:            sender.ConversationID,
+		LastMessageAt: msg.CreatedAt,
+	}); err != nil {
+		return database.Message{}, err
+	}
+	for _, recipient := range others {
+		if err := publishEvent(ctx, q, eventMessageCreated, recipient, newMessageResponse(msg)); err != nil {
+			return database.Message{}, err
+		}
+	}
+	return msg, nil
+}
+
+// handlerCreateConversation starts a conversation with one or more other
+// users, optionally with a first message. Starting a one-to-one
+// conversation that already exists returns the existing one.
+func (cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, req *http.Request) {
+	var reqJSON struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+		Body           string      `json:"body"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	me := requestPrincipal(req).UserID
+	var others []uuid.UUID
+	seen := map[uuid.UUID]bool{me: true}
+	for _, id := range reqJSON.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+
+	var fieldErrors []FieldError
+	if len(others) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "participant_ids", Rule: "required", Message: "at least one other user is required"})
+	}
+	if len(others) > maxConversationParticipants-1 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "participant_ids", Rule: "max", Message: fmt.Sprintf("a conversation can have at most %d participants", maxConversationParticipants)})
+	}
+	body := ""
+	if reqJSON.Body != "" {
+		cleaned, err := validateMessage(reqJSON.Body)
+		if err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: "body", Rule: "message", Message: err.Error()})
+		}
+		body = cleaned
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "invalid conversation", fieldErrors)
+		return
+	}
+
+	for _, id := range others {
+		if _, err := cfg.dbQueries.GetUserByID(req.Context(), id); err != nil {
+			log.Printf("error retrieving user: %v\n", err)
+			respondWithError(w, http.StatusNotFound, "user not found")
+			return
+		}
+	}
+	blocked, err := cfg.dbQueries.HasBlockWithAny(req.Context(), database.HasBlockWithAnyParams{UserID: me, OtherIds: others})
+	if err != nil {
+		log.Printf("error checking blocks: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "you cannot message one of these users")
+		return
+	}
+	if len(others) == 1 {
+		declined, err := cfg.dbQueries.HasDeclinedDirectConversation(req.Context(), database.HasDeclinedDirectConversationParams{
+			UserID:  uuid.NullUUID{UUID: me, Valid: true},
+			OtherID: others[0],
+		})
+		if err != nil {
+			log.Printf("error checking declined requests: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		if declined {
+			respondWithError(w, http.StatusForbidden, "you cannot message one of these users")
+			return
+		}
+	}
+
+	status := http.StatusCreated
+	var res ConversationResponse
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		var conversation database.Conversation
+		var err error
+		if len(others) == 1 {
+			conversation, err = q.GetDirectConversation(req.Context(), database.GetDirectConversationParams{UserID: me, OtherID: others[0]})
+			if err == nil {
+				status = http.StatusOK
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+
+		if status == http.StatusCreated {
+			conversation, err = q.CreateConversation(req.Context(), database.CreateConversationParams{
+				CreatedBy: uuid.NullUUID{UUID: me, Valid: true},
+				IsGroup:   len(others) > 1,
+			})
+			if err != nil {
+				return err
+			}
+			if err := q.AddConversationParticipant(req.Context(), database.AddConversationParticipantParams{
+				ConversationID: conversation.ID,
+				UserID:         me,
+				Status:         participantAccepted,
+			}); err != nil {
+				return err
+			}
+			for _, id := range others {
+				follows, err := q.IsFollowing(req.Context(), database.IsFollowingParams{FollowerID: id, FolloweeID: me})
+				if err != nil {
+					return err
+				}
+				participantStatus := participantRequested
+				if follows {
+					participantStatus = participantAccepted
+				}
+				if err := q.AddConversationParticipant(req.Context(), database.AddConversationParticipantParams{
+					ConversationID: conversation.ID,
+					UserID:         id,
+					Status:         participantStatus,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		sender, err := q.GetConversationParticipant(req.Context(), database.GetConversationParticipantParams{
+			ConversationID: conversation.ID,
+			UserID:         me,
+		})
+		if err != nil {
+			return err
+		}
+		// Starting a conversation the caller once declined takes the
+		// decline back.
+		if sender.Status == participantDeclined {
+			if _, err := q.AcceptConversation(req.Context(), database.AcceptConversationParams{
+				ConversationID: sender.ConversationID,
+				UserID:         sender.UserID,
+			}); err != nil {
+				return err
+			}
+			sender.Status = participantAccepted
+		}
+		if body != "" {
+			msg, err := sendMessage(req.Context(), q, sender, others, body)
+			if err != nil {
+				return err
+			}
+			conversation.LastMessageAt = msg.CreatedAt
+			sender.Status = participantAccepted
+		}
+
+		res = ConversationResponse{
+			ID:             conversation.ID,
+			CreatedAt:      conversation.CreatedAt,
+			IsGroup:        conversation.IsGroup,
+			ParticipantIDs: append([]uuid.UUID{me}, others...),
+			Status:         sender.Status,
+			LastMessageAt:  conversation.LastMessageAt,
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("error creating conversation: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	respondWithJSON(w, status, res)
+}
+
+// handlerGetConversations lists the caller's conversations, most recently
+// active first. ?requests=true lists message requests instead.
+func (cfg *apiConfig) handlerGetConversations(w http.ResponseWriter, req *http.Request) {
+	status := participantAccepted
+	if req.URL.Query().Get("requests") == "true" {
+		status = participantRequested
+	}
+	rows, err := cfg.dbQueries.GetConversationsForUser(req.Context(), database.GetConversationsForUserParams{
+		UserID: requestPrincipal(req).UserID,
+		Status: status,
+	})
+	if err != nil {
+		log.Printf("error retrieving conversations: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []ConversationResponse{}
+	for _, row := range rows {
+		res = append(res, ConversationResponse{
+			ID:             row.ID,
+			CreatedAt:      row.CreatedAt,
+			IsGroup:        row.IsGroup,
+			ParticipantIDs: row.ParticipantIds,
+			Status:         row.Status,
+			LastMessageAt:  row.LastMessageAt,
+			UnreadCount:    row.UnreadCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerAcceptConversation accepts a message request.
+func (cfg *apiConfig) handlerAcceptConversation(w http.ResponseWriter, req *http.Request) {
+	participant, ok := cfg.conversationParticipant(w, req)
+	if !ok {
+		return
+	}
+	if participant.Status != participantRequested {
+		respondWithError(w, http.StatusConflict, "conversation is not a message request")
+		return
+	}
+	_, err := cfg.dbQueries.AcceptConversation(req.Context(), database.AcceptConversationParams{
+		ConversationID: participant.ConversationID,
+		UserID:         participant.UserID,
+	})
+	if err != nil {
+		log.Printf("error accepting conversation: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLeaveConversation removes the caller from a conversation, or
+// declines it if it is a message request.
+func (cfg *apiConfig) handlerLeaveConversation(w http.ResponseWriter, req *http.Request) {
+	participant, ok := cfg.conversationParticipant(w, req)
+	if !ok {
+		return
+	}
+	var err error
+	if participant.Status == participantRequested {
+		_, err = cfg.dbQueries.DeclineConversation(req.Context(), database.DeclineConversationParams{
+			ConversationID: participant.ConversationID,
+			UserID:         participant.UserID,
+		})
+	} else {
+		_, err = cfg.dbQueries.LeaveConversation(req.Context(), database.LeaveConversationParams{
+			ConversationID: participant.ConversationID,
+			UserID:         participant.UserID,
+		})
+	}
+	if err != nil {
+		log.Printf("error leaving conversation: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerSendMessage(w http.ResponseWriter, req *http.Request) {
+	participant, ok := cfg.conversationParticipant(w, req)
+	if !ok {
+		return
+	}
+
+	var reqJSON struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	body, err := validateMessage(reqJSON.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var msg database.Message
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		others, err := otherParticipants(req.Context(), q, participant.ConversationID, participant.UserID)
+		if err != nil {
+			return err
+		}
+		if len(others) == 0 {
+			return errNoRecipients
+		}
+		blocked, err := q.HasBlockWithAny(req.Context(), database.HasBlockWithAnyParams{UserID: participant.UserID, OtherIds: others})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errBlocked
+		}
+		msg, err = sendMessage(req.Context(), q, participant, others, body)
+		return err
+	})
+	if errors.Is(err, errBlocked) {
+		respondWithError(w, http.StatusForbidden, "you cannot message one of these users")
+		return
+	}
+	if errors.Is(err, errNoRecipients) {
+		respondWithError(w, http.StatusConflict, "no one else is in this conversation")
+		return
+	}
+	if err != nil {
+		log.Printf("error sending message: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, newMessageResponse(msg))
+}
+
+// handlerGetMessages pages through a conversation newest first. Pass the
+// id of the last message of a page as ?before= to get the next one.
+func (cfg *apiConfig) handlerGetMessages(w http.ResponseWriter, req *http.Request) {
+	participant, ok := cfg.conversationParticipant(w, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	limit := defaultMessageLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxMessageLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxMessageLimit))
+			return
+		}
+		limit = n
+	}
+	var before uuid.NullUUID
+	if raw := query.Get("before"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid before id")
+			return
+		}
+		before = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	messages, err := cfg.dbQueries.GetMessages(req.Context(), database.GetMessagesParams{
+		ConversationID: participant.ConversationID,
+		UserID:         participant.UserID,
+		BeforeID:       before,
+		MaxResults:     int32(limit),
+	})
+	if err != nil {
+		log.Printf("error retrieving messages: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []MessageResponse{}
+	for _, msg := range messages {
+		res = append(res, newMessageResponse(msg))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerMarkConversationRead marks messages as read up to and including
+// message_id, or up to the newest message if it is left out.
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, req *http.Request) {
+	participant, ok := cfg.conversationParticipant(w, req)
+	if !ok {
+		return
+	}
+
+	var reqJSON struct {
+		MessageID uuid.UUID `json:"message_id"`
+	}
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&reqJSON); err != nil {
+			log.Printf("error decoding request: %v\n", err)
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	var readUpTo database.Message
+	if reqJSON.MessageID != uuid.Nil {
+		msg, err := cfg.dbQueries.GetMessage(req.Context(), database.GetMessageParams{
+			ID:             reqJSON.MessageID,
+			ConversationID: participant.ConversationID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "message not found")
+			return
+		}
+		if err != nil {
+			log.Printf("error retrieving message: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		readUpTo = msg
+	} else {
+		latest, err := cfg.dbQueries.GetMessages(req.Context(), database.GetMessagesParams{
+			ConversationID: participant.ConversationID,
+			UserID:         participant.UserID,
+			MaxResults:     1,
+		})
+		if err != nil {
+			log.Printf("error retrieving messages: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		if len(latest) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		readUpTo = latest[0]
+	}
+
+	err := cfg.dbQueries.MarkConversationRead(req.Context(), database.MarkConversationReadParams{
+		ReadAt:         readUpTo.CreatedAt,
+		ConversationID: participant.ConversationID,
+		UserID:         participant.UserID,
+	})
+	if err != nil {
+		log.Printf("error marking conversation read: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDeleteMessage hides a message from the caller, or with
+// ?for=everyone replaces it with a tombstone for every participant. Only
+// the sender can delete a message for everyone.
+func (cfg *apiConfig) handlerDeleteMessage(w http.ResponseWriter, req *http.Request) {
+	participant, ok := cfg.conversationParticipant(w, req)
+	if !ok {
+		return
+	}
+	messageID, err := uuid.Parse(req.PathValue("messageID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid message id")
+		return
+	}
+	msg, err := cfg.dbQueries.GetMessage(req.Context(), database.GetMessageParams{
+		ID:             messageID,
+		ConversationID: participant.ConversationID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	if err != nil {
+		log.Printf("error retrieving message: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	switch req.URL.Query().Get("for") {
+	case "", "me":
+		err = cfg.dbQueries.DeleteMessageForUser(req.Context(), database.DeleteMessageForUserParams{
+			MessageID: msg.ID,
+			UserID:    participant.UserID,
+		})
+	case "everyone":
+		if msg.SenderID != participant.UserID {
+			respondWithError(w, http.StatusForbidden, "only the sender can delete a message for everyone")
+			return
+		}
+		err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+			deleted, err := q.DeleteMessageForEveryone(req.Context(), database.DeleteMessageForEveryoneParams{
+				ID:       msg.ID,
+				SenderID: participant.UserID,
+			})
+			if err != nil || deleted == 0 {
+				return err
+			}
+			others, err := otherParticipants(req.Context(), q, participant.ConversationID, participant.UserID)
+			if err != nil {
+				return err
+			}
+			for _, recipient := range others {
+				data := deletedMessageData{ID: msg.ID, ConversationID: msg.ConversationID}
+				if err := publishEvent(req.Context(), q, eventMessageDeleted, recipient, data); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		respondWithError(w, http.StatusBadRequest, "for must be me or everyone")
+		return
+	}
+	if err != nil {
+		log.Printf("error deleting message: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
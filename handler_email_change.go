@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/mailer"
+)
+
+// handlerEmailChangeRequest records the new address as pending. Nothing
+// changes until the link sent to the new address is opened, and the old
+// address gets a link to cancel the change.
+func (cfg *apiConfig) handlerEmailChangeRequest(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+	if err := cfg.checkCurrentPassword(req.Context(), user, reqJSON.Password); err != nil {
+		respondWithPasswordError(w, err)
+		return
+	}
+	if reqJSON.Email == "" || reqJSON.Email == user.Email {
+		respondWithError(w, http.StatusBadRequest, "a new email address is required")
+		return
+	}
+	if _, err := cfg.dbQueries.GetUserByEmail(req.Context(), reqJSON.Email); err == nil {
+		respondWithError(w, http.StatusConflict, "email is already in use")
+		return
+	}
+
+	// Links from an earlier request must not confirm this new address.
+	if err := cfg.revokeEmailChangeTokens(req.Context(), user.ID); err != nil {
+		log.Printf("error revoking email change tokens: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	err = cfg.dbQueries.SetPendingEmail(req.Context(), database.SetPendingEmailParams{
+		ID:           user.ID,
+		PendingEmail: sql.NullString{String: reqJSON.Email, Valid: true},
+	})
+	if err != nil {
+		log.Printf("error storing pending email: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	confirmToken, err := cfg.issueOneTimeToken(req.Context(), user.ID, auth.PurposeChangeEmail, changeEmailTokenTTL)
+	if err != nil {
+		log.Printf("error issuing email change token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	cancelToken, err := cfg.issueOneTimeToken(req.Context(), user.ID, auth.PurposeCancelEmailChange, changeEmailTokenTTL)
+	if err != nil {
+		log.Printf("error issuing email change token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	err = cfg.mailer.Send(req.Context(), mailer.Message{
+		To:      reqJSON.Email,
+		Subject: "Confirm your new Chirpy email address",
+		Body: fmt.Sprintf("Open the link below to use this address for your Chirpy account:\n\n%s\n\nThe link expires in 24 hours.\n",
+			cfg.linkURL("/app/confirm-email-change", confirmToken)),
+	})
+	if err != nil {
+		log.Printf("error sending email change confirmation: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "could not send confirmation email")
+		return
+	}
+	err = cfg.mailer.Send(req.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy email address is being changed",
+		Body: fmt.Sprintf("Someone asked to change the email address of your Chirpy account to %s.\n\nIf this was not you, cancel the change and reset your password:\n\n%s\n",
+			reqJSON.Email, cfg.linkURL("/app/cancel-email-change", cancelToken)),
+	})
+	if err != nil {
+		log.Printf("error sending email change notice: %v\n", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlerEmailChangeConfirm(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Token string `json:"token"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID, err := cfg.redeemOneTimeToken(req.Context(), reqJSON.Token, auth.PurposeChangeEmail)
+	if errors.Is(err, errInvalidOneTimeToken) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("error redeeming email change token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	user, err := cfg.dbQueries.ApplyPendingEmail(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "no email change is pending")
+		return
+	}
+	if err != nil {
+		log.Printf("error applying pending email: %v\n", err)
+		respondWithError(w, http.StatusConflict, "could not change email, it may already be in use")
+		return
+	}
+	if err := cfg.revokeEmailChangeTokens(req.Context(), user.ID); err != nil {
+		log.Printf("error revoking email change tokens: %v\n", err)
+	}
+	// Verification and password reset links sent to the old address must
+	// not be usable against the account once it has moved.
+	for _, purpose := range []string{auth.PurposeVerifyEmail, auth.PurposeResetPassword} {
+		err = cfg.dbQueries.RevokeOneTimeTokens(req.Context(), database.RevokeOneTimeTokensParams{
+			UserID:  user.ID,
+			Purpose: purpose,
+		})
+		if err != nil {
+			log.Printf("error revoking %s tokens: %v\n", purpose, err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// handlerEmailChangeCancel is reached from the notice sent to the old
+// address. It needs no access token because the session may be the one
+// that was compromised.
+func (cfg *apiConfig) handlerEmailChangeCancel(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Token string `json:"token"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID, err := cfg.redeemOneTimeToken(req.Context(), reqJSON.Token, auth.PurposeCancelEmailChange)
+	if errors.Is(err, errInvalidOneTimeToken) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("error redeeming cancel token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	err = cfg.dbQueries.SetPendingEmail(req.Context(), database.SetPendingEmailParams{ID: userID})
+	if err != nil {
+		log.Printf("error clearing pending email: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if err := cfg.revokeEmailChangeTokens(req.Context(), userID); err != nil {
+		log.Printf("error revoking email change tokens: %v\n", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) revokeEmailChangeTokens(ctx context.Context, userID uuid.UUID) error {
+	for _, purpose := range []string{auth.PurposeChangeEmail, auth.PurposeCancelEmailChange} {
+		err := cfg.dbQueries.RevokeOneTimeTokens(ctx, database.RevokeOneTimeTokensParams{
+			UserID:  userID,
+			Purpose: purpose,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+const exportLinkTTL = 15 * time.Minute
+
+type DataExportResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	Error             string     `json:"error,omitempty"`
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+func (cfg *apiConfig) newDataExportResponse(job database.DataExport) DataExportResponse {
+	res := DataExportResponse{
+		ID:        job.ID,
+		Status:    job.Status,
+		CreatedAt: job.CreatedAt,
+		Error:     job.Error.String,
+	}
+	if job.CompletedAt.Valid {
+		res.CompletedAt = &job.CompletedAt.Time
+	}
+	if job.Status == "completed" {
+		expires := time.Now().UTC().Add(exportLinkTTL)
+		res.DownloadURL = cfg.baseURL + auth.SignURL(dataExportDownloadPath(job.ID), expires, cfg.secretString)
+		res.DownloadExpiresAt = &expires
+	}
+	return res
+}
+
+func (cfg *apiConfig) handlerCreateExport(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	// Only one export per user is built at a time.
+	job, err := cfg.dbQueries.GetActiveDataExport(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		job, err = cfg.dbQueries.CreateDataExport(req.Context(), userID)
+	}
+	if err != nil {
+		log.Printf("error creating data export: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	cfg.wakeDataExporter()
+
+	w.Header().Set("Location", "/api/users/me/export/"+job.ID.String())
+	respondWithJSON(w, http.StatusAccepted, cfg.newDataExportResponse(job))
+}
+
+func (cfg *apiConfig) handlerGetExport(w http.ResponseWriter, req *http.Request) {
+	exportID, err := uuid.Parse(req.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid export id")
+		return
+	}
+	userID := requestPrincipal(req).UserID
+
+	job, err := cfg.dbQueries.GetDataExport(req.Context(), exportID)
+	if err != nil || job.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "export not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, cfg.newDataExportResponse(job))
+}
+
+// handlerDownloadExport serves a finished archive. It is authorized by the
+// signed, expiring link from handlerGetExport instead of a bearer token so
+// that the link can be opened directly in a browser.
+func (cfg *apiConfig) handlerDownloadExport(w http.ResponseWriter, req *http.Request) {
+	exportID, err := uuid.Parse(req.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid export id")
+		return
+	}
+	if err := auth.VerifySignedURL(dataExportDownloadPath(exportID), req.URL.Query(), cfg.secretString, time.Now()); err != nil {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	job, err := cfg.dbQueries.GetDataExport(req.Context(), exportID)
+	if err != nil || job.Status != "completed" || !job.FilePath.Valid {
+		respondWithError(w, http.StatusNotFound, "export not found")
+		return
+	}
+	f, err := os.Open(job.FilePath.String)
+	if err != nil {
+		log.Printf("error opening data export: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "export not found")
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export-`+job.ID.String()+`.zip"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, req, "", job.CompletedAt.Time, f)
+}
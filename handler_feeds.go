@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/feed"
+)
+
+// feedSize is how many of the newest chirps a feed lists.
+const feedSize = 50
+
+// handlerUserFeed serves a user's newest chirps as Atom or RSS, depending
+// on whether the path ends in .atom or .rss.
+func (cfg *apiConfig) handlerUserFeed(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil || user.DeletionRequestedAt.Valid {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	chirps, err := cfg.dbQueries.GetNewestChirpsByUserID(req.Context(), database.GetNewestChirpsByUserIDParams{
+		UserID: userID,
+		Limit:  feedSize,
+	})
+	if err != nil {
+		log.Printf("error fetching chirps: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	name := publicName(user)
+	f := feed.Feed{
+		ID:      "urn:uuid:" + user.ID.String(),
+		Title:   "Chirps by " + name,
+		Link:    cfg.baseURL + "/api/chirps?author_id=" + user.ID.String(),
+		Self:    cfg.baseURL + req.URL.Path,
+		Author:  name,
+		Created: user.CreatedAt,
+	}
+	if user.Bio.Valid {
+		f.Subtitle = user.Bio.String
+	}
+	for _, chirp := range chirps {
+		f.Entries = append(f.Entries, cfg.feedEntry(chirp, ""))
+	}
+	serveFeed(w, req, f)
+}
+
+// handlerHashtagFeed serves the newest chirps containing #tag as Atom or
+// RSS.
+func (cfg *apiConfig) handlerHashtagFeed(w http.ResponseWriter, req *http.Request) {
+	tag := req.PathValue("tag")
+	if !hashtagPattern.MatchString(tag) {
+		respondWithError(w, http.StatusBadRequest, "hashtags are 1 to 50 ASCII letters, digits and underscores")
+		return
+	}
+	tag = strings.ToLower(tag)
+
+	chirps, err := cfg.dbQueries.GetChirpsByHashtag(req.Context(), database.GetChirpsByHashtagParams{
+		Tag:        tag,
+		MaxResults: feedSize,
+	})
+	if err != nil {
+		log.Printf("error fetching chirps: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	f := feed.Feed{
+		ID:    cfg.baseURL + "/hashtags/" + tag,
+		Title: "Chirps tagged #" + tag,
+		Link:  cfg.baseURL + req.URL.Path,
+		Self:  cfg.baseURL + req.URL.Path,
+		// An empty feed has never changed.
+		Created: time.Unix(0, 0),
+	}
+	names := map[uuid.UUID]string{}
+	for _, chirp := range chirps {
+		name, ok := names[chirp.UserID]
+		if !ok {
+			user, err := cfg.dbQueries.GetUserByID(req.Context(), chirp.UserID)
+			if err != nil {
+				log.Printf("error retrieving user: %v\n", err)
+				respondWithError(w, http.StatusInternalServerError, "")
+				return
+			}
+			name = publicName(user)
+			names[chirp.UserID] = name
+		}
+		f.Entries = append(f.Entries, cfg.feedEntry(chirp, name))
+	}
+	serveFeed(w, req, f)
+}
+
+func (cfg *apiConfig) feedEntry(chirp database.Chirp, author string) feed.Entry {
+	return feed.Entry{
+		ID:        "urn:uuid:" + chirp.ID.String(),
+		Link:      cfg.baseURL + "/api/chirps/" + chirp.ID.String(),
+		Author:    author,
+		Published: chirp.CreatedAt,
+		Updated:   chirp.UpdatedAt,
+		Text:      chirp.Body,
+		Images:    chirp.MediaUrls,
+	}
+}
+
+// serveFeed renders f in the format named by the path's extension. The
+// ETag is a hash of the document, so it also changes when a chirp is
+// deleted, which Last-Modified can't show.
+func serveFeed(w http.ResponseWriter, req *http.Request, f feed.Feed) {
+	var body []byte
+	var err error
+	if strings.HasSuffix(req.URL.Path, ".rss") {
+		body, err = f.RSS()
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	} else {
+		body, err = f.Atom()
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	}
+	if err != nil {
+		log.Printf("error rendering feed: %v\n", err)
+		w.Header().Del("Content-Type")
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, req, "", f.LastUpdated(), bytes.NewReader(body))
+}
+
+// publicName is how a user is shown to others: their username, else their
+// display name.
+func publicName(user database.User) string {
+	if user.Username.Valid {
+		return "@" + user.Username.String
+	}
+	if user.DisplayName.Valid {
+		return user.DisplayName.String
+	}
+	return "Chirpy user"
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// otherUser parses the user named in the path, responding with an error
+// and returning false unless it is an existing user other than the caller.
+func (cfg *apiConfig) otherUser(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return uuid.Nil, false
+	}
+	if userID == requestPrincipal(req).UserID {
+		respondWithError(w, http.StatusBadRequest, "you cannot do that to yourself")
+		return uuid.Nil, false
+	}
+	if _, err := cfg.dbQueries.GetUserByID(req.Context(), userID); err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.otherUser(w, req)
+	if !ok {
+		return
+	}
+	me := requestPrincipal(req).UserID
+
+	blocked, err := cfg.dbQueries.HasBlockWithAny(req.Context(), database.HasBlockWithAnyParams{
+		UserID:   me,
+		OtherIds: []uuid.UUID{userID},
+	})
+	if err != nil {
+		log.Printf("error checking blocks: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "you cannot follow this user")
+		return
+	}
+
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		added, err := q.FollowUser(req.Context(), database.FollowUserParams{
+			FollowerID: me,
+			FolloweeID: userID,
+		})
+		if err != nil || added == 0 {
+			return err
+		}
+		return publishEvent(req.Context(), q, eventUserFollowed, me, followData{FollowerID: me, FolloweeID: userID})
+	})
+	if err != nil {
+		log.Printf("error following user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	removed, err := cfg.dbQueries.UnfollowUser(req.Context(), database.UnfollowUserParams{
+		FollowerID: requestPrincipal(req).UserID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		log.Printf("error unfollowing user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "you do not follow this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerBlock blocks a user, which also ends any follow between the two
+// of them.
+func (cfg *apiConfig) handlerBlock(w http.ResponseWriter, req *http.Request) {
+	userID, ok := cfg.otherUser(w, req)
+	if !ok {
+		return
+	}
+	me := requestPrincipal(req).UserID
+
+	err := cfg.withTx(req.Context(), func(q *database.Queries) error {
+		if err := q.BlockUser(req.Context(), database.BlockUserParams{BlockerID: me, BlockedID: userID}); err != nil {
+			return err
+		}
+		if _, err := q.UnfollowUser(req.Context(), database.UnfollowUserParams{FollowerID: me, FolloweeID: userID}); err != nil {
+			return err
+		}
+		_, err := q.UnfollowUser(req.Context(), database.UnfollowUserParams{FollowerID: userID, FolloweeID: me})
+		return err
+	})
+	if err != nil {
+		log.Printf("error blocking user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblock(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	removed, err := cfg.dbQueries.UnblockUser(req.Context(), database.UnblockUserParams{
+		BlockerID: requestPrincipal(req).UserID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		log.Printf("error unblocking user: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "you have not blocked this user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
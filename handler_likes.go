@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+	chirp, err := cfg.dbQueries.GetChirpByID(req.Context(), chirpID)
+	if err != nil {
+		log.Printf("error retrieving chirp: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	me := requestPrincipal(req).UserID
+
+	blocked, err := cfg.dbQueries.HasBlockWithAny(req.Context(), database.HasBlockWithAnyParams{
+		UserID:   me,
+		OtherIds: []uuid.UUID{chirp.UserID},
+	})
+	if err != nil {
+		log.Printf("error checking blocks: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "you cannot like this chirp")
+		return
+	}
+
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		added, err := q.LikeChirp(req.Context(), database.LikeChirpParams{UserID: me, ChirpID: chirpID})
+		if err != nil || added == 0 {
+			return err
+		}
+		return publishEvent(req.Context(), q, eventChirpLiked, me, likedChirpData{
+			ChirpID:  chirpID,
+			UserID:   me,
+			AuthorID: chirp.UserID,
+		})
+	})
+	if err != nil {
+		log.Printf("error liking chirp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+	removed, err := cfg.dbQueries.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+		UserID:  requestPrincipal(req).UserID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		log.Printf("error unliking chirp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "you have not liked this chirp")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+func accountLockoutKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipLockoutKey keys on the connecting address. Deployments behind a proxy
+// see the proxy's address here and should rely on the account limiter.
+func ipLockoutKey(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// loginRetryAfter returns the longer of the account and IP lockouts. Store
+// errors fail open so an outage of the throttle store cannot block logins.
+func (cfg *apiConfig) loginRetryAfter(ctx context.Context, accountKey, ipKey string) time.Duration {
+	accountRetry, err := cfg.accountLimiter.RetryAfter(ctx, accountKey)
+	if err != nil {
+		log.Printf("error checking account lockout: %v", err)
+	}
+	ipRetry, err := cfg.ipLimiter.RetryAfter(ctx, ipKey)
+	if err != nil {
+		log.Printf("error checking ip lockout: %v", err)
+	}
+	return max(accountRetry, ipRetry)
+}
+
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, accountKey, ipKey string) {
+	if _, err := cfg.accountLimiter.Fail(ctx, accountKey); err != nil {
+		log.Printf("error recording account login failure: %v", err)
+	}
+	if _, err := cfg.ipLimiter.Fail(ctx, ipKey); err != nil {
+		log.Printf("error recording ip login failure: %v", err)
+	}
+}
+
+var errIncorrectPassword = errors.New("incorrect password")
+
+type passwordLockedError struct {
+	retryAfter time.Duration
+}
+
+func (e passwordLockedError) Error() string {
+	return "too many incorrect passwords"
+}
+
+// checkCurrentPassword confirms the password of a signed-in user before a
+// sensitive change. Failures count against a per-user lockout so a stolen
+// access token cannot be used to guess the password.
+func (cfg *apiConfig) checkCurrentPassword(ctx context.Context, user database.User, password string) error {
+	lockoutKey := "password:" + user.ID.String()
+	retryAfter, err := cfg.accountLimiter.RetryAfter(ctx, lockoutKey)
+	if err != nil {
+		log.Printf("error checking password lockout: %v", err)
+	}
+	if retryAfter > 0 {
+		return passwordLockedError{retryAfter: retryAfter}
+	}
+
+	if err := auth.CheckPasswordHash(password, user.HashedPassword); err != nil {
+		if _, err := cfg.accountLimiter.Fail(ctx, lockoutKey); err != nil {
+			log.Printf("error recording password failure: %v", err)
+		}
+		return errIncorrectPassword
+	}
+
+	if err := cfg.accountLimiter.Reset(ctx, lockoutKey); err != nil {
+		log.Printf("error resetting password failures: %v", err)
+	}
+	return nil
+}
+
+func respondWithPasswordError(w http.ResponseWriter, err error) {
+	var locked passwordLockedError
+	if errors.As(err, &locked) {
+		setRetryAfter(w, locked.retryAfter)
+		respondWithError(w, http.StatusTooManyRequests, "too many incorrect passwords, try again later")
+		return
+	}
+	respondWithError(w, http.StatusForbidden, "incorrect password")
+}
+
+func respondWithRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	respondWithError(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+}
+
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+func (cfg *apiConfig) handlerUnlock(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if reqJSON.Email == "" && reqJSON.IP == "" {
+		respondWithError(w, http.StatusBadRequest, "email or ip is required")
+		return
+	}
+
+	if reqJSON.Email != "" {
+		if err := cfg.accountLimiter.Reset(req.Context(), accountLockoutKey(reqJSON.Email)); err != nil {
+			log.Printf("error unlocking account: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+	}
+	if reqJSON.IP != "" {
+		if err := cfg.ipLimiter.Reset(req.Context(), "ip:"+reqJSON.IP); err != nil {
+			log.Printf("error unlocking ip: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/mu7ammad1951/chirpy/internal/auth"
 	"github.com/mu7ammad1951/chirpy/internal/database"
 )
@@ -20,9 +22,17 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	accountKey := accountLockoutKey(reqJSON.Email)
+	ipKey := ipLockoutKey(req)
+	if retryAfter := cfg.loginRetryAfter(req.Context(), accountKey, ipKey); retryAfter > 0 {
+		respondWithRetryAfter(w, retryAfter)
+		return
+	}
+
 	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), reqJSON.Email)
 	if err != nil {
 		log.Printf("error retrieving user")
+		cfg.recordLoginFailure(req.Context(), accountKey, ipKey)
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password")
 		return
 	}
@@ -30,10 +40,58 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 	err = auth.CheckPasswordHash(reqJSON.Password, user.HashedPassword)
 	if err != nil {
 		log.Printf("wrong password")
+		cfg.recordLoginFailure(req.Context(), accountKey, ipKey)
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password")
 		return
 	}
 
+	if err := cfg.accountLimiter.Reset(req.Context(), accountKey); err != nil {
+		log.Printf("error resetting login failures: %v", err)
+	}
+
+	if auth.NeedsRehash(user.HashedPassword) {
+		cfg.rehashPassword(req.Context(), user.ID, reqJSON.Password)
+	}
+
+	if user.TotpEnabled {
+		cfg.respondWithMFAChallenge(w, user)
+		return
+	}
+
+	cfg.respondWithSession(w, req, user)
+}
+
+// respondWithMFAChallenge is sent instead of a session when the first
+// factor was correct but the user has two-factor authentication enabled.
+func (cfg *apiConfig) respondWithMFAChallenge(w http.ResponseWriter, user database.User) {
+	mfaToken, err := auth.MakeMFAToken(user.ID, cfg.secretString)
+	if err != nil {
+		log.Printf("error creating MFA token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+	})
+}
+
+// respondWithSession issues a new access and refresh token pair for a user
+// who has completed every authentication step. Logging in cancels a pending
+// account deletion.
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, req *http.Request, user database.User) {
+	if user.DeletionRequestedAt.Valid {
+		if err := cfg.dbQueries.CancelUserDeletion(req.Context(), user.ID); err != nil {
+			log.Printf("error cancelling account deletion: %v", err)
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("account deletion cancelled by login")
+	}
+
 	tokenString, err := auth.MakeJWT(user.ID, cfg.secretString)
 	if err != nil {
 		log.Printf("error creating JWT: %v", err)
@@ -63,15 +121,26 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		Token        string `json:"token"`
 		RefreshToken string `json:"refresh_token"`
 	}{
-		UserResponse: UserResponse{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		UserResponse: newUserResponse(user),
 		Token:        tokenString,
 		RefreshToken: refreshTokenString,
 	})
+}
 
+// rehashPassword upgrades a stored hash to the current scheme. Failures are
+// only logged: the user already proved their password and the old hash
+// remains valid.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, userID uuid.UUID, password string) {
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("error rehashing password: %v", err)
+		return
+	}
+	err = cfg.dbQueries.UpdatePasswordHash(ctx, database.UpdatePasswordHashParams{
+		ID:             userID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		log.Printf("error storing rehashed password: %v", err)
+	}
 }
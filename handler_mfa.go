@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+const totpIssuer = "Chirpy"
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+func (cfg *apiConfig) handlerTOTPEnroll(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+	if user.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		log.Printf("error generating totp secret: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	err = cfg.dbQueries.SetTOTPSecret(req.Context(), database.SetTOTPSecretParams{
+		ID:         user.ID,
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+	})
+	if err != nil {
+		log.Printf("error storing totp secret: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) handlerTOTPConfirm(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Code string `json:"code"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+	if user.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "two-factor enrollment has not been started")
+		return
+	}
+
+	step, err := auth.ValidateTOTP(user.TotpSecret.String, reqJSON.Code, time.Now(), 0)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid code")
+		return
+	}
+
+	recoveryCodes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		log.Printf("error generating recovery codes: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if err := cfg.dbQueries.DeleteRecoveryCodes(req.Context(), user.ID); err != nil {
+		log.Printf("error deleting recovery codes: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	for _, code := range recoveryCodes {
+		err := cfg.dbQueries.CreateRecoveryCode(req.Context(), database.CreateRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(code),
+		})
+		if err != nil {
+			log.Printf("error storing recovery code: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+	}
+
+	err = cfg.dbQueries.EnableTOTP(req.Context(), database.EnableTOTPParams{
+		ID:           user.ID,
+		TotpLastStep: step,
+	})
+	if err != nil {
+		log.Printf("error enabling totp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (cfg *apiConfig) handlerTOTPDisable(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+	if !user.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is not enabled")
+		return
+	}
+	if err := cfg.checkCurrentPassword(req.Context(), user, reqJSON.Password); err != nil {
+		respondWithPasswordError(w, err)
+		return
+	}
+	if err := cfg.verifySecondFactor(req.Context(), user, reqJSON.Code, reqJSON.RecoveryCode); err != nil {
+		cfg.respondWithSecondFactorError(w, err)
+		return
+	}
+
+	if err := cfg.dbQueries.DisableTOTP(req.Context(), user.ID); err != nil {
+		log.Printf("error disabling totp: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if err := cfg.dbQueries.DeleteRecoveryCodes(req.Context(), user.ID); err != nil {
+		log.Printf("error deleting recovery codes: %v\n", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLoginMFA is the second login step: it exchanges the challenge
+// token from handlerLogin plus a TOTP or recovery code for a session.
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID, err := auth.ValidateMFAToken(reqJSON.MFAToken, cfg.secretString)
+	if err != nil {
+		log.Printf("error validating MFA token: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired MFA token")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired MFA token")
+		return
+	}
+	if err := cfg.verifySecondFactor(req.Context(), user, reqJSON.Code, reqJSON.RecoveryCode); err != nil {
+		cfg.respondWithSecondFactorError(w, err)
+		return
+	}
+
+	cfg.respondWithSession(w, req, user)
+}
+
+type secondFactorLockedError struct {
+	retryAfter time.Duration
+}
+
+func (e secondFactorLockedError) Error() string {
+	return "too many invalid two-factor codes"
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+// Failures count against the user's lockout so six-digit codes cannot be
+// brute forced.
+func (cfg *apiConfig) verifySecondFactor(ctx context.Context, user database.User, code, recoveryCode string) error {
+	lockoutKey := "mfa:" + user.ID.String()
+	retryAfter, err := cfg.accountLimiter.RetryAfter(ctx, lockoutKey)
+	if err != nil {
+		log.Printf("error checking mfa lockout: %v", err)
+	}
+	if retryAfter > 0 {
+		return secondFactorLockedError{retryAfter: retryAfter}
+	}
+
+	if err := cfg.checkSecondFactor(ctx, user, code, recoveryCode); err != nil {
+		if _, err := cfg.accountLimiter.Fail(ctx, lockoutKey); err != nil {
+			log.Printf("error recording mfa failure: %v", err)
+		}
+		return err
+	}
+
+	if err := cfg.accountLimiter.Reset(ctx, lockoutKey); err != nil {
+		log.Printf("error resetting mfa failures: %v", err)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, user database.User, code, recoveryCode string) error {
+	if !user.TotpEnabled || !user.TotpSecret.Valid {
+		return errInvalidSecondFactor
+	}
+
+	if recoveryCode != "" {
+		used, err := cfg.dbQueries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashRecoveryCode(recoveryCode),
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	step, err := auth.ValidateTOTP(user.TotpSecret.String, code, time.Now(), user.TotpLastStep)
+	if err != nil {
+		return errInvalidSecondFactor
+	}
+	// Claiming the step in the database rather than trusting the value read
+	// above stops two concurrent requests from both using the same code.
+	claimed, err := cfg.dbQueries.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		ID:           user.ID,
+		TotpLastStep: step,
+	})
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+func (cfg *apiConfig) respondWithSecondFactorError(w http.ResponseWriter, err error) {
+	var locked secondFactorLockedError
+	switch {
+	case errors.As(err, &locked):
+		respondWithRetryAfter(w, locked.retryAfter)
+	case errors.Is(err, errInvalidSecondFactor):
+		respondWithError(w, http.StatusUnauthorized, "invalid two-factor code")
+	default:
+		log.Printf("error verifying second factor: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/notification"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 100
+	maxMarkReadIDs           = 100
+)
+
+type NotificationPreferencesResponse struct {
+	// Types says whether the user is notified of each type.
+	Types       map[string]bool `json:"types"`
+	EmailDigest bool            `json:"email_digest"`
+}
+
+func newNotificationPreferencesResponse(prefs database.NotificationPreference) NotificationPreferencesResponse {
+	res := NotificationPreferencesResponse{
+		Types:       map[string]bool{},
+		EmailDigest: prefs.EmailDigest,
+	}
+	for _, t := range notification.Types {
+		res.Types[t] = !slices.Contains(prefs.MutedTypes, t)
+	}
+	return res
+}
+
+// handlerGetNotifications pages through the caller's notifications newest
+// first. Pass the id of the last notification of a page as ?before= to get
+// the next one, and ?unread=true to leave out ones already read.
+func (cfg *apiConfig) handlerGetNotifications(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	limit := defaultNotificationLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxNotificationLimit))
+			return
+		}
+		limit = n
+	}
+	var before uuid.NullUUID
+	if raw := query.Get("before"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid before id")
+			return
+		}
+		before = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	var unreadOnly bool
+	if raw := query.Get("unread"); raw != "" {
+		var err error
+		if unreadOnly, err = strconv.ParseBool(raw); err != nil {
+			respondWithError(w, http.StatusBadRequest, "unread must be true or false")
+			return
+		}
+	}
+
+	notifications, err := cfg.dbQueries.GetNotifications(req.Context(), database.GetNotificationsParams{
+		UserID:     requestPrincipal(req).UserID,
+		UnreadOnly: unreadOnly,
+		BeforeID:   before,
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		log.Printf("error retrieving notifications: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []NotificationResponse{}
+	for _, n := range notifications {
+		res = append(res, newNotificationResponse(n))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerGetUnreadNotificationCount counts unread notifications, in total
+// and by type.
+func (cfg *apiConfig) handlerGetUnreadNotificationCount(w http.ResponseWriter, req *http.Request) {
+	counts, err := cfg.dbQueries.CountUnreadNotifications(req.Context(), requestPrincipal(req).UserID)
+	if err != nil {
+		log.Printf("error counting notifications: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	var res struct {
+		Total  int64            `json:"total"`
+		ByType map[string]int64 `json:"by_type"`
+	}
+	res.ByType = map[string]int64{}
+	for _, t := range notification.Types {
+		res.ByType[t] = 0
+	}
+	for _, count := range counts {
+		res.Total += count.Count
+		res.ByType[count.Type] = count.Count
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerMarkNotificationsRead marks the listed notifications as read, or
+// all of them if ids is left out.
+func (cfg *apiConfig) handlerMarkNotificationsRead(w http.ResponseWriter, req *http.Request) {
+	var reqJSON struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&reqJSON); err != nil {
+			log.Printf("error decoding request: %v\n", err)
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if len(reqJSON.IDs) > maxMarkReadIDs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("at most %d ids can be marked at once", maxMarkReadIDs))
+		return
+	}
+	userID := requestPrincipal(req).UserID
+
+	var marked int64
+	var err error
+	if reqJSON.IDs == nil {
+		marked, err = cfg.dbQueries.MarkAllNotificationsRead(req.Context(), userID)
+	} else {
+		marked, err = cfg.dbQueries.MarkNotificationsRead(req.Context(), database.MarkNotificationsReadParams{
+			UserID: userID,
+			Ids:    reqJSON.IDs,
+		})
+	}
+	if err != nil {
+		log.Printf("error marking notifications read: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, struct {
+		Marked int64 `json:"marked"`
+	}{Marked: marked})
+}
+
+func (cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, req *http.Request) {
+	prefs, ok := cfg.notificationPreferences(w, req)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newNotificationPreferencesResponse(prefs))
+}
+
+// handlerUpdateNotificationPreferences changes which notification types the
+// caller receives and whether they get the email digest. Types and fields
+// left out keep their current setting.
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, req *http.Request) {
+	var reqJSON struct {
+		Types       map[string]bool `json:"types"`
+		EmailDigest *bool           `json:"email_digest"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var fieldErrors []FieldError
+	for t := range reqJSON.Types {
+		if !notification.ValidType(t) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "types." + t, Rule: "unknown_type", Message: "unknown notification type"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		slices.SortFunc(fieldErrors, func(a, b FieldError) int { return strings.Compare(a.Field, b.Field) })
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "invalid notification preferences", fieldErrors)
+		return
+	}
+
+	prefs, ok := cfg.notificationPreferences(w, req)
+	if !ok {
+		return
+	}
+	current := newNotificationPreferencesResponse(prefs)
+	muted := []string{}
+	for _, t := range notification.Types {
+		enabled := current.Types[t]
+		if want, ok := reqJSON.Types[t]; ok {
+			enabled = want
+		}
+		if !enabled {
+			muted = append(muted, t)
+		}
+	}
+	emailDigest := prefs.EmailDigest
+	if reqJSON.EmailDigest != nil {
+		emailDigest = *reqJSON.EmailDigest
+	}
+
+	updated, err := cfg.dbQueries.UpsertNotificationPreferences(req.Context(), database.UpsertNotificationPreferencesParams{
+		UserID:      requestPrincipal(req).UserID,
+		MutedTypes:  muted,
+		EmailDigest: emailDigest,
+	})
+	if err != nil {
+		log.Printf("error updating notification preferences: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newNotificationPreferencesResponse(updated))
+}
+
+// notificationPreferences loads the caller's preferences, falling back to
+// the defaults of every type on and no digest.
+func (cfg *apiConfig) notificationPreferences(w http.ResponseWriter, req *http.Request) (database.NotificationPreference, bool) {
+	userID := requestPrincipal(req).UserID
+	prefs, err := cfg.dbQueries.GetNotificationPreferences(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.NotificationPreference{UserID: userID}, true
+	}
+	if err != nil {
+		log.Printf("error retrieving notification preferences: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return prefs, false
+	}
+	return prefs, true
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// Token lifetimes for third-party clients are fixed; clients are expected
+// to use their refresh token rather than ask for longer-lived tokens.
+const (
+	oauthCodeTTL         = 10 * time.Minute
+	oauthAccessTokenTTL  = time.Hour
+	oauthRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, req *http.Request) {
+	ar, err := cfg.parseAuthorizeRequest(req.Context(), req.URL.Query())
+	if err != nil {
+		if ar.RedirectURI == "" {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		redirectWithOAuthError(w, req, ar, err)
+		return
+	}
+	renderConsent(w, http.StatusOK, consentPage{authorizeRequest: ar})
+}
+
+// handlerOAuthConsent handles the consent form. The user signs in on the
+// form itself, so the client never sees their password.
+func (cfg *apiConfig) handlerOAuthConsent(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+	ar, err := cfg.parseAuthorizeRequest(req.Context(), req.PostForm)
+	if err != nil {
+		if ar.RedirectURI == "" {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		redirectWithOAuthError(w, req, ar, err)
+		return
+	}
+	if req.PostForm.Get("decision") != "allow" {
+		redirectWithOAuthError(w, req, ar, &oauthError{Code: "access_denied", Description: "the user denied the request"})
+		return
+	}
+
+	email := req.PostForm.Get("email")
+	page := consentPage{authorizeRequest: ar, Email: email}
+
+	accountKey := accountLockoutKey(email)
+	ipKey := ipLockoutKey(req)
+	if retryAfter := cfg.loginRetryAfter(req.Context(), accountKey, ipKey); retryAfter > 0 {
+		setRetryAfter(w, retryAfter)
+		page.Error = "Too many failed login attempts, try again later."
+		renderConsent(w, http.StatusTooManyRequests, page)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), email)
+	if err == nil {
+		err = auth.CheckPasswordHash(req.PostForm.Get("password"), user.HashedPassword)
+	}
+	if err != nil {
+		cfg.recordLoginFailure(req.Context(), accountKey, ipKey)
+		page.Error = "Incorrect email or password."
+		renderConsent(w, http.StatusUnauthorized, page)
+		return
+	}
+	if err := cfg.accountLimiter.Reset(req.Context(), accountKey); err != nil {
+		log.Printf("error resetting login failures: %v", err)
+	}
+
+	if user.TotpEnabled {
+		err := cfg.verifySecondFactor(req.Context(), user, req.PostForm.Get("totp_code"), req.PostForm.Get("recovery_code"))
+		var locked secondFactorLockedError
+		switch {
+		case errors.As(err, &locked):
+			setRetryAfter(w, locked.retryAfter)
+			page.Error = "Too many invalid two-factor codes, try again later."
+			renderConsent(w, http.StatusTooManyRequests, page)
+			return
+		case err != nil:
+			page.Error = "Invalid two-factor code."
+			renderConsent(w, http.StatusUnauthorized, page)
+			return
+		}
+	}
+	if user.DeletionRequestedAt.Valid {
+		page.Error = "This account is scheduled for deletion. Log in to Chirpy to cancel the deletion first."
+		renderConsent(w, http.StatusForbidden, page)
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("error creating authorization code: %v\n", err)
+		redirectWithOAuthError(w, req, ar, err)
+		return
+	}
+	err = cfg.dbQueries.CreateOAuthAuthorizationCode(req.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ExpiresAt:     time.Now().UTC().Add(oauthCodeTTL),
+		ClientID:      ar.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   ar.RedirectURI,
+		Scope:         auth.FormatScope(ar.Scopes),
+		CodeChallenge: ar.CodeChallenge,
+	})
+	if err != nil {
+		log.Printf("error storing authorization code: %v\n", err)
+		redirectWithOAuthError(w, req, ar, err)
+		return
+	}
+	redirectToClient(w, req, ar, url.Values{"code": {code}})
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_request", Description: "invalid form"})
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(req)
+	if err != nil {
+		cfg.respondWithClientError(w, req, err)
+		return
+	}
+
+	var (
+		userID uuid.UUID
+		scopes []string
+	)
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := cfg.dbQueries.UseOAuthAuthorizationCode(req.Context(), auth.HashToken(req.PostForm.Get("code")))
+		if err != nil {
+			log.Printf("error redeeming authorization code: %v\n", err)
+			respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "invalid, expired or used authorization code"})
+			return
+		}
+		if code.ClientID != client.ID || code.RedirectUri != req.PostForm.Get("redirect_uri") {
+			respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "authorization code was issued to another client or redirect_uri"})
+			return
+		}
+		if err := auth.VerifyPKCE(req.PostForm.Get("code_verifier"), code.CodeChallenge); err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: err.Error()})
+			return
+		}
+		userID = code.UserID
+		scopes, _ = auth.ParseScope(code.Scope)
+
+	case "refresh_token":
+		grant, err := cfg.dbQueries.UseOAuthRefreshToken(req.Context(), auth.HashToken(req.PostForm.Get("refresh_token")))
+		if err != nil {
+			log.Printf("error redeeming oauth refresh token: %v\n", err)
+			respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "invalid, expired or revoked refresh token"})
+			return
+		}
+		if grant.ClientID != client.ID {
+			respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_grant", Description: "refresh token was issued to another client"})
+			return
+		}
+		userID = grant.UserID
+		scopes, _ = auth.ParseScope(grant.Scope)
+		// A refresh may narrow the scope but never widen it.
+		if requested := req.PostForm.Get("scope"); requested != "" {
+			narrowed, err := auth.ParseScope(requested)
+			if err != nil || !auth.ScopeSubset(narrowed, scopes) {
+				respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_scope", Description: "scope exceeds the original grant"})
+				return
+			}
+			scopes = narrowed
+		}
+
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "unsupported_grant_type"})
+		return
+	}
+
+	res, err := cfg.issueOAuthTokens(req.Context(), client.ID, userID, scopes)
+	if err != nil {
+		log.Printf("error issuing oauth tokens: %v\n", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) issueOAuthTokens(ctx context.Context, clientID, userID uuid.UUID, scopes []string) (oauthTokenResponse, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	now := time.Now().UTC()
+	grant, err := cfg.dbQueries.CreateOAuthToken(ctx, database.CreateOAuthTokenParams{
+		ClientID:         clientID,
+		UserID:           userID,
+		Scope:            auth.FormatScope(scopes),
+		ExpiresAt:        now.Add(oauthAccessTokenTTL),
+		RefreshTokenHash: auth.HashToken(refreshToken),
+		RefreshExpiresAt: now.Add(oauthRefreshTokenTTL),
+	})
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	accessToken, err := auth.MakeOAuthAccessToken(auth.OAuthAccessToken{
+		UserID:    userID,
+		ClientID:  clientID,
+		TokenID:   grant.ID,
+		Scopes:    scopes,
+		ExpiresAt: grant.ExpiresAt,
+	}, cfg.secretString)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	return oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        grant.Scope,
+	}, nil
+}
+
+func (cfg *apiConfig) respondWithClientError(w http.ResponseWriter, req *http.Request, err error) {
+	if !errors.Is(err, errInvalidClient) {
+		log.Printf("error authenticating oauth client: %v\n", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{Code: "server_error"})
+		return
+	}
+	if _, _, ok := req.BasicAuth(); ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	respondWithOAuthError(w, http.StatusUnauthorized, errInvalidClient)
+}
+
+// handlerOAuthIntrospect implements RFC 7662. Clients can only introspect
+// their own tokens; anything else is reported as inactive.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_request", Description: "invalid form"})
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(req)
+	if err != nil {
+		cfg.respondWithClientError(w, req, err)
+		return
+	}
+
+	type introspection struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Sub       string `json:"sub,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		Exp       int64  `json:"exp,omitempty"`
+		Iat       int64  `json:"iat,omitempty"`
+	}
+
+	tokenString := req.PostForm.Get("token")
+	grant, err := cfg.lookupOAuthToken(req.Context(), tokenString)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("error looking up oauth token: %v\n", err)
+	}
+	if err != nil || grant.ClientID != client.ID || grant.RevokedAt.Valid {
+		respondWithJSON(w, http.StatusOK, introspection{Active: false})
+		return
+	}
+
+	res := introspection{
+		Active:   true,
+		Scope:    grant.Scope,
+		ClientID: grant.ClientID.String(),
+		Sub:      grant.UserID.String(),
+		Iat:      grant.CreatedAt.Unix(),
+	}
+	if grant.RefreshTokenHash == auth.HashToken(tokenString) {
+		res.TokenType = "refresh_token"
+		res.Exp = grant.RefreshExpiresAt.Unix()
+		if grant.RefreshExpiresAt.Before(time.Now().UTC()) {
+			res = introspection{Active: false}
+		}
+	} else {
+		res.TokenType = "Bearer"
+		res.Exp = grant.ExpiresAt.Unix()
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking either token of a pair
+// revokes both, and unknown tokens are not an error.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{Code: "invalid_request", Description: "invalid form"})
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(req)
+	if err != nil {
+		cfg.respondWithClientError(w, req, err)
+		return
+	}
+
+	grant, err := cfg.lookupOAuthToken(req.Context(), req.PostForm.Get("token"))
+	if err == nil && grant.ClientID == client.ID {
+		err = cfg.dbQueries.RevokeOAuthToken(req.Context(), grant.ID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("error revoking oauth token: %v\n", err)
+		respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{Code: "server_error"})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+type OAuthClientResponse struct {
+	ID           uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scope        string    `json:"scope"`
+	Confidential bool      `json:"confidential"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+}
+
+func newOAuthClientResponse(client database.OauthClient) OAuthClientResponse {
+	return OAuthClientResponse{
+		ID:           client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scope:        client.Scope,
+		Confidential: client.SecretHash.Valid,
+	}
+}
+
+// validRedirectURI accepts absolute https URIs, and http only for loopback
+// addresses so that native apps and local development still work.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+// handlerCreateOAuthClient registers a third-party client. Confidential
+// clients get a secret, which is only ever shown in this response.
+func (cfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scope        string   `json:"scope"`
+		Confidential bool     `json:"confidential"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var fieldErrors []FieldError
+	if reqJSON.Name == "" {
+		fieldErrors = append(fieldErrors, FieldError{Field: "name", Rule: "required", Message: "name is required"})
+	}
+	if len(reqJSON.RedirectURIs) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "redirect_uris", Rule: "required", Message: "at least one redirect URI is required"})
+	}
+	for _, uri := range reqJSON.RedirectURIs {
+		if !validRedirectURI(uri) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "redirect_uris", Rule: "url", Message: uri + " must be an https URL, or http on a loopback address"})
+		}
+	}
+	scopes, err := auth.ParseScope(reqJSON.Scope)
+	if err != nil || len(scopes) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "scope", Rule: "scope", Message: "scope must be one or more of read, write, follow and admin"})
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "invalid client", fieldErrors)
+		return
+	}
+
+	var secret string
+	var secretHash sql.NullString
+	if reqJSON.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("error creating client secret: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.dbQueries.CreateOAuthClient(req.Context(), database.CreateOAuthClientParams{
+		Name:         reqJSON.Name,
+		SecretHash:   secretHash,
+		RedirectUris: reqJSON.RedirectURIs,
+		Scope:        auth.FormatScope(scopes),
+	})
+	if err != nil {
+		log.Printf("error creating oauth client: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	res := newOAuthClientResponse(client)
+	res.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, res)
+}
+
+func (cfg *apiConfig) handlerGetOAuthClients(w http.ResponseWriter, req *http.Request) {
+	clients, err := cfg.dbQueries.GetOAuthClients(req.Context())
+	if err != nil {
+		log.Printf("error retrieving oauth clients: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []OAuthClientResponse{}
+	for _, client := range clients {
+		res = append(res, newOAuthClientResponse(client))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerDeleteOAuthClient removes a client along with every code and token
+// issued to it.
+func (cfg *apiConfig) handlerDeleteOAuthClient(w http.ResponseWriter, req *http.Request) {
+	clientID, err := uuid.Parse(req.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid client id")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteOAuthClient(req.Context(), clientID)
+	if err != nil {
+		log.Printf("error deleting oauth client: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
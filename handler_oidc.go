@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/oidc"
+)
+
+const oidcStateCookie = "chirpy_oidc_state"
+
+var (
+	errOIDCEmailNotVerified = errors.New("the provider did not return a verified email address")
+	errOIDCAccountNotLinked = errors.New("an account with this email exists but its email is not verified; log in with your password and verify it first")
+)
+
+// handlerOIDCLogin sends the user to the external provider. State, nonce
+// and the PKCE verifier are kept in a signed, short-lived cookie that is
+// only sent back to the callback.
+func (cfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, req *http.Request) {
+	if cfg.oidcProvider == nil {
+		respondWithError(w, http.StatusNotFound, "external login is not configured")
+		return
+	}
+
+	var loginState auth.LoginState
+	for _, v := range []*string{&loginState.State, &loginState.Nonce, &loginState.CodeVerifier} {
+		random, err := auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("error creating login state: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		*v = random
+	}
+
+	authURL, err := cfg.oidcProvider.AuthCodeURL(req.Context(), loginState.State, loginState.Nonce, loginState.CodeVerifier)
+	if err != nil {
+		log.Printf("error starting external login: %v\n", err)
+		respondWithError(w, http.StatusBadGateway, "external login is unavailable")
+		return
+	}
+	cookieValue, err := auth.MakeLoginStateToken(loginState, cfg.secretString)
+	if err != nil {
+		log.Printf("error signing login state: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	http.SetCookie(w, cfg.oidcStateCookie(cookieValue, 600))
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) oidcStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/login/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.baseURL, "https://"),
+		// Lax, not Strict: the callback is a top-level redirect from the
+		// provider's site.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// handlerOIDCCallback finishes an external login and responds exactly like
+// handlerLogin: with a session, or with an MFA challenge if the user has
+// two-factor authentication enabled.
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, req *http.Request) {
+	if cfg.oidcProvider == nil {
+		respondWithError(w, http.StatusNotFound, "external login is not configured")
+		return
+	}
+
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "login session expired, try again")
+		return
+	}
+	http.SetCookie(w, cfg.oidcStateCookie("", -1))
+	loginState, err := auth.ValidateLoginStateToken(cookie.Value, cfg.secretString)
+	if err != nil {
+		log.Printf("error validating login state: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "login session expired, try again")
+		return
+	}
+
+	query := req.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(loginState.State)) != 1 {
+		respondWithError(w, http.StatusBadRequest, "login state does not match")
+		return
+	}
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("external login failed at provider: %s %s\n", providerErr, query.Get("error_description"))
+		respondWithError(w, http.StatusUnauthorized, "external login was not completed")
+		return
+	}
+
+	claims, err := cfg.oidcProvider.Exchange(req.Context(), query.Get("code"), loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("error completing external login: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "external login failed")
+		return
+	}
+
+	user, err := cfg.userForOIDCClaims(req.Context(), claims)
+	switch {
+	case errors.Is(err, errOIDCEmailNotVerified), errors.Is(err, errOIDCAccountNotLinked):
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	case err != nil:
+		log.Printf("error resolving external login: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	if user.TotpEnabled {
+		cfg.respondWithMFAChallenge(w, user)
+		return
+	}
+	cfg.respondWithSession(w, req, user)
+}
+
+// userForOIDCClaims finds the user behind an external identity. The first
+// login links the identity to the account with the same email, or creates
+// one. Both require the provider to have verified the email; linking also
+// requires that Chirpy verified it, otherwise someone could sign up with
+// another person's address and wait for them to link it.
+func (cfg *apiConfig) userForOIDCClaims(ctx context.Context, claims oidc.Claims) (database.User, error) {
+	identity, err := cfg.dbQueries.GetOIDCIdentity(ctx, database.GetOIDCIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		return cfg.dbQueries.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return database.User{}, errOIDCEmailNotVerified
+	}
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = cfg.createOIDCUser(ctx, claims.Email)
+		if err != nil {
+			return database.User{}, err
+		}
+	case err != nil:
+		return database.User{}, err
+	case !user.EmailVerified:
+		return database.User{}, errOIDCAccountNotLinked
+	}
+
+	_, err = cfg.dbQueries.CreateOIDCIdentity(ctx, database.CreateOIDCIdentityParams{
+		UserID:  user.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// createOIDCUser signs up a user who arrived through an external provider.
+// They get a random password nobody knows; a password reset sets a real
+// one.
+func (cfg *apiConfig) createOIDCUser(ctx context.Context, email string) (database.User, error) {
+	password, err := auth.MakeRefreshToken()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := cfg.dbQueries.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := cfg.dbQueries.SetEmailVerified(ctx, user.ID); err != nil {
+		return database.User{}, err
+	}
+	user.EmailVerified = true
+	return user, nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+const (
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 500
+)
+
+type WebhookSubscriptionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func newWebhookSubscriptionResponse(sub database.WebhookSubscription) WebhookSubscriptionResponse {
+	return WebhookSubscriptionResponse{
+		ID:        sub.ID,
+		CreatedAt: sub.CreatedAt,
+		URL:       sub.Url,
+		Events:    sub.EventTypes,
+	}
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func newWebhookDeliveryResponse(delivery database.WebhookDelivery) WebhookDeliveryResponse {
+	res := WebhookDeliveryResponse{
+		ID:        delivery.ID,
+		CreatedAt: delivery.CreatedAt,
+		EventType: delivery.EventType,
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError.String,
+		Payload:   delivery.Payload,
+	}
+	if delivery.Status == deliveryStatusPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		res.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		res.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return res
+}
+
+// webhookOwner is the owner of the caller's webhook subscriptions: the
+// user, or nobody for subscriptions managed with the admin key.
+func webhookOwner(req *http.Request) uuid.NullUUID {
+	principal := requestPrincipal(req)
+	if principal.Method == AuthMethodAdminKey {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: principal.UserID, Valid: true}
+}
+
+// validWebhookURL checks the URL of a subscription owned by owner. Users'
+// subscriptions must use https; instance-wide ones, which only the
+// operator can create, may also use http on a loopback address for local
+// development. Where users' deliveries may connect is checked again when
+// they are sent.
+func validWebhookURL(raw string, owner uuid.NullUUID) bool {
+	if !owner.Valid {
+		return validRedirectURI(raw)
+	}
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme == "https" && u.Host != "" && u.Fragment == ""
+}
+
+// handlerCreateWebhook registers a webhook subscription. The signing
+// secret is only ever shown in this response.
+func (cfg *apiConfig) handlerCreateWebhook(w http.ResponseWriter, req *http.Request) {
+	var reqJSON struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var fieldErrors []FieldError
+	owner := webhookOwner(req)
+	if !validWebhookURL(reqJSON.URL, owner) {
+		message := "url must be an https URL"
+		if !owner.Valid {
+			message = "url must be an https URL, or http on a loopback address"
+		}
+		fieldErrors = append(fieldErrors, FieldError{Field: "url", Rule: "url", Message: message})
+	}
+	if len(reqJSON.Events) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "events", Rule: "required", Message: "at least one event is required"})
+	}
+	for _, event := range reqJSON.Events {
+		if !outboundEventTypes[event] {
+			fieldErrors = append(fieldErrors, FieldError{Field: "events", Rule: "event", Message: event + " is not a known event"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "invalid webhook", fieldErrors)
+		return
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("error creating webhook secret: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	secret = "whsec_" + secret
+
+	sub, err := cfg.dbQueries.CreateWebhookSubscription(req.Context(), database.CreateWebhookSubscriptionParams{
+		UserID:     owner,
+		Url:        reqJSON.URL,
+		Secret:     secret,
+		EventTypes: reqJSON.Events,
+	})
+	if err != nil {
+		log.Printf("error creating webhook subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	res := newWebhookSubscriptionResponse(sub)
+	res.Secret = secret
+	respondWithJSON(w, http.StatusCreated, res)
+}
+
+func (cfg *apiConfig) handlerGetWebhooks(w http.ResponseWriter, req *http.Request) {
+	subs, err := cfg.dbQueries.GetWebhookSubscriptionsByOwner(req.Context(), webhookOwner(req))
+	if err != nil {
+		log.Printf("error retrieving webhook subscriptions: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []WebhookSubscriptionResponse{}
+	for _, sub := range subs {
+		res = append(res, newWebhookSubscriptionResponse(sub))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerDeleteWebhook removes a subscription along with its delivery log
+// and any deliveries still queued.
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, req *http.Request) {
+	webhookID, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	deleted, err := cfg.dbQueries.DeleteWebhookSubscription(req.Context(), database.DeleteWebhookSubscriptionParams{
+		ID:     webhookID,
+		UserID: webhookOwner(req),
+	})
+	if err != nil {
+		log.Printf("error deleting webhook subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedWebhook loads the subscription named in the path, responding with
+// an error and returning false unless it belongs to the caller.
+func (cfg *apiConfig) ownedWebhook(w http.ResponseWriter, req *http.Request) (database.WebhookSubscription, bool) {
+	webhookID, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid webhook id")
+		return database.WebhookSubscription{}, false
+	}
+	sub, err := cfg.dbQueries.GetWebhookSubscription(req.Context(), webhookID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("error retrieving webhook subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return database.WebhookSubscription{}, false
+	}
+	if err != nil || sub.UserID != webhookOwner(req) {
+		respondWithError(w, http.StatusNotFound, "webhook not found")
+		return database.WebhookSubscription{}, false
+	}
+	return sub, true
+}
+
+// handlerGetWebhookDeliveries is the delivery log of a subscription, newest
+// first. ?status= narrows it to pending, delivered or dead deliveries.
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	sub, ok := cfg.ownedWebhook(w, req)
+	if !ok {
+		return
+	}
+
+	query := req.URL.Query()
+	limit := defaultWebhookDeliveryLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxWebhookDeliveryLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := cfg.dbQueries.GetWebhookDeliveries(req.Context(), database.GetWebhookDeliveriesParams{
+		SubscriptionID: sub.ID,
+		Status:         query.Get("status"),
+		MaxResults:     int32(limit),
+	})
+	if err != nil {
+		log.Printf("error retrieving webhook deliveries: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []WebhookDeliveryResponse{}
+	for _, delivery := range deliveries {
+		res = append(res, newWebhookDeliveryResponse(delivery))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerRetryWebhookDelivery puts a dead-lettered delivery back in the
+// queue with a fresh set of attempts.
+func (cfg *apiConfig) handlerRetryWebhookDelivery(w http.ResponseWriter, req *http.Request) {
+	sub, ok := cfg.ownedWebhook(w, req)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	delivery, err := cfg.dbQueries.RetryWebhookDelivery(req.Context(), database.RetryWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: sub.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "no dead delivery with that id")
+		return
+	}
+	if err != nil {
+		log.Printf("error retrying webhook delivery: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	cfg.wakeWebhookDispatcher()
+	respondWithJSON(w, http.StatusAccepted, newWebhookDeliveryResponse(delivery))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/mailer"
+)
+
+// handlerPasswordResetRequest always answers 202 so that it cannot be used
+// to find out which emails have accounts. Failures after the lookup are only
+// logged, since answering differently for them would give the account away.
+func (cfg *apiConfig) handlerPasswordResetRequest(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Email string `json:"email"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByEmail(req.Context(), reqJSON.Email)
+	if err != nil {
+		log.Printf("password reset requested for unknown email\n")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := cfg.issueOneTimeToken(req.Context(), user.ID, auth.PurposeResetPassword, resetPasswordTokenTTL)
+	if err != nil {
+		log.Printf("error issuing reset token: %v\n", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	err = cfg.mailer.Send(req.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account. If it was you, open the link below:\n\n%s\n\nThe link expires in 1 hour. If you did not ask for this, you can ignore this email.\n",
+			cfg.linkURL("/app/reset-password", token)),
+	})
+	if err != nil {
+		log.Printf("error sending reset email: %v\n", err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handlerPasswordResetConfirm sets a new password and signs the user out
+// everywhere by revoking all of their refresh tokens, OAuth grants and
+// personal access tokens.
+func (cfg *apiConfig) handlerPasswordResetConfirm(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// Check the policy before redeeming so a rejected password does not
+	// burn the token.
+	if fieldErrors := cfg.checkPasswordPolicy(reqJSON.Password); len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "password does not meet requirements", fieldErrors)
+		return
+	}
+
+	userID, err := cfg.redeemOneTimeToken(req.Context(), reqJSON.Token, auth.PurposeResetPassword)
+	if errors.Is(err, errInvalidOneTimeToken) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("error redeeming reset token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(reqJSON.Password)
+	if err != nil {
+		log.Printf("error hashing password: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		err := q.UpdatePassword(req.Context(), database.UpdatePasswordParams{
+			ID:             userID,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+		return revokeUserCredentials(req.Context(), q, userID)
+	})
+	if err != nil {
+		log.Printf("error updating password: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	err = cfg.dbQueries.RevokeOneTimeTokens(req.Context(), database.RevokeOneTimeTokensParams{
+		UserID:  userID,
+		Purpose: auth.PurposeResetPassword,
+	})
+	if err != nil {
+		log.Printf("error revoking reset tokens: %v\n", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/subscription"
+)
+
+const maxWebhookBodyBytes = 1 << 20
+
+const webhookProviderPolka = "polka"
+
+// Outcomes recorded against a stored webhook event.
+const (
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+// polkaSubscriptionEvents maps Polka event types to subscription events.
+var polkaSubscriptionEvents = map[string]string{
+	"user.upgraded":       subscription.EventUpgraded,
+	"user.renewed":        subscription.EventRenewed,
+	"user.downgraded":     subscription.EventDowngraded,
+	"user.payment_failed": subscription.EventPaymentFailed,
+}
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID           uuid.UUID  `json:"user_id"`
+		Plan             string     `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// handlerPolkaWebhook applies membership events from Polka. Requests must
+// be signed with one of the shared webhook secrets. Every event is stored
+// before it is applied, and an event ID that has already been processed is
+// not applied again.
+func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxWebhookBodyBytes))
+	if err != nil {
+		log.Printf("error reading webhook body: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	// The signature covers the raw bytes, so verify before decoding.
+	err = cfg.polkaVerifier.Verify(req.Header.Get("X-Polka-Timestamp"), req.Header.Get("X-Polka-Signature"), body)
+	if err != nil {
+		log.Printf("rejected polka webhook: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+
+	var reqJSON polkaEvent
+	if err := json.Unmarshal(body, &reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if reqJSON.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "missing event id")
+		return
+	}
+
+	event, err := cfg.dbQueries.RecordWebhookEvent(req.Context(), database.RecordWebhookEventParams{
+		Provider:  webhookProviderPolka,
+		EventID:   reqJSON.ID,
+		EventType: reqJSON.Event,
+		Payload:   body,
+	})
+	if err != nil {
+		log.Printf("error recording webhook event: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	// Claiming fails if the event was already applied, or another delivery
+	// of it is still being processed. A duplicate of a finished event is
+	// acknowledged so that Polka stops retrying it; one still in progress
+	// gets 409 so that Polka tries again later.
+	claimed, err := cfg.dbQueries.ClaimWebhookEvent(req.Context(), event.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := cfg.dbQueries.GetWebhookEvent(req.Context(), event.ID)
+		if err != nil {
+			log.Printf("error retrieving webhook event: %v\n", err)
+			respondWithError(w, http.StatusInternalServerError, "")
+			return
+		}
+		if current.Status == webhookStatusProcessed || current.Status == webhookStatusIgnored {
+			log.Printf("acknowledged duplicate polka event %s\n", reqJSON.ID)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		log.Printf("rejected polka event %s while it is being processed\n", reqJSON.ID)
+		respondWithError(w, http.StatusConflict, "event is being processed")
+		return
+	}
+	if err != nil {
+		log.Printf("error claiming webhook event: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	if _, err := cfg.processPolkaEvent(req.Context(), claimed); err != nil {
+		switch {
+		case errors.Is(err, errSubscriptionUserNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, subscription.ErrNotSubscribed):
+			respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		default:
+			respondWithError(w, http.StatusInternalServerError, "")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processPolkaEvent applies a claimed event and records the outcome on it.
+// A successful outcome is recorded in the transaction that applies the
+// event, so an event cannot be applied and then claimed again. A failure
+// rolls the change back and is recorded afterwards. The returned error is
+// the reason the event failed, if it did.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	var finished database.WebhookEvent
+	var status string
+	var applyErr error
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		status, applyErr = cfg.applyPolkaEvent(ctx, q, event)
+		if applyErr != nil {
+			return applyErr
+		}
+		var err error
+		finished, err = q.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+			ID:     event.ID,
+			Status: status,
+		})
+		return err
+	})
+	if applyErr == nil {
+		if err != nil {
+			// Nothing was applied; the event is claimed again once its
+			// claim goes stale.
+			log.Printf("error recording webhook outcome: %v\n", err)
+			return event, err
+		}
+		return finished, nil
+	}
+
+	log.Printf("error applying polka event %s: %v\n", event.EventID, applyErr)
+	finished, err = cfg.dbQueries.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:     event.ID,
+		Status: status,
+		Error:  sql.NullString{String: applyErr.Error(), Valid: true},
+	})
+	if err != nil {
+		log.Printf("error recording webhook outcome: %v\n", err)
+		return event, applyErr
+	}
+	return finished, applyErr
+}
+
+// applyPolkaEvent applies an event through q. The event is dated when it
+// was received rather than when it is applied, so that a retry or replay
+// has the effect it would have had on time.
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, q *database.Queries, event database.WebhookEvent) (string, error) {
+	var polka polkaEvent
+	if err := json.Unmarshal(event.Payload, &polka); err != nil {
+		return webhookStatusFailed, err
+	}
+	eventType, ok := polkaSubscriptionEvents[polka.Event]
+	if !ok {
+		return webhookStatusIgnored, nil
+	}
+
+	subEvent := subscription.Event{
+		Type: eventType,
+		Plan: polka.Data.Plan,
+		At:   event.CreatedAt.UTC(),
+	}
+	if polka.Data.CurrentPeriodEnd != nil {
+		subEvent.PeriodEnd = polka.Data.CurrentPeriodEnd.UTC()
+	}
+	_, err := cfg.applySubscriptionEvent(ctx, q, polka.Data.UserID, subEvent, uuid.NullUUID{UUID: event.ID, Valid: true})
+	if err != nil {
+		return webhookStatusFailed, err
+	}
+	return webhookStatusProcessed, nil
+}
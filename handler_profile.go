@@ -0,0 +1,124 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/lib/pq"
+)
+
+const maxProfilePatchBytes = 16 << 10
+
+func (cfg *apiConfig) handlerGetMe(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	res := newUserResponse(user)
+	sub, err := cfg.dbQueries.GetSubscription(req.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("error retrieving subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if err == nil {
+		subRes := newSubscriptionResponse(sub)
+		res.Subscription = &subRes
+	}
+
+	etag := userETag(user, res.Subscription != nil && res.Subscription.Active)
+	w.Header().Set("ETag", etag)
+	if match := req.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	ent, err := cfg.entitlementsFor(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving entitlements: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res.Badges = ent.Badges
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// handlerPatchMe applies a JSON merge patch to the caller's profile. An
+// If-Match header makes the update conditional on the ETag from a previous
+// GET; without one the update is still rejected if the profile changed
+// between reading and writing it.
+func (cfg *apiConfig) handlerPatchMe(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			respondWithError(w, http.StatusUnsupportedMediaType, "use application/merge-patch+json")
+			return
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxProfilePatchBytes))
+	if err != nil {
+		log.Printf("error reading request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	active, err := cfg.subscriptionActive(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if match := req.Header.Get("If-Match"); match != "" && !etagMatches(match, userETag(user, active)) {
+		w.Header().Set("ETag", userETag(user, active))
+		respondWithError(w, http.StatusPreconditionFailed, "profile was modified, fetch it again and retry")
+		return
+	}
+
+	params, fieldErrors, err := applyProfilePatch(user, body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "invalid profile", fieldErrors)
+		return
+	}
+
+	updated, err := cfg.dbQueries.UpdateUserProfile(req.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusPreconditionFailed, "profile was modified, fetch it again and retry")
+		return
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "users_username_idx" {
+		respondWithFieldErrors(w, http.StatusConflict, "invalid profile", []FieldError{
+			{Field: "username", Rule: "unique", Message: "username is taken"},
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("error updating profile: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	w.Header().Set("ETag", userETag(updated, active))
+	respondWithJSON(w, http.StatusOK, newUserResponse(updated))
+}
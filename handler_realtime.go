@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/realtime"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+)
+
+const (
+	realtimeWriteWait = 10 * time.Second
+	realtimePongWait  = 60 * time.Second
+	realtimePingEvery = realtimePongWait * 9 / 10
+	realtimeReadLimit = 4096
+	// realtimeAuthWarning is how long before the token expires the client
+	// is asked to send a fresh one.
+	realtimeAuthWarning = time.Minute
+	// realtimeMaxRejected is how many messages in a row may be refused by
+	// the rate limiter before the connection is closed.
+	realtimeMaxRejected = 10
+	// realtimeBuffer is how many events a connection may fall behind by
+	// before it is closed.
+	realtimeBuffer = 64
+	// realtimeDedupe is how many recent user events are remembered to
+	// drop ones dispatched twice by the outbox.
+	realtimeDedupe = 100
+)
+
+// Close codes specific to the realtime API.
+const closeTokenExpired = 4001
+
+var realtimeUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+type clientFrame struct {
+	msg realtime.ClientMessage
+	err error
+}
+
+// realtimeSession is one WebSocket connection. All writes and all session
+// state belong to the goroutine running run; a second goroutine only
+// reads frames from the client.
+type realtimeSession struct {
+	cfg       *apiConfig
+	conn      *websocket.Conn
+	token     string
+	principal Principal
+	subs      realtime.Subscriptions
+	limiter   *realtime.Limiter
+	rejected  int
+
+	// presenceUsers is the user_ids of the presence subscription. Only
+	// those the user follows and is not blocked by are subscribed to.
+	presenceUsers []uuid.UUID
+
+	timeline  *stream.Subscription
+	user      *stream.Subscription
+	authTimer *time.Timer
+	warned    bool
+}
+
+// handlerRealtime upgrades to a WebSocket that carries timeline,
+// notification, typing and presence events. See the README for the
+// message protocol.
+func (cfg *apiConfig) handlerRealtime(w http.ResponseWriter, req *http.Request) {
+	conn, err := realtimeUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// Upgrade has already responded.
+		log.Printf("error upgrading to websocket: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	principal := requestPrincipal(req)
+	token, _ := auth.GetBearerToken(req.Header)
+	s := &realtimeSession{
+		cfg:       cfg,
+		conn:      conn,
+		token:     token,
+		principal: principal,
+		limiter:   realtime.NewLimiter(cfg.realtimeRate, cfg.realtimeBurst),
+	}
+	s.user, _, _ = cfg.userStream.Subscribe("", func(event stream.Event) bool {
+		return event.Type == realtime.EventPresence || event.UserID == principal.UserID
+	})
+	defer s.user.Close()
+
+	if cfg.presence.Join(principal.UserID) {
+		cfg.setPresence(req.Context(), principal.UserID, true)
+	}
+	defer func() {
+		if cfg.presence.Leave(principal.UserID) {
+			cfg.setPresence(context.Background(), principal.UserID, false)
+		}
+	}()
+
+	s.run(req.Context())
+}
+
+func (s *realtimeSession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	frames := make(chan clientFrame)
+	readErr := make(chan error, 1)
+	go s.read(ctx, frames, readErr)
+
+	ping := time.NewTicker(realtimePingEvery)
+	defer ping.Stop()
+	s.armAuthTimer()
+	defer func() {
+		if s.authTimer != nil {
+			s.authTimer.Stop()
+		}
+		if s.timeline != nil {
+			s.timeline.Close()
+		}
+	}()
+
+	for {
+		var timelineEvents <-chan stream.Event
+		if s.timeline != nil {
+			timelineEvents = s.timeline.Events
+		}
+		var authDeadline <-chan time.Time
+		if s.authTimer != nil {
+			authDeadline = s.authTimer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-readErr:
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("error reading from websocket: %v\n", err)
+			}
+			return
+		case frame := <-frames:
+			if !s.handle(ctx, frame) {
+				return
+			}
+		case event, ok := <-timelineEvents:
+			if !ok || !s.deliver(event) {
+				s.close(websocket.CloseTryAgainLater, "too slow")
+				return
+			}
+		case event, ok := <-s.user.Events:
+			if !ok || !s.deliver(event) {
+				s.close(websocket.CloseTryAgainLater, "too slow")
+				return
+			}
+		case <-ping.C:
+			if !s.recheck(ctx) {
+				return
+			}
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(realtimeWriteWait)); err != nil {
+				return
+			}
+		case <-authDeadline:
+			if s.warned {
+				s.close(closeTokenExpired, "token expired")
+				return
+			}
+			s.warned = true
+			expiresAt := s.principal.ExpiresAt
+			if !s.send(realtime.ServerMessage{Type: realtime.TypeAuthExpiring, ExpiresAt: &expiresAt}) {
+				return
+			}
+			s.authTimer = time.NewTimer(time.Until(expiresAt))
+		}
+	}
+}
+
+// read passes frames from the client to run until the connection fails.
+// A client that stops answering pings is disconnected.
+func (s *realtimeSession) read(ctx context.Context, frames chan<- clientFrame, readErr chan<- error) {
+	s.conn.SetReadLimit(realtimeReadLimit)
+	s.conn.SetReadDeadline(time.Now().Add(realtimePongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(realtimePongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			readErr <- err
+			return
+		}
+		var frame clientFrame
+		frame.err = json.Unmarshal(data, &frame.msg)
+		select {
+		case frames <- frame:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle answers a client message, and returns false if the connection
+// should close.
+func (s *realtimeSession) handle(ctx context.Context, frame clientFrame) bool {
+	if !s.limiter.Allow(time.Now()) {
+		s.rejected++
+		if s.rejected >= realtimeMaxRejected {
+			s.close(websocket.ClosePolicyViolation, "rate limit exceeded")
+			return false
+		}
+		return s.replyError(frame.msg, "rate limit exceeded")
+	}
+	s.rejected = 0
+
+	if frame.err != nil {
+		return s.replyError(frame.msg, "invalid message")
+	}
+
+	msg := frame.msg
+	switch msg.Type {
+	case realtime.TypePing:
+		return s.send(realtime.ServerMessage{ID: msg.ID, Type: realtime.TypePong})
+
+	case realtime.TypeSubscribe:
+		if err := s.subs.Subscribe(msg.Channel, msg.UserIDs); err != nil {
+			return s.replyError(msg, err.Error())
+		}
+		if msg.Channel == realtime.ChannelTimeline && s.timeline == nil {
+			s.timeline, _, _ = s.cfg.chirpStream.Subscribe("", nil)
+		}
+		if msg.Channel == realtime.ChannelPresence {
+			s.presenceUsers = msg.UserIDs
+			if err := s.refreshPresence(ctx); err != nil {
+				log.Printf("error narrowing presence subscription: %v\n", err)
+				s.subs.Unsubscribe(msg.Channel)
+				s.presenceUsers = nil
+				return s.replyError(msg, "could not subscribe to presence")
+			}
+		}
+		return s.send(realtime.ServerMessage{ID: msg.ID, Type: realtime.TypeAck, Channel: msg.Channel})
+
+	case realtime.TypeUnsubscribe:
+		s.subs.Unsubscribe(msg.Channel)
+		if msg.Channel == realtime.ChannelPresence {
+			s.presenceUsers = nil
+		}
+		if msg.Channel == realtime.ChannelTimeline && s.timeline != nil {
+			s.timeline.Close()
+			s.timeline = nil
+		}
+		return s.send(realtime.ServerMessage{ID: msg.ID, Type: realtime.TypeAck, Channel: msg.Channel})
+
+	case realtime.TypeTyping:
+		if msg.To == uuid.Nil || msg.To == s.principal.UserID {
+			return s.replyError(msg, "to must be another user")
+		}
+		blocked, err := s.cfg.dbQueries.HasBlockWithAny(ctx, database.HasBlockWithAnyParams{UserID: s.principal.UserID, OtherIds: []uuid.UUID{msg.To}})
+		if err != nil {
+			log.Printf("error checking blocks: %v\n", err)
+			return s.replyError(msg, "could not send typing signal")
+		}
+		if blocked {
+			return s.replyError(msg, "you cannot send typing signals to this user")
+		}
+		if err := s.cfg.sendSignal(ctx, realtime.EventTyping, msg.To, realtime.TypingData{From: s.principal.UserID}); err != nil {
+			log.Printf("error sending typing signal: %v\n", err)
+			return s.replyError(msg, "could not send typing signal")
+		}
+		return s.send(realtime.ServerMessage{ID: msg.ID, Type: realtime.TypeAck})
+
+	case realtime.TypeAuth:
+		return s.reauthenticate(ctx, msg)
+	}
+	return s.replyError(msg, "unknown message type")
+}
+
+// reauthenticate swaps in a fresh token so that the connection can outlive
+// the one it was opened with. The token must be for the same user.
+func (s *realtimeSession) reauthenticate(ctx context.Context, msg realtime.ClientMessage) bool {
+	principal, err := s.cfg.authenticateToken(ctx, msg.Token)
+	if err != nil {
+		log.Printf("error re-authenticating websocket: %v\n", err)
+		return s.replyError(msg, "invalid token")
+	}
+	if principal.UserID != s.principal.UserID {
+		return s.replyError(msg, "token is for a different user")
+	}
+	if !principal.HasScope(auth.ScopeRead) {
+		return s.replyError(msg, "token is missing the read scope")
+	}
+
+	s.token = msg.Token
+	s.principal = principal
+	s.armAuthTimer()
+	res := realtime.ServerMessage{ID: msg.ID, Type: realtime.TypeAck}
+	if !principal.ExpiresAt.IsZero() {
+		res.ExpiresAt = &principal.ExpiresAt
+	}
+	return s.send(res)
+}
+
+// recheck runs on every ping. It closes the connection if its token has
+// been revoked since it was checked, and drops presence of users who have
+// since been unfollowed or have blocked the user. It returns false if the
+// connection was closed.
+func (s *realtimeSession) recheck(ctx context.Context) bool {
+	if _, err := s.cfg.authenticateToken(ctx, s.token); err != nil {
+		log.Printf("closing websocket whose token no longer works: %v\n", err)
+		s.close(closeTokenExpired, "token revoked")
+		return false
+	}
+	if s.presenceUsers != nil {
+		if err := s.refreshPresence(ctx); err != nil {
+			log.Printf("error narrowing presence subscription: %v\n", err)
+		}
+	}
+	return true
+}
+
+// refreshPresence subscribes to the presence of the users in
+// presenceUsers that the user may currently see.
+func (s *realtimeSession) refreshPresence(ctx context.Context) error {
+	visible, err := s.cfg.dbQueries.GetVisibleFollowees(ctx, database.GetVisibleFolloweesParams{
+		UserID:   s.principal.UserID,
+		OtherIds: s.presenceUsers,
+	})
+	if err != nil {
+		return err
+	}
+	if len(visible) == 0 {
+		// An empty list would mean everyone.
+		s.subs.Unsubscribe(realtime.ChannelPresence)
+		return nil
+	}
+	return s.subs.Subscribe(realtime.ChannelPresence, visible)
+}
+
+// armAuthTimer schedules the warning before the current token expires, or
+// the expiry itself if it is already too close to warn.
+func (s *realtimeSession) armAuthTimer() {
+	if s.authTimer != nil {
+		s.authTimer.Stop()
+		s.authTimer = nil
+	}
+	s.warned = false
+	if s.principal.ExpiresAt.IsZero() {
+		return
+	}
+	warnIn := time.Until(s.principal.ExpiresAt) - realtimeAuthWarning
+	if warnIn <= 0 {
+		s.warned = true
+		s.authTimer = time.NewTimer(time.Until(s.principal.ExpiresAt))
+		return
+	}
+	s.authTimer = time.NewTimer(warnIn)
+}
+
+// deliver sends an event if the connection is subscribed to it.
+func (s *realtimeSession) deliver(event stream.Event) bool {
+	channel, ok := s.subs.Route(s.principal.UserID, event)
+	if !ok {
+		return true
+	}
+	return s.send(realtime.ServerMessage{Type: realtime.TypeEvent, Channel: channel, Event: &event})
+}
+
+func (s *realtimeSession) replyError(msg realtime.ClientMessage, reason string) bool {
+	return s.send(realtime.ServerMessage{ID: msg.ID, Type: realtime.TypeError, Error: reason})
+}
+
+// send writes a message, giving up on clients that don't read it in time.
+func (s *realtimeSession) send(msg realtime.ServerMessage) bool {
+	s.conn.SetWriteDeadline(time.Now().Add(realtimeWriteWait))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		if !errors.Is(err, websocket.ErrCloseSent) {
+			log.Printf("error writing to websocket: %v\n", err)
+		}
+		return false
+	}
+	return true
+}
+
+func (s *realtimeSession) close(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	s.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(realtimeWriteWait))
+}
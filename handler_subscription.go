@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/entitlements"
+)
+
+type SubscriptionResponse struct {
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	Active           bool      `json:"active"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+func newSubscriptionResponse(sub database.Subscription) SubscriptionResponse {
+	return SubscriptionResponse{
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		Active:           toSubscription(sub).Active(time.Now().UTC()),
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		ExpiresAt:        sub.ExpiresAt,
+	}
+}
+
+type SubscriptionEventResponse struct {
+	CreatedAt        time.Time `json:"created_at"`
+	Event            string    `json:"event"`
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// handlerGetSubscription returns the caller's subscription, or null if they
+// have never subscribed, together with every change made to it.
+func (cfg *apiConfig) handlerGetSubscription(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	res := struct {
+		Subscription *SubscriptionResponse       `json:"subscription"`
+		Entitlements entitlements.Entitlements   `json:"entitlements"`
+		History      []SubscriptionEventResponse `json:"history"`
+	}{History: []SubscriptionEventResponse{}}
+
+	sub, err := cfg.dbQueries.GetSubscription(req.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("error retrieving subscription: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if err == nil {
+		subRes := newSubscriptionResponse(sub)
+		res.Subscription = &subRes
+	}
+
+	res.Entitlements, err = cfg.entitlementsFor(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving entitlements: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if res.Entitlements.Badges == nil {
+		res.Entitlements.Badges = []string{}
+	}
+
+	events, err := cfg.dbQueries.GetSubscriptionEvents(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving subscription history: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	for _, event := range events {
+		res.History = append(res.History, SubscriptionEventResponse{
+			CreatedAt:        event.CreatedAt,
+			Event:            event.Event,
+			Plan:             event.Plan,
+			Status:           event.Status,
+			CurrentPeriodEnd: event.CurrentPeriodEnd,
+			ExpiresAt:        event.ExpiresAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
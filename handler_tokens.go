@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+type PersonalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func newPersonalAccessTokenResponse(pat database.PersonalAccessToken) PersonalAccessTokenResponse {
+	res := PersonalAccessTokenResponse{
+		ID:        pat.ID,
+		CreatedAt: pat.CreatedAt,
+		Name:      pat.Name,
+		Scope:     pat.Scope,
+	}
+	if pat.ExpiresAt.Valid {
+		res.ExpiresAt = &pat.ExpiresAt.Time
+	}
+	if pat.LastUsedAt.Valid {
+		res.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return res
+}
+
+// handlerCreateToken issues a personal access token for scripts and bots.
+// Only a hash is stored, so the token is returned in this response and
+// never again. The new token is limited to the caller's own scopes, and
+// when the caller is itself a token, to its lifetime.
+func (cfg *apiConfig) handlerCreateToken(w http.ResponseWriter, req *http.Request) {
+	principal := requestPrincipal(req)
+
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Name      string     `json:"name"`
+		Scope     string     `json:"scope"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var fieldErrors []FieldError
+	if reqJSON.Name == "" || len(reqJSON.Name) > 100 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "name", Rule: "length", Message: "name must be 1-100 characters"})
+	}
+	scopes, err := auth.ParseScope(reqJSON.Scope)
+	if err != nil || len(scopes) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "scope", Rule: "scope", Message: "scope must be one or more of read, write, follow and admin"})
+	} else if !auth.ScopeSubset(scopes, principal.Scopes) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "scope", Rule: "scope", Message: "a token cannot be given scopes its creator does not have"})
+	}
+	var expiresAt sql.NullTime
+	if reqJSON.ExpiresAt != nil {
+		if !reqJSON.ExpiresAt.After(time.Now()) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "expires_at", Rule: "future", Message: "expires_at must be in the future"})
+		}
+		expiresAt = sql.NullTime{Time: reqJSON.ExpiresAt.UTC(), Valid: true}
+	}
+	// A token cannot be used to mint one that outlives it.
+	if principal.Method != AuthMethodSession && !principal.ExpiresAt.IsZero() {
+		if !expiresAt.Valid || expiresAt.Time.After(principal.ExpiresAt) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "expires_at", Rule: "max", Message: "expires_at cannot be later than the expiry of the token creating it"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "invalid token", fieldErrors)
+		return
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		log.Printf("error creating personal access token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	pat, err := cfg.dbQueries.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    principal.UserID,
+		Name:      reqJSON.Name,
+		TokenHash: auth.HashToken(token),
+		Scope:     auth.FormatScope(scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("error storing personal access token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	res := newPersonalAccessTokenResponse(pat)
+	res.Token = token
+	respondWithJSON(w, http.StatusCreated, res)
+}
+
+func (cfg *apiConfig) handlerGetTokens(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	pats, err := cfg.dbQueries.GetPersonalAccessTokensByUserID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving personal access tokens: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []PersonalAccessTokenResponse{}
+	for _, pat := range pats {
+		res = append(res, newPersonalAccessTokenResponse(pat))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) handlerDeleteToken(w http.ResponseWriter, req *http.Request) {
+	tokenID, err := uuid.Parse(req.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid token id")
+		return
+	}
+	userID := requestPrincipal(req).UserID
+
+	deleted, err := cfg.dbQueries.DeletePersonalAccessToken(req.Context(), database.DeletePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("error deleting personal access token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
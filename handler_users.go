@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -17,11 +18,43 @@ type UserRequest struct {
 }
 
 type UserResponse struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
+	Username      *string   `json:"username"`
+	DisplayName   *string   `json:"display_name"`
+	Bio           *string   `json:"bio"`
+	Location      *string   `json:"location"`
+	Website       *string   `json:"website"`
+
+	Subscription *SubscriptionResponse `json:"subscription,omitempty"`
+	Badges       []string              `json:"badges,omitempty"`
+}
+
+func newUserResponse(user database.User) UserResponse {
+	return UserResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerified,
+		Username:      nullStringPtr(user.Username),
+		DisplayName:   nullStringPtr(user.DisplayName),
+		Bio:           nullStringPtr(user.Bio),
+		Location:      nullStringPtr(user.Location),
+		Website:       nullStringPtr(user.Website),
+	}
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
 }
 
 func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request) {
@@ -34,6 +67,10 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request
 		respondWithError(w, http.StatusInternalServerError, "")
 		return
 	}
+	if fieldErrors := cfg.checkPasswordPolicy(reqJSON.Password); len(fieldErrors) > 0 {
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "password does not meet requirements", fieldErrors)
+		return
+	}
 	hashedPassword, err := auth.HashPassword(reqJSON.Password)
 	if err != nil {
 		log.Printf("error hashing password")
@@ -50,102 +87,90 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, req *http.Request
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, UserResponse{
-		ID:          userData.ID,
-		CreatedAt:   userData.CreatedAt,
-		UpdatedAt:   userData.UpdatedAt,
-		Email:       userData.Email,
-		IsChirpyRed: userData.IsChirpyRed,
-	})
+	if err := cfg.sendVerificationEmail(req.Context(), userData); err != nil {
+		log.Printf("error sending verification email: %v\n", err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, newUserResponse(userData))
 }
 
-func (cfg *apiConfig) handlerUserUpdate(w http.ResponseWriter, req *http.Request) {
+// handlerPasswordChangeDeprecated serves PUT /api/users, which used to
+// update the email and password in one go, as an alias of
+// PUT /api/users/me/password. Email changes have to be confirmed now.
+func (cfg *apiConfig) handlerPasswordChangeDeprecated(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", `</api/users/me/password>; rel="successor-version"`)
+	cfg.handlerPasswordChange(w, req)
+}
+
+// handlerPasswordChange requires the current password so that a stolen
+// access token alone cannot be used to take over the account. All refresh
+// tokens, OAuth grants and personal access tokens are revoked afterwards.
+func (cfg *apiConfig) handlerPasswordChange(w http.ResponseWriter, req *http.Request) {
 	decoder := json.NewDecoder(req.Body)
-	var reqJSON UserRequest
+	var reqJSON struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
 	if err := decoder.Decode(&reqJSON); err != nil {
 		log.Printf("error decoding request: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "")
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-	accessToken, err := auth.GetBearerToken(req.Header)
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
 	if err != nil {
-		log.Printf("error getting token: %v\n", err)
+		log.Printf("error retrieving user: %v\n", err)
 		respondWithError(w, http.StatusUnauthorized, "")
 		return
 	}
-	userID, err := auth.ValidateJWT(accessToken, cfg.secretString)
-	if err != nil {
-		log.Printf("error validating JWT: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "")
+	if err := cfg.checkCurrentPassword(req.Context(), user, reqJSON.CurrentPassword); err != nil {
+		respondWithPasswordError(w, err)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(reqJSON.Password)
-	if err != nil {
-		log.Printf("error hashing password: %v\n", err)
-		respondWithError(w, http.StatusInternalServerError, "")
+	if fieldErrors := cfg.checkPasswordPolicy(reqJSON.NewPassword); len(fieldErrors) > 0 {
+		for i := range fieldErrors {
+			fieldErrors[i].Field = "new_password"
+		}
+		respondWithFieldErrors(w, http.StatusUnprocessableEntity, "password does not meet requirements", fieldErrors)
 		return
 	}
 
-	userInfo, err := cfg.dbQueries.UpdatePasswordEmail(req.Context(), database.UpdatePasswordEmailParams{
-		ID:             userID,
-		Email:          reqJSON.Email,
-		HashedPassword: hashedPassword,
-	})
+	hashedPassword, err := auth.HashPassword(reqJSON.NewPassword)
 	if err != nil {
-		log.Printf("error updating password or email: %v\n", err)
+		log.Printf("error hashing password: %v\n", err)
 		respondWithError(w, http.StatusInternalServerError, "")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, UserResponse{
-		ID:          userInfo.ID,
-		CreatedAt:   userInfo.CreatedAt,
-		UpdatedAt:   userInfo.UpdatedAt,
-		Email:       userInfo.Email,
-		IsChirpyRed: userInfo.IsChirpyRed,
+	err = cfg.withTx(req.Context(), func(q *database.Queries) error {
+		err := q.UpdatePassword(req.Context(), database.UpdatePasswordParams{
+			ID:             user.ID,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+		return revokeUserCredentials(req.Context(), q, user.ID)
 	})
-
-}
-
-func (cfg *apiConfig) handlerUpgrade(w http.ResponseWriter, req *http.Request) {
-
-	polkaKey, err := auth.GetAPIKey(req.Header)
 	if err != nil {
-		log.Printf("error retrieving key: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "")
-		return
-	}
-	if polkaKey != cfg.polkaApiKey {
-		log.Printf("mismatched api key: %v\n", err)
-		respondWithError(w, http.StatusUnauthorized, "")
-		return
-	}
-
-	decoder := json.NewDecoder(req.Body)
-	var reqJSON struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
-	}
-
-	if err := decoder.Decode(&reqJSON); err != nil {
-		log.Printf("error decoding request: %v\n", err)
+		log.Printf("error updating password: %v\n", err)
 		respondWithError(w, http.StatusInternalServerError, "")
 		return
 	}
-
-	if reqJSON.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	if err := cfg.dbQueries.UpgradeUserByID(req.Context(), reqJSON.Data.UserID); err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
 	w.WriteHeader(http.StatusNoContent)
+}
 
+func (cfg *apiConfig) checkPasswordPolicy(password string) []FieldError {
+	var fieldErrors []FieldError
+	for _, violation := range cfg.passwordPolicy.Check(password) {
+		fieldErrors = append(fieldErrors, FieldError{
+			Field:   "password",
+			Rule:    violation.Rule,
+			Message: violation.Message,
+		})
+	}
+	return fieldErrors
 }
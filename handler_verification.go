@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/mailer"
+)
+
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token, err := cfg.issueOneTimeToken(ctx, user.ID, auth.PurposeVerifyEmail, verifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Confirm that this is your email address by opening the link below:\n\n%s\n\nThe link expires in 24 hours.\n",
+			cfg.linkURL("/app/verify-email", token)),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmailRequest(w http.ResponseWriter, req *http.Request) {
+	userID := requestPrincipal(req).UserID
+
+	user, err := cfg.dbQueries.GetUserByID(req.Context(), userID)
+	if err != nil {
+		log.Printf("error retrieving user: %v\n", err)
+		respondWithError(w, http.StatusUnauthorized, "")
+		return
+	}
+	if user.EmailVerified {
+		respondWithError(w, http.StatusConflict, "email is already verified")
+		return
+	}
+
+	if err := cfg.sendVerificationEmail(req.Context(), user); err != nil {
+		log.Printf("error sending verification email: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "could not send verification email")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlerVerifyEmailConfirm(w http.ResponseWriter, req *http.Request) {
+	decoder := json.NewDecoder(req.Body)
+	var reqJSON struct {
+		Token string `json:"token"`
+	}
+	if err := decoder.Decode(&reqJSON); err != nil {
+		log.Printf("error decoding request: %v\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	userID, err := cfg.redeemOneTimeToken(req.Context(), reqJSON.Token, auth.PurposeVerifyEmail)
+	if errors.Is(err, errInvalidOneTimeToken) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("error redeeming verification token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	if err := cfg.dbQueries.SetEmailVerified(req.Context(), userID); err != nil {
+		log.Printf("error verifying email: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+const (
+	defaultWebhookEventLimit = 50
+	maxWebhookEventLimit     = 500
+)
+
+type WebhookEventResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	Error       string          `json:"error,omitempty"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+func newWebhookEventResponse(event database.WebhookEvent) WebhookEventResponse {
+	res := WebhookEventResponse{
+		ID:        event.ID,
+		CreatedAt: event.CreatedAt,
+		UpdatedAt: event.UpdatedAt,
+		Provider:  event.Provider,
+		EventID:   event.EventID,
+		EventType: event.EventType,
+		Status:    event.Status,
+		Attempts:  event.Attempts,
+		Error:     event.Error.String,
+		Payload:   event.Payload,
+	}
+	if event.ProcessedAt.Valid {
+		res.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return res
+}
+
+// handlerGetWebhookEvents lists stored webhook events, newest first. The
+// provider and status query parameters narrow the list.
+func (cfg *apiConfig) handlerGetWebhookEvents(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	limit := defaultWebhookEventLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxWebhookEventLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	events, err := cfg.dbQueries.ListWebhookEvents(req.Context(), database.ListWebhookEventsParams{
+		Provider:   query.Get("provider"),
+		Status:     query.Get("status"),
+		MaxResults: int32(limit),
+	})
+	if err != nil {
+		log.Printf("error retrieving webhook events: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	res := []WebhookEventResponse{}
+	for _, event := range events {
+		res = append(res, newWebhookEventResponse(event))
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) handlerGetWebhookEvent(w http.ResponseWriter, req *http.Request) {
+	eventID, err := uuid.Parse(req.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	event, err := cfg.dbQueries.GetWebhookEvent(req.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "event not found")
+		return
+	}
+	if err != nil {
+		log.Printf("error retrieving webhook event: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}
+
+// handlerReplayWebhookEvent applies a stored event that has not been
+// applied yet, such as one that failed. Events that were processed or
+// ignored are refused: applying a renewal or downgrade twice is not
+// idempotent.
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, req *http.Request) {
+	eventID, err := uuid.Parse(req.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid event id")
+		return
+	}
+	event, err := cfg.dbQueries.GetWebhookEvent(req.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "event not found")
+		return
+	}
+	if err != nil {
+		log.Printf("error retrieving webhook event: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+	if event.Provider != webhookProviderPolka {
+		respondWithError(w, http.StatusUnprocessableEntity, "unsupported webhook provider")
+		return
+	}
+
+	event, err = cfg.dbQueries.ClaimWebhookEventForReplay(req.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "event was already applied or is being processed")
+		return
+	}
+	if err != nil {
+		log.Printf("error claiming webhook event: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "")
+		return
+	}
+
+	// The outcome is recorded on the event, so a failed replay is still a
+	// successful request.
+	event, _ = cfg.processPolkaEvent(req.Context(), event)
+	respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}
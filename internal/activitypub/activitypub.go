@@ -0,0 +1,149 @@
+// Package activitypub federates Chirpy accounts with the fediverse. It
+// holds the ActivityStreams vocabulary Chirpy uses, HTTP Signatures,
+// fetching remote actors, delivering activities and handling the Follow
+// and Undo activities sent to a user's inbox.
+package activitypub
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of ActivityPub documents.
+const ContentType = "application/activity+json"
+
+// Public addresses an activity to everyone.
+const Public = "https://www.w3.org/ns/activitystreams#Public"
+
+// Context is the JSON-LD context of every top-level document.
+var Context = []string{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+// DeliveryInbox is where activities for the actor's followers should go:
+// the shared inbox of their server if it has one.
+func (a Actor) DeliveryInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+type Attachment struct {
+	Type      string `json:"type"`
+	MediaType string `json:"mediaType,omitempty"`
+	URL       string `json:"url"`
+}
+
+type Note struct {
+	Context      any          `json:"@context,omitempty"`
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	AttributedTo string       `json:"attributedTo"`
+	InReplyTo    string       `json:"inReplyTo,omitempty"`
+	Content      string       `json:"content"`
+	Published    time.Time    `json:"published"`
+	Updated      *time.Time   `json:"updated,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	To           []string     `json:"to"`
+	Cc           []string     `json:"cc,omitempty"`
+	Attachment   []Attachment `json:"attachment,omitempty"`
+}
+
+// Tombstone replaces a deleted object.
+type Tombstone struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type Activity struct {
+	Context any             `json:"@context,omitempty"`
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Actor   string          `json:"actor"`
+	Object  json.RawMessage `json:"object"`
+	To      []string        `json:"to,omitempty"`
+	Cc      []string        `json:"cc,omitempty"`
+}
+
+// NewActivity returns an activity of actor's wrapping object.
+func NewActivity(id, activityType, actor string, object any) (Activity, error) {
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return Activity{}, err
+	}
+	return Activity{Context: Context, ID: id, Type: activityType, Actor: actor, Object: raw}, nil
+}
+
+// ObjectID returns the id of the activity's object, which may be embedded
+// or given only by its id.
+func (a Activity) ObjectID() string {
+	var id string
+	if json.Unmarshal(a.Object, &id) == nil {
+		return id
+	}
+	var object struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(a.Object, &object)
+	return object.ID
+}
+
+// ObjectType returns the type of an embedded object, or "" if the object
+// is only given by its id.
+func (a Activity) ObjectType() string {
+	var object struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(a.Object, &object) != nil {
+		return ""
+	}
+	return object.Type
+}
+
+type OrderedCollection struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   int    `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems,omitempty"`
+}
+
+// Content turns plain text into the HTML that Note content holds.
+func Content(text string) string {
+	var b strings.Builder
+	b.WriteString("<p>")
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteString("<br>")
+		}
+		b.WriteString(html.EscapeString(line))
+	}
+	b.WriteString("</p>")
+	return b.String()
+}
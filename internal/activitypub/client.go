@@ -0,0 +1,158 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/safehttp"
+)
+
+// maxDocumentBytes caps the size of documents fetched from other servers.
+const maxDocumentBytes = 1 << 20
+
+var ErrInsecureURL = errors.New("activitypub: remote URLs must use https")
+
+// StatusError is returned when a remote server answered with a status
+// outside 2xx.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("activitypub: remote server responded with %d", e.StatusCode)
+}
+
+// Signer is the local actor key that outgoing requests are signed with.
+type Signer struct {
+	KeyID string
+	Key   *rsa.PrivateKey
+}
+
+// Client talks to other servers. Any server can make it fetch a URL by
+// sending an activity, so only https URLs are used, and without HTTP set
+// it only connects to public addresses and does not follow redirects; an
+// https URL alone does not keep requests off the server's own network.
+type Client struct {
+	// HTTP is the client requests are made with. It defaults to one from
+	// safehttp.NewClient, which a replacement should also be unless it is
+	// only used in tests.
+	HTTP      *http.Client
+	UserAgent string
+	Now       func() time.Time
+}
+
+// defaultHTTP is used by Clients without HTTP set.
+var defaultHTTP = safehttp.NewClient(10 * time.Second)
+
+func (c *Client) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return nil, ErrInsecureURL
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = defaultHTTP
+	}
+	return client.Do(req)
+}
+
+// FetchActor retrieves a remote actor. The document must have the id it
+// was fetched from, so that one server cannot speak for another's actors.
+func (c *Client) FetchActor(ctx context.Context, actorID string) (Actor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, actorID, nil)
+	if err != nil {
+		return Actor{}, err
+	}
+	req.Header.Set("Accept", ContentType)
+	resp, err := c.do(req)
+	if err != nil {
+		return Actor{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Actor{}, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentBytes)).Decode(&actor); err != nil {
+		return Actor{}, fmt.Errorf("activitypub: decoding actor: %w", err)
+	}
+	if actor.ID != actorID || actor.Inbox == "" {
+		return Actor{}, fmt.Errorf("activitypub: %s is not a valid actor", actorID)
+	}
+	return actor, nil
+}
+
+// Deliver posts a signed activity to an inbox.
+func (c *Client) Deliver(ctx context.Context, inbox string, activity any, signer Signer) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if err := Sign(req, body, signer.KeyID, signer.Key, c.now()); err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// DeliverAll posts an activity to each distinct inbox, carrying on past
+// failures and returning them together.
+func (c *Client) DeliverAll(ctx context.Context, inboxes []string, activity any, signer Signer) error {
+	seen := map[string]bool{}
+	var errs []error
+	for _, inbox := range inboxes {
+		if seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		if err := c.Deliver(ctx, inbox, activity, signer); err != nil {
+			errs = append(errs, fmt.Errorf("delivering to %s: %w", inbox, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SameHost reports whether two URLs are on the same server.
+func SameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && ua.Host == ub.Host
+}
@@ -0,0 +1,305 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/safehttp"
+)
+
+// fakeRemote is another fediverse server with two actors: bob, and eve,
+// whose inbox is on a different server. It checks the signature of
+// everything delivered to it.
+type fakeRemote struct {
+	srv       *httptest.Server
+	bob       Actor
+	bobSigner Signer
+	eve       Actor
+	eveSigner Signer
+	localKey  *rsa.PublicKey
+
+	mu       sync.Mutex
+	received []delivery
+}
+
+type delivery struct {
+	path     string
+	activity Activity
+	err      error
+}
+
+func newFakeRemote(t *testing.T, localKeyID string, localKey *rsa.PublicKey) *fakeRemote {
+	t.Helper()
+	r := &fakeRemote{localKey: localKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/bob", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(r.bob)
+	})
+	mux.HandleFunc("GET /users/eve", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(r.eve)
+	})
+	receive := func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		_, err := Verify(req, body, time.Now(), func(keyID string) (*rsa.PublicKey, error) {
+			if keyID != localKeyID {
+				return nil, errors.New("unknown key")
+			}
+			return r.localKey, nil
+		})
+		var activity Activity
+		json.Unmarshal(body, &activity)
+		r.mu.Lock()
+		r.received = append(r.received, delivery{path: req.URL.Path, activity: activity, err: err})
+		r.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}
+	mux.HandleFunc("POST /users/bob/inbox", receive)
+	mux.HandleFunc("POST /inbox", receive)
+	r.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(r.srv.Close)
+
+	key := testKey(t)
+	pub, err := EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobID := r.srv.URL + "/users/bob"
+	r.bob = Actor{
+		Context:           Context,
+		ID:                bobID,
+		Type:              "Person",
+		PreferredUsername: "bob",
+		Inbox:             bobID + "/inbox",
+		Endpoints:         &Endpoints{SharedInbox: r.srv.URL + "/inbox"},
+		PublicKey:         PublicKey{ID: bobID + "#main-key", Owner: bobID, PublicKeyPem: pub},
+	}
+	r.bobSigner = Signer{KeyID: r.bob.PublicKey.ID, Key: key}
+
+	eveID := r.srv.URL + "/users/eve"
+	r.eve = Actor{
+		Context:   Context,
+		ID:        eveID,
+		Type:      "Person",
+		Inbox:     "https://internal.example/inbox",
+		Endpoints: &Endpoints{SharedInbox: r.srv.URL + "/inbox"},
+		PublicKey: PublicKey{ID: eveID + "#main-key", Owner: eveID, PublicKeyPem: pub},
+	}
+	r.eveSigner = Signer{KeyID: r.eve.PublicKey.ID, Key: key}
+	return r
+}
+
+func (r *fakeRemote) deliveries() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.received...)
+}
+
+type memStore struct {
+	mu        sync.Mutex
+	followers map[uuid.UUID]map[string]Follower
+}
+
+func (s *memStore) AddFollower(ctx context.Context, userID uuid.UUID, follower Follower) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.followers == nil {
+		s.followers = map[uuid.UUID]map[string]Follower{}
+	}
+	if s.followers[userID] == nil {
+		s.followers[userID] = map[string]Follower{}
+	}
+	s.followers[userID][follower.ActorID] = follower
+	return nil
+}
+
+func (s *memStore) RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.followers[userID], actorID)
+	return nil
+}
+
+func (s *memStore) inboxes(userID uuid.UUID) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var inboxes []string
+	for _, f := range s.followers[userID] {
+		inboxes = append(inboxes, f.Inbox)
+	}
+	return inboxes
+}
+
+type federationTest struct {
+	remote *fakeRemote
+	local  Local
+	store  *memStore
+	inbox  *Inbox
+}
+
+func newFederationTest(t *testing.T) *federationTest {
+	t.Helper()
+	userID := uuid.New()
+	actorID := "https://chirpy.test/ap/users/" + userID.String()
+	key := testKey(t)
+	local := Local{UserID: userID, ActorID: actorID, Signer: Signer{KeyID: actorID + "#main-key", Key: key}}
+
+	remote := newFakeRemote(t, local.Signer.KeyID, &key.PublicKey)
+	store := &memStore{}
+	return &federationTest{
+		remote: remote,
+		local:  local,
+		store:  store,
+		inbox:  &Inbox{Client: &Client{HTTP: remote.srv.Client()}, Store: store},
+	}
+}
+
+// post signs an activity with signer and hands it to the local inbox.
+func (ft *federationTest) post(t *testing.T, activity any, signer Signer, signedAt time.Time) error {
+	t.Helper()
+	body, err := json.Marshal(activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, ft.local.ActorID+"/inbox", bytes.NewReader(body))
+	if err := Sign(req, body, signer.KeyID, signer.Key, signedAt); err != nil {
+		t.Fatal(err)
+	}
+	return ft.inbox.Receive(context.Background(), req, body, ft.local)
+}
+
+func (ft *federationTest) follow(t *testing.T) Activity {
+	t.Helper()
+	follow, err := NewActivity(ft.remote.bob.ID+"/follows/1", TypeFollow, ft.remote.bob.ID, ft.local.ActorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return follow
+}
+
+func TestFollowAcceptUndo(t *testing.T) {
+	ft := newFederationTest(t)
+	follow := ft.follow(t)
+
+	if err := ft.post(t, follow, ft.remote.bobSigner, time.Now()); err != nil {
+		t.Fatalf("Follow: Receive() error = %v", err)
+	}
+	if got := ft.store.inboxes(ft.local.UserID); len(got) != 1 || got[0] != ft.remote.srv.URL+"/inbox" {
+		t.Fatalf("follower inboxes = %v, want the shared inbox", got)
+	}
+	received := ft.remote.deliveries()
+	if len(received) != 1 {
+		t.Fatalf("remote received %d activities, want an Accept", len(received))
+	}
+	accept := received[0]
+	if accept.err != nil {
+		t.Errorf("Accept signature: %v", accept.err)
+	}
+	if accept.path != "/users/bob/inbox" || accept.activity.Type != TypeAccept || accept.activity.Actor != ft.local.ActorID {
+		t.Errorf("Accept = %+v delivered to %s", accept.activity, accept.path)
+	}
+	if got := accept.activity.ObjectID(); got != follow.ID {
+		t.Errorf("Accept object = %s, want the Follow %s", got, follow.ID)
+	}
+
+	// A chirp posted now reaches bob's server.
+	note := Note{
+		ID:           "https://chirpy.test/ap/chirps/1",
+		Type:         "Note",
+		AttributedTo: ft.local.ActorID,
+		Content:      Content("hello <fediverse>"),
+		Published:    time.Now().UTC(),
+		To:           []string{Public},
+	}
+	create, err := NewActivity(note.ID+"/activity", TypeCreate, ft.local.ActorID, note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.inbox.Client.DeliverAll(context.Background(), ft.store.inboxes(ft.local.UserID), create, ft.local.Signer); err != nil {
+		t.Fatalf("DeliverAll() error = %v", err)
+	}
+	received = ft.remote.deliveries()
+	if len(received) != 2 || received[1].path != "/inbox" || received[1].activity.Type != TypeCreate || received[1].err != nil {
+		t.Fatalf("remote received %+v, want a signed Create at /inbox", received)
+	}
+	if got := received[1].activity.ObjectID(); got != note.ID {
+		t.Errorf("Create object = %s, want %s", got, note.ID)
+	}
+
+	undo, err := NewActivity(ft.remote.bob.ID+"/follows/1/undo", TypeUndo, ft.remote.bob.ID, follow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.post(t, undo, ft.remote.bobSigner, time.Now()); err != nil {
+		t.Fatalf("Undo: Receive() error = %v", err)
+	}
+	if got := ft.store.inboxes(ft.local.UserID); len(got) != 0 {
+		t.Errorf("follower inboxes after Undo = %v, want none", got)
+	}
+}
+
+func TestReceiveRejects(t *testing.T) {
+	ft := newFederationTest(t)
+	follow := ft.follow(t)
+	mallory := Signer{KeyID: ft.remote.bob.PublicKey.ID, Key: testKey(t)}
+
+	otherActor := follow
+	otherActor.Actor = ft.remote.srv.URL + "/users/carol"
+	otherObject, _ := NewActivity(follow.ID, TypeFollow, ft.remote.bob.ID, "https://chirpy.test/ap/users/someone-else")
+	insecure := Signer{KeyID: strings.Replace(ft.remote.bob.PublicKey.ID, "https://", "http://", 1), Key: ft.remote.bobSigner.Key}
+	elsewhere := Signer{KeyID: "https://internal.example/users/bob#main-key", Key: ft.remote.bobSigner.Key}
+	eveFollow, _ := NewActivity(ft.remote.eve.ID+"/follows/1", TypeFollow, ft.remote.eve.ID, ft.local.ActorID)
+
+	tests := []struct {
+		name     string
+		activity Activity
+		signer   Signer
+		signedAt time.Time
+		wantErr  error
+	}{
+		{name: "signed with another key", activity: follow, signer: mallory, signedAt: time.Now(), wantErr: ErrInvalidSignature},
+		{name: "stale signature", activity: follow, signer: ft.remote.bobSigner, signedAt: time.Now().Add(-MaxClockSkew - time.Minute), wantErr: ErrDateOutOfRange},
+		{name: "actor is not the signer", activity: otherActor, signer: ft.remote.bobSigner, signedAt: time.Now(), wantErr: ErrActorMismatch},
+		{name: "follow of another user", activity: otherObject, signer: ft.remote.bobSigner, signedAt: time.Now(), wantErr: ErrInvalidActivity},
+		{name: "plain http key", activity: follow, signer: insecure, signedAt: time.Now(), wantErr: ErrInsecureURL},
+		{name: "key on another server", activity: follow, signer: elsewhere, signedAt: time.Now(), wantErr: ErrActorMismatch},
+		{name: "inbox on another server", activity: eveFollow, signer: ft.remote.eveSigner, signedAt: time.Now(), wantErr: ErrInvalidActivity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ft.post(t, tt.activity, tt.signer, tt.signedAt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Receive() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := ft.store.inboxes(ft.local.UserID); len(got) != 0 {
+		t.Errorf("rejected activities added followers %v", got)
+	}
+	if got := ft.remote.deliveries(); len(got) != 0 {
+		t.Errorf("rejected activities were answered with %+v", got)
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	ft := newFederationTest(t)
+	// The fake remote listens on loopback, which only a Client with its
+	// own HTTP client may reach.
+	_, err := (&Client{}).FetchActor(context.Background(), ft.remote.bob.ID)
+	if !errors.Is(err, safehttp.ErrBlockedAddress) {
+		t.Errorf("FetchActor() error = %v, want safehttp.ErrBlockedAddress", err)
+	}
+}
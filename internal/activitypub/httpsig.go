@@ -0,0 +1,164 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HTTP Signatures as used across the fediverse: draft-cavage-http-signatures
+// with rsa-sha256, covering the request target, host and date, and the
+// Digest header of requests with a body.
+
+// MaxClockSkew is how far a signed request's Date may be from the current
+// time.
+const MaxClockSkew = 12 * time.Hour
+
+var (
+	ErrMissingSignature = errors.New("activitypub: missing signature")
+	ErrInvalidSignature = errors.New("activitypub: invalid signature")
+	ErrDateOutOfRange   = errors.New("activitypub: signature date outside tolerance")
+	ErrDigestMismatch   = errors.New("activitypub: digest does not match body")
+)
+
+// KeyLookup resolves a signature's keyId to the public key to check it
+// with.
+type KeyLookup func(keyID string) (*rsa.PublicKey, error)
+
+// Sign sets the Date header of req, and the Digest header if it has a
+// body, and signs them with key.
+func Sign(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey, now time.Time) error {
+	req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// Verify checks the signature of a request received at now, and returns
+// the keyId it was signed with. The signature must cover the request
+// target, host and date, and the digest of body for POST requests.
+func Verify(req *http.Request, body []byte, now time.Time, lookup KeyLookup) (string, error) {
+	params := parseSignature(req.Header.Get("Signature"))
+	keyID, encoded := params["keyId"], params["signature"]
+	if keyID == "" || encoded == "" {
+		return "", ErrMissingSignature
+	}
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return "", ErrInvalidSignature
+	}
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	required := []string{"(request-target)", "host", "date"}
+	if req.Method == http.MethodPost {
+		required = append(required, "digest")
+	}
+	for _, name := range required {
+		if !slices.Contains(headers, name) {
+			return "", ErrInvalidSignature
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if skew := now.Sub(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return "", ErrDateOutOfRange
+	}
+	if slices.Contains(headers, "digest") && !digestMatches(req.Header.Get("Digest"), body) {
+		return "", ErrDigestMismatch
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	key, err := lookup(keyID)
+	if err != nil {
+		return "", err
+	}
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature) != nil {
+		return "", ErrInvalidSignature
+	}
+	return keyID, nil
+}
+
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		var value string
+		switch name {
+		case "(request-target)":
+			value = strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		default:
+			value = strings.Join(req.Header.Values(name), ", ")
+		}
+		lines = append(lines, name+": "+value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSignature splits a Signature header into its parameters.
+func parseSignature(header string) map[string]string {
+	params := map[string]string{}
+	for header != "" {
+		name, rest, ok := strings.Cut(header, "=")
+		if !ok || !strings.HasPrefix(rest, `"`) {
+			break
+		}
+		value, rest, ok := strings.Cut(rest[1:], `"`)
+		if !ok {
+			break
+		}
+		params[strings.TrimSpace(name)] = value
+		header = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return params
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// digestMatches reports whether one of the SHA-256 digests in header is
+// that of body.
+func digestMatches(header string, body []byte) bool {
+	want := digest(body)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		alg, _, _ := strings.Cut(candidate, "=")
+		if strings.EqualFold(alg, "SHA-256") {
+			candidate = "SHA-256" + candidate[len(alg):]
+			if subtle.ConstantTimeCompare([]byte(candidate), []byte(want)) == 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
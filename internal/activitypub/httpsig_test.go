@@ -0,0 +1,108 @@
+package activitypub
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyEncoding(t *testing.T) {
+	key := testKey(t)
+	private, err := EncodePrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParsePrivateKey(private)
+	if err != nil || !parsed.Equal(key) {
+		t.Fatalf("ParsePrivateKey() = %v, want the encoded key", err)
+	}
+	public, err := EncodePublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedPublic, err := ParsePublicKey(public)
+	if err != nil || !parsedPublic.Equal(&key.PublicKey) {
+		t.Fatalf("ParsePublicKey() = %v, want the encoded key", err)
+	}
+	if _, err := ParsePublicKey("not a key"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("ParsePublicKey(garbage) error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	key := testKey(t)
+	other := testKey(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"Follow"}`)
+	const keyID = "https://example.com/users/alice#main-key"
+
+	lookup := func(id string) (*rsa.PublicKey, error) {
+		if id != keyID {
+			return nil, errors.New("unknown key")
+		}
+		return &key.PublicKey, nil
+	}
+
+	tests := []struct {
+		name    string
+		prepare func(req *http.Request) []byte
+		at      time.Time
+		wantErr error
+	}{
+		{name: "valid", prepare: func(req *http.Request) []byte { return body }, at: now},
+		{name: "body changed", prepare: func(req *http.Request) []byte { return []byte(`{"type":"Undo"}`) }, at: now, wantErr: ErrDigestMismatch},
+		{name: "digest replaced", prepare: func(req *http.Request) []byte {
+			changed := []byte(`{"type":"Undo"}`)
+			req.Header.Set("Digest", digest(changed))
+			return changed
+		}, at: now, wantErr: ErrInvalidSignature},
+		{name: "path changed", prepare: func(req *http.Request) []byte {
+			req.URL.Path = "/users/bob/inbox"
+			return body
+		}, at: now, wantErr: ErrInvalidSignature},
+		{name: "clock too far ahead", prepare: func(req *http.Request) []byte { return body }, at: now.Add(MaxClockSkew + time.Minute), wantErr: ErrDateOutOfRange},
+		{name: "missing signature", prepare: func(req *http.Request) []byte {
+			req.Header.Del("Signature")
+			return body
+		}, at: now, wantErr: ErrMissingSignature},
+		{name: "digest not signed", prepare: func(req *http.Request) []byte {
+			req.Header.Set("Signature", strings.Replace(req.Header.Get("Signature"), " digest", "", 1))
+			return body
+		}, at: now, wantErr: ErrInvalidSignature},
+		{name: "signed by another key", prepare: func(req *http.Request) []byte {
+			if err := Sign(req, body, keyID, other, now); err != nil {
+				t.Fatal(err)
+			}
+			return body
+		}, at: now, wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://chirpy.test/ap/users/alice/inbox", nil)
+			if err := Sign(req, body, keyID, key, now); err != nil {
+				t.Fatal(err)
+			}
+			received := tt.prepare(req)
+			gotKeyID, err := Verify(req, received, tt.at, lookup)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && gotKeyID != keyID {
+				t.Errorf("Verify() keyID = %q, want %q", gotKeyID, keyID)
+			}
+		})
+	}
+}
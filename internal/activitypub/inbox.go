@@ -0,0 +1,132 @@
+package activitypub
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Activity types handled by the inbox.
+const (
+	TypeFollow = "Follow"
+	TypeAccept = "Accept"
+	TypeUndo   = "Undo"
+	TypeCreate = "Create"
+	TypeDelete = "Delete"
+)
+
+var (
+	ErrInvalidActivity = errors.New("activitypub: invalid activity")
+	// ErrActorMismatch is returned when an activity was signed by someone
+	// other than its actor.
+	ErrActorMismatch = errors.New("activitypub: activity not signed by its actor")
+	// ErrUnknownActor is returned when the actor whose key signed an
+	// activity could not be fetched.
+	ErrUnknownActor = errors.New("activitypub: could not fetch signing actor")
+)
+
+// Follower is a remote actor following a local user.
+type Follower struct {
+	ActorID string
+	// Inbox is where the user's activities are delivered for them.
+	Inbox string
+}
+
+// Store keeps the remote followers of local users.
+type Store interface {
+	// AddFollower records a follow, replacing any earlier one by the same
+	// actor.
+	AddFollower(ctx context.Context, userID uuid.UUID, follower Follower) error
+	RemoveFollower(ctx context.Context, userID uuid.UUID, actorID string) error
+}
+
+// Local is the local user whose inbox received an activity.
+type Local struct {
+	UserID  uuid.UUID
+	ActorID string
+	Signer  Signer
+}
+
+// Inbox handles activities delivered to local users' inboxes.
+type Inbox struct {
+	Client *Client
+	Store  Store
+}
+
+// Receive verifies that an activity posted to local's inbox was signed by
+// its actor, then acts on it. A Follow of local is accepted straight away,
+// and an Undo of one removes the follower. Other activities are ignored.
+func (in *Inbox) Receive(ctx context.Context, req *http.Request, body []byte, local Local) error {
+	var activity Activity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.ID == "" || activity.Actor == "" {
+		return ErrInvalidActivity
+	}
+
+	var actor Actor
+	_, err := Verify(req, body, in.Client.now(), func(keyID string) (*rsa.PublicKey, error) {
+		// The key is fetched before anything is known about the sender, so
+		// it must at least be on the server the activity claims to be from.
+		actorID, _, _ := strings.Cut(keyID, "#")
+		if !SameHost(actorID, activity.Actor) {
+			return nil, ErrActorMismatch
+		}
+		var err error
+		if actor, err = in.Client.FetchActor(ctx, actorID); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnknownActor, err)
+		}
+		if actor.PublicKey.ID != keyID {
+			return nil, ErrInvalidSignature
+		}
+		return ParsePublicKey(actor.PublicKey.PublicKeyPem)
+	})
+	if err != nil {
+		return err
+	}
+	if actor.ID != activity.Actor {
+		return ErrActorMismatch
+	}
+
+	switch activity.Type {
+	case TypeFollow:
+		if activity.ObjectID() != local.ActorID {
+			return ErrInvalidActivity
+		}
+		return in.follow(ctx, local, actor, activity)
+	case TypeUndo:
+		// The object may be the Follow itself or only its id; either way
+		// the actor can only undo their own follow.
+		if objectType := activity.ObjectType(); objectType != "" && objectType != TypeFollow {
+			return nil
+		}
+		return in.Store.RemoveFollower(ctx, local.UserID, actor.ID)
+	}
+	return nil
+}
+
+func (in *Inbox) follow(ctx context.Context, local Local, actor Actor, follow Activity) error {
+	// Both inboxes are written to, so neither may point at another server.
+	inbox := actor.DeliveryInbox()
+	if !SameHost(inbox, actor.ID) || !SameHost(actor.Inbox, actor.ID) {
+		return ErrInvalidActivity
+	}
+	if err := in.Store.AddFollower(ctx, local.UserID, Follower{ActorID: actor.ID, Inbox: inbox}); err != nil {
+		return err
+	}
+
+	follow.Context = nil
+	accept, err := NewActivity(local.ActorID+"#accepts/"+uuid.NewString(), TypeAccept, local.ActorID, follow)
+	if err != nil {
+		return err
+	}
+	accept.To = []string{actor.ID}
+	if err := in.Client.Deliver(ctx, actor.Inbox, accept, local.Signer); err != nil {
+		return fmt.Errorf("activitypub: accepting follow: %w", err)
+	}
+	return nil
+}
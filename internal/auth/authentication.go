@@ -4,12 +4,8 @@ import (
 	"errors"
 	"net/http"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-const costToHashPassword = 10
-
 func GetBearerToken(headers http.Header) (tokenString string, err error) {
 	authValue := headers.Get("Authorization")
 	if authValue == "" {
@@ -31,16 +27,19 @@ func HashPassword(password string) (string, error) {
 	if len(password) < 1 {
 		return "", errors.New("password too short")
 	}
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), costToHashPassword)
-	if err != nil {
-		return "", err
-	}
-	return string(passwordHash), nil
+	return hashArgon2id(password, CurrentArgon2Params)
 }
 
+// CheckPasswordHash verifies password against either an argon2id hash or a
+// legacy bcrypt hash. Callers should follow a successful check with
+// NeedsRehash to upgrade legacy hashes.
 func CheckPasswordHash(password, hash string) error {
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
-		return err
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return checkArgon2id(password, hash)
+	case isBcryptHash(hash):
+		return checkBcrypt(password, hash)
+	default:
+		return ErrUnknownHashFormat
 	}
-	return nil
 }
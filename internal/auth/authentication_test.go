@@ -3,8 +3,10 @@ package auth
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCheckPasswordHash(t *testing.T) {
@@ -13,6 +15,7 @@ func TestCheckPasswordHash(t *testing.T) {
 	password2 := "anotherPassword456!"
 	hash1, _ := HashPassword(password1)
 	hash2, _ := HashPassword(password2)
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte(password1), bcrypt.DefaultCost)
 
 	tests := []struct {
 		name     string
@@ -44,6 +47,18 @@ func TestCheckPasswordHash(t *testing.T) {
 			hash:     hash1,
 			wantErr:  true,
 		},
+		{
+			name:     "Correct password, legacy bcrypt hash",
+			password: password1,
+			hash:     string(legacyHash),
+			wantErr:  false,
+		},
+		{
+			name:     "Incorrect password, legacy bcrypt hash",
+			password: password2,
+			hash:     string(legacyHash),
+			wantErr:  true,
+		},
 		{
 			name:     "Invalid hash",
 			password: password1,
@@ -62,9 +77,41 @@ func TestCheckPasswordHash(t *testing.T) {
 	}
 }
 
+func TestNeedsRehash(t *testing.T) {
+	currentHash, _ := HashPassword("correctPassword123!")
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte("correctPassword123!"), bcrypt.DefaultCost)
+	weakParams := CurrentArgon2Params
+	weakParams.Iterations = 1
+	outdatedHash, _ := hashArgon2id("correctPassword123!", weakParams)
+
+	tests := []struct {
+		name string
+		hash string
+		want bool
+	}{
+		{name: "Current argon2id hash", hash: currentHash, want: false},
+		{name: "Legacy bcrypt hash", hash: string(legacyHash), want: true},
+		{name: "Outdated argon2id parameters", hash: outdatedHash, want: true},
+		{name: "Garbage", hash: "invalidhash", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := CheckPasswordHash("correctPassword123!", outdatedHash); err != nil {
+		t.Errorf("CheckPasswordHash() with outdated parameters: %v", err)
+	}
+}
+
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
 	validToken, _ := MakeJWT(userID, "secret")
+	mfaToken, _ := MakeMFAToken(userID, "secret")
 
 	tests := []struct {
 		name        string
@@ -94,6 +141,13 @@ func TestValidateJWT(t *testing.T) {
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
+		{
+			name:        "MFA challenge token",
+			tokenString: mfaToken,
+			tokenSecret: "secret",
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -110,6 +164,83 @@ func TestValidateJWT(t *testing.T) {
 	}
 }
 
+func TestValidateJWTWithExpiry(t *testing.T) {
+	userID := uuid.New()
+	before := time.Now().Add(time.Hour).Truncate(time.Second)
+	token, _ := MakeJWT(userID, "secret")
+
+	gotUserID, expiresAt, err := ValidateJWTWithExpiry(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateJWTWithExpiry() error = %v", err)
+	}
+	if gotUserID != userID {
+		t.Errorf("ValidateJWTWithExpiry() userID = %v, want %v", gotUserID, userID)
+	}
+	if expiresAt.Before(before) || expiresAt.After(before.Add(time.Minute)) {
+		t.Errorf("ValidateJWTWithExpiry() expiresAt = %v, want about an hour from now", expiresAt)
+	}
+
+	if _, _, err := ValidateJWTWithExpiry(token, "wrong_secret"); err == nil {
+		t.Errorf("ValidateJWTWithExpiry() accepted a token signed with another secret")
+	}
+}
+
+func TestValidateOneTimeToken(t *testing.T) {
+	userID := uuid.New()
+	tokenID := uuid.New()
+	resetToken, _ := MakeOneTimeToken(userID, tokenID, PurposeResetPassword, "secret", time.Hour)
+	expiredToken, _ := MakeOneTimeToken(userID, tokenID, PurposeResetPassword, "secret", -time.Minute)
+	accessToken, _ := MakeJWT(userID, "secret")
+
+	tests := []struct {
+		name        string
+		tokenString string
+		purpose     string
+		wantErr     bool
+	}{
+		{name: "Valid token", tokenString: resetToken, purpose: PurposeResetPassword},
+		{name: "Wrong purpose", tokenString: resetToken, purpose: PurposeVerifyEmail, wantErr: true},
+		{name: "Expired", tokenString: expiredToken, purpose: PurposeResetPassword, wantErr: true},
+		{name: "Access token", tokenString: accessToken, purpose: PurposeResetPassword, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, gotTokenID, err := ValidateOneTimeToken(tt.tokenString, tt.purpose, "secret")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateOneTimeToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (gotUserID != userID || gotTokenID != tokenID) {
+				t.Errorf("ValidateOneTimeToken() = %v, %v, want %v, %v", gotUserID, gotTokenID, userID, tokenID)
+			}
+		})
+	}
+}
+
+func TestValidateLoginStateToken(t *testing.T) {
+	state := LoginState{State: "state", Nonce: "nonce", CodeVerifier: "verifier"}
+	token, err := MakeLoginStateToken(state, "secret")
+	if err != nil {
+		t.Fatalf("MakeLoginStateToken() error = %v", err)
+	}
+
+	got, err := ValidateLoginStateToken(token, "secret")
+	if err != nil {
+		t.Fatalf("ValidateLoginStateToken() error = %v", err)
+	}
+	if got != state {
+		t.Errorf("ValidateLoginStateToken() = %+v, want %+v", got, state)
+	}
+
+	if _, err := ValidateLoginStateToken(token, "wrong"); err == nil {
+		t.Errorf("ValidateLoginStateToken() expected error for wrong secret")
+	}
+	accessToken, _ := MakeJWT(uuid.New(), "secret")
+	if _, err := ValidateLoginStateToken(accessToken, "secret"); err == nil {
+		t.Errorf("ValidateLoginStateToken() accepted an access token")
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 
 	cases := []struct {
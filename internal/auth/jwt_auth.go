@@ -9,12 +9,146 @@ import (
 	"github.com/google/uuid"
 )
 
+// Each kind of token gets its own issuer so that, for example, an MFA
+// challenge token can never be used as an access token.
+const (
+	accessTokenIssuer = "chirpy"
+	mfaTokenIssuer    = "chirpy-mfa"
+	loginStateIssuer  = "chirpy-login-state"
+
+	mfaTokenTTL   = 5 * time.Minute
+	loginStateTTL = 10 * time.Minute
+)
+
+// Purposes for MakeOneTimeToken. They are used as the token issuer.
+const (
+	PurposeVerifyEmail       = "chirpy-verify-email"
+	PurposeResetPassword     = "chirpy-reset-password"
+	PurposeChangeEmail       = "chirpy-change-email"
+	PurposeCancelEmailChange = "chirpy-cancel-email-change"
+)
+
 func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+	return makeJWT(userID, tokenSecret, accessTokenIssuer, time.Hour)
+}
+
+func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return validateJWT(tokenString, tokenSecret, accessTokenIssuer)
+}
+
+// ValidateJWTWithExpiry is ValidateJWT for callers that outlive a request,
+// such as long-lived connections, and need to know when the token expires.
+func ValidateJWTWithExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	claims, err := parseJWT(tokenString, tokenSecret, accessTokenIssuer)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return uuid.Nil, time.Time{}, errors.New("invalid token: no expiry")
+	}
+	return userID, claims.ExpiresAt.Time, nil
+}
+
+// MakeMFAToken issues the short-lived challenge token returned by a correct
+// password when the user has two-factor authentication enabled.
+func MakeMFAToken(userID uuid.UUID, tokenSecret string) (string, error) {
+	return makeJWT(userID, tokenSecret, mfaTokenIssuer, mfaTokenTTL)
+}
+
+func ValidateMFAToken(tokenString, tokenSecret string) (uuid.UUID, error) {
+	return validateJWT(tokenString, tokenSecret, mfaTokenIssuer)
+}
+
+// LoginState is what an external login has to remember between sending the
+// user to the provider and the provider's callback.
+type LoginState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type loginStateClaims struct {
+	jwt.RegisteredClaims
+	LoginState
+}
+
+// MakeLoginStateToken signs a LoginState so it can be kept in a cookie.
+func MakeLoginStateToken(state LoginState, tokenSecret string) (string, error) {
+	now := time.Now().UTC()
+	claims := loginStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    loginStateIssuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(loginStateTTL)),
+		},
+		LoginState: state,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+}
+
+func ValidateLoginStateToken(tokenString, tokenSecret string) (LoginState, error) {
+	claims := &loginStateClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(tokenSecret), nil
+	}, jwt.WithIssuer(loginStateIssuer))
+	if err != nil {
+		return LoginState{}, err
+	}
+	return claims.LoginState, nil
+}
+
+// MakeOneTimeToken issues a signed token for a link sent by email. tokenID
+// is stored by the caller so the token can only be redeemed once.
+func MakeOneTimeToken(userID, tokenID uuid.UUID, purpose, tokenSecret string, ttl time.Duration) (string, error) {
+	now := time.Now().UTC()
+	claims := jwt.RegisteredClaims{
+		Issuer:    purpose,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		Subject:   userID.String(),
+		ID:        tokenID.String(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+}
+
+// ValidateOneTimeToken checks the signature, expiry and purpose of a token
+// from MakeOneTimeToken. It does not check whether it was already used.
+func ValidateOneTimeToken(tokenString, purpose, tokenSecret string) (userID, tokenID uuid.UUID, err error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(tokenSecret), nil
+	}, jwt.WithIssuer(purpose))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	userID, err = uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	tokenID, err = uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userID, tokenID, nil
+}
+
+func makeJWT(userID uuid.UUID, tokenSecret, issuer string, ttl time.Duration) (string, error) {
 	now := time.Now().UTC()
 	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
+		Issuer:    issuer,
 		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		Subject:   userID.String(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -26,24 +160,27 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 	return signedToken, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+func validateJWT(tokenString, tokenSecret, issuer string) (uuid.UUID, error) {
+	claims, err := parseJWT(tokenString, tokenSecret, issuer)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(claims.Subject)
+}
+
+func parseJWT(tokenString, tokenSecret, issuer string) (*jwt.RegisteredClaims, error) {
 	claims := &jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithIssuer(issuer))
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 	if !token.Valid {
-		return uuid.Nil, errors.New("invalid token: expired")
-	}
-
-	userID, err := token.Claims.GetSubject()
-	if err != nil {
-		return uuid.Nil, err
+		return nil, errors.New("invalid token: expired")
 	}
-	return uuid.Parse(userID)
+	return claims, nil
 }
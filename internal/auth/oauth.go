@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Scopes that can be granted to third-party OAuth clients. First-party
+// access tokens from MakeJWT carry every scope.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeFollow = "follow"
+	ScopeAdmin  = "admin"
+)
+
+var knownScopes = []string{ScopeRead, ScopeWrite, ScopeFollow, ScopeAdmin}
+
+// AllScopes returns every scope, in canonical order.
+func AllScopes() []string {
+	return slices.Clone(knownScopes)
+}
+
+const oauthAccessTokenIssuer = "chirpy-oauth"
+
+var (
+	ErrUnknownScope        = errors.New("unknown scope")
+	ErrInvalidPKCE         = errors.New("code verifier does not match challenge")
+	ErrInvalidPKCEVerifier = errors.New("code verifier must be 43-128 unreserved characters")
+)
+
+// ParseScope splits a space-delimited OAuth scope string, rejecting unknown
+// scopes and dropping duplicates. The result is in canonical order.
+func ParseScope(scope string) ([]string, error) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(knownScopes, s) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownScope, s)
+		}
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	slices.SortFunc(scopes, func(a, b string) int {
+		return slices.Index(knownScopes, a) - slices.Index(knownScopes, b)
+	})
+	return scopes, nil
+}
+
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// ScopeSubset reports whether every scope in requested is also in allowed.
+func ScopeSubset(requested, allowed []string) bool {
+	for _, s := range requested {
+		if !slices.Contains(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthAccessToken is the validated content of a token issued to a
+// third-party client. TokenID identifies the grant in the database so the
+// token can be revoked before it expires.
+type OAuthAccessToken struct {
+	UserID    uuid.UUID
+	ClientID  uuid.UUID
+	TokenID   uuid.UUID
+	Scopes    []string
+	ExpiresAt time.Time
+}
+
+type oauthClaims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+}
+
+func MakeOAuthAccessToken(token OAuthAccessToken, tokenSecret string) (string, error) {
+	claims := oauthClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    oauthAccessTokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(token.ExpiresAt),
+			Subject:   token.UserID.String(),
+			ID:        token.TokenID.String(),
+		},
+		ClientID: token.ClientID.String(),
+		Scope:    FormatScope(token.Scopes),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+}
+
+// ValidateOAuthAccessToken checks the signature and expiry of a token from
+// MakeOAuthAccessToken. It does not check whether the grant was revoked.
+func ValidateOAuthAccessToken(tokenString, tokenSecret string) (OAuthAccessToken, error) {
+	claims := &oauthClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(tokenSecret), nil
+	}, jwt.WithIssuer(oauthAccessTokenIssuer))
+	if err != nil {
+		return OAuthAccessToken{}, err
+	}
+
+	var token OAuthAccessToken
+	if token.UserID, err = uuid.Parse(claims.Subject); err != nil {
+		return OAuthAccessToken{}, err
+	}
+	if token.ClientID, err = uuid.Parse(claims.ClientID); err != nil {
+		return OAuthAccessToken{}, err
+	}
+	if token.TokenID, err = uuid.Parse(claims.ID); err != nil {
+		return OAuthAccessToken{}, err
+	}
+	if token.Scopes, err = ParseScope(claims.Scope); err != nil {
+		return OAuthAccessToken{}, err
+	}
+	token.ExpiresAt = claims.ExpiresAt.Time
+	return token, nil
+}
+
+// VerifyPKCE checks a code verifier against an S256 code challenge as
+// described in RFC 7636. The plain method is not supported.
+func VerifyPKCE(verifier, challenge string) error {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return ErrInvalidPKCEVerifier
+	}
+	for _, c := range verifier {
+		if !isUnreserved(c) {
+			return ErrInvalidPKCEVerifier
+		}
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) != 1 {
+		return ErrInvalidPKCE
+	}
+	return nil
+}
+
+func isUnreserved(c rune) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// HashToken returns the value stored for a random opaque token such as an
+// authorization code, OAuth refresh token or client secret. The tokens are
+// high-entropy, so a fast hash is sufficient.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Empty", scope: "", want: nil},
+		{name: "Canonical order", scope: "admin read", want: []string{ScopeRead, ScopeAdmin}},
+		{name: "Duplicates dropped", scope: "write  write read", want: []string{ScopeRead, ScopeWrite}},
+		{name: "Unknown scope", scope: "read delete", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScope(tt.scope)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "abcdefghijklmnopqrstuvwxyz0123456789-._~ABCDEFG"
+	challenge := "TDp3C-wPo3aKDK1Bg-ukP78nZ9YiqUD2ToRD96HoaHU"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		wantErr   error
+	}{
+		{name: "Matching verifier", verifier: verifier, challenge: challenge},
+		{name: "Wrong verifier", verifier: strings.Repeat("a", 43), challenge: challenge, wantErr: ErrInvalidPKCE},
+		{name: "Too short", verifier: "short", challenge: challenge, wantErr: ErrInvalidPKCEVerifier},
+		{name: "Reserved characters", verifier: strings.Repeat("a", 42) + "/", challenge: challenge, wantErr: ErrInvalidPKCEVerifier},
+		{name: "Plain challenge rejected", verifier: verifier, challenge: verifier, wantErr: ErrInvalidPKCE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyPKCE(tt.verifier, tt.challenge); err != tt.wantErr {
+				t.Errorf("VerifyPKCE() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOAuthAccessToken(t *testing.T) {
+	want := OAuthAccessToken{
+		UserID:    uuid.New(),
+		ClientID:  uuid.New(),
+		TokenID:   uuid.New(),
+		Scopes:    []string{ScopeRead, ScopeWrite},
+		ExpiresAt: time.Now().Add(time.Hour).Truncate(time.Second),
+	}
+	tokenString, err := MakeOAuthAccessToken(want, "secret")
+	if err != nil {
+		t.Fatalf("MakeOAuthAccessToken() error = %v", err)
+	}
+
+	got, err := ValidateOAuthAccessToken(tokenString, "secret")
+	if err != nil {
+		t.Fatalf("ValidateOAuthAccessToken() error = %v", err)
+	}
+	if got.UserID != want.UserID || got.ClientID != want.ClientID || got.TokenID != want.TokenID ||
+		!slices.Equal(got.Scopes, want.Scopes) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Errorf("ValidateOAuthAccessToken() = %+v, want %+v", got, want)
+	}
+
+	if _, err := ValidateOAuthAccessToken(tokenString, "wrong"); err == nil {
+		t.Errorf("ValidateOAuthAccessToken() expected error for wrong secret")
+	}
+	if _, err := ValidateJWT(tokenString, "secret"); err == nil {
+		t.Errorf("ValidateJWT() accepted an OAuth access token")
+	}
+
+	accessToken, _ := MakeJWT(want.UserID, "secret")
+	if _, err := ValidateOAuthAccessToken(accessToken, "secret"); err == nil {
+		t.Errorf("ValidateOAuthAccessToken() accepted a first-party access token")
+	}
+}
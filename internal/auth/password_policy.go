@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule names reported in PolicyViolation.Rule.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleBanned    = "banned"
+	RuleBreached  = "breached"
+)
+
+type PolicyViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	Banned    map[string]bool
+	Breached  *BreachedPasswords
+}
+
+// Check returns every rule the password fails; an empty result means the
+// password is acceptable. Lengths are counted in characters, not bytes.
+func (p PasswordPolicy) Check(password string) []PolicyViolation {
+	var violations []PolicyViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("password must be at most %d characters", p.MaxLength),
+		})
+	}
+	if p.Banned[strings.ToLower(password)] {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleBanned,
+			Message: "password is too common",
+		})
+	}
+	if p.Breached != nil && p.Breached.Contains(password) {
+		violations = append(violations, PolicyViolation{
+			Rule:    RuleBreached,
+			Message: "password has appeared in a data breach",
+		})
+	}
+	return violations
+}
+
+const hashPrefixLength = 5
+
+// BreachedPasswords is an offline copy of a breached-password corpus keyed
+// the same way as a k-anonymity range API: SHA-1 digests are bucketed by
+// their first five hex characters and only the bucket is searched.
+type BreachedPasswords struct {
+	buckets map[string]map[string]bool
+}
+
+// LoadBreachedPasswords reads a corpus file with one upper- or lower-case
+// hex SHA-1 digest per line, optionally followed by ":<count>" as in the
+// Have I Been Pwned downloads. Blank lines and lines starting with # are
+// ignored.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := &BreachedPasswords{buckets: map[string]map[string]bool{}}
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digest, _, _ := strings.Cut(line, ":")
+		digest = strings.ToUpper(digest)
+		if len(digest) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 digest", path, lineNumber)
+		}
+		if _, err := hex.DecodeString(digest); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 digest", path, lineNumber)
+		}
+		b.add(digest)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *BreachedPasswords) add(digest string) {
+	prefix, suffix := digest[:hashPrefixLength], digest[hashPrefixLength:]
+	bucket, ok := b.buckets[prefix]
+	if !ok {
+		bucket = map[string]bool{}
+		b.buckets[prefix] = bucket
+	}
+	bucket[suffix] = true
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return b.buckets[digest[:hashPrefixLength]][digest[hashPrefixLength:]]
+}
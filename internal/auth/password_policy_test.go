@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	corpus := filepath.Join(t.TempDir(), "breached.txt")
+	// SHA-1 of "password123" and "letmein"
+	contents := "# test corpus\nCBFDAC6008F9CAB4083784CBD1874F76618D2A97:2000\nb7a875fc1ea228b9061041b7cec4bd3c52ab3ce3\n"
+	if err := os.WriteFile(corpus, []byte(contents), 0o600); err != nil {
+		t.Fatalf("error writing corpus: %v", err)
+	}
+	breached, err := LoadBreachedPasswords(corpus)
+	if err != nil {
+		t.Fatalf("LoadBreachedPasswords() error = %v", err)
+	}
+
+	policy := PasswordPolicy{
+		MinLength: 8,
+		MaxLength: 16,
+		Banned:    map[string]bool{"chirpychirpy": true},
+		Breached:  breached,
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		wantRules []string
+	}{
+		{name: "Acceptable password", password: "correct-horse-9", wantRules: nil},
+		{name: "Too short", password: "abc", wantRules: []string{RuleMinLength}},
+		{name: "Too long", password: "this-password-is-far-too-long", wantRules: []string{RuleMaxLength}},
+		{name: "Multibyte characters count once", password: "ééééééé", wantRules: []string{RuleMinLength}},
+		{name: "Banned, case insensitive", password: "ChirpyChirpy", wantRules: []string{RuleBanned}},
+		{name: "Breached", password: "password123", wantRules: []string{RuleBreached}},
+		{name: "Breached, lowercase digest", password: "letmein", wantRules: []string{RuleMinLength, RuleBreached}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := policy.Check(tt.password)
+			if len(violations) != len(tt.wantRules) {
+				t.Fatalf("Check() = %v, want rules %v", violations, tt.wantRules)
+			}
+			for i, v := range violations {
+				if v.Rule != tt.wantRules[i] {
+					t.Errorf("Check()[%d].Rule = %v, want %v", i, v.Rule, tt.wantRules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBreachedPasswordsInvalid(t *testing.T) {
+	corpus := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(corpus, []byte("not-a-digest\n"), 0o600); err != nil {
+		t.Fatalf("error writing corpus: %v", err)
+	}
+	if _, err := LoadBreachedPasswords(corpus); err == nil {
+		t.Errorf("LoadBreachedPasswords() expected error for invalid digest")
+	}
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// personalAccessTokenPrefix makes personal access tokens recognisable, both
+// to the server when deciding how to validate a bearer token and to secret
+// scanners when one is committed by mistake.
+const personalAccessTokenPrefix = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return personalAccessTokenPrefix + hex.EncodeToString(randomBytes), nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
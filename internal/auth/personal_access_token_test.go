@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken() error = %v", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("IsPersonalAccessToken(%q) = false, want true", token)
+	}
+	if len(token) != len(personalAccessTokenPrefix)+64 {
+		t.Errorf("MakePersonalAccessToken() length = %d, want %d", len(token), len(personalAccessTokenPrefix)+64)
+	}
+
+	other, _ := MakePersonalAccessToken()
+	if other == token {
+		t.Errorf("MakePersonalAccessToken() returned the same token twice")
+	}
+
+	accessToken, _ := MakeJWT(uuid.New(), "secret")
+	if IsPersonalAccessToken(accessToken) {
+		t.Errorf("IsPersonalAccessToken() = true for a JWT")
+	}
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrLinkExpired      = errors.New("link expired")
+)
+
+func urlSignature(path string, expires int64, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return mac.Sum(nil)
+}
+
+// SignURL returns path with expires and signature query parameters that
+// grant access to it until expires without any other credentials.
+func SignURL(path string, expires time.Time, secret string) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", hex.EncodeToString(urlSignature(path, expires.Unix(), secret)))
+	return path + "?" + query.Encode()
+}
+
+// VerifySignedURL checks the query parameters added by SignURL.
+func VerifySignedURL(path string, query url.Values, secret string, now time.Time) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(signature, urlSignature(path, expires, secret)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expires {
+		return ErrLinkExpired
+	}
+	return nil
+}
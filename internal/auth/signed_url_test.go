@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignedURL(t *testing.T) {
+	now := time.Date(2025, 2, 17, 12, 0, 0, 0, time.UTC)
+	signed := SignURL("/api/exports/abc/download", now.Add(time.Minute), "secret")
+	path, rawQuery, _ := strings.Cut(signed, "?")
+	query, _ := url.ParseQuery(rawQuery)
+
+	tests := []struct {
+		name    string
+		path    string
+		secret  string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "Valid", path: path, secret: "secret", now: now},
+		{name: "Expired", path: path, secret: "secret", now: now.Add(2 * time.Minute), wantErr: ErrLinkExpired},
+		{name: "Other path", path: "/api/exports/def/download", secret: "secret", now: now, wantErr: ErrInvalidSignature},
+		{name: "Wrong secret", path: path, secret: "wrong_secret", now: now, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignedURL(tt.path, query, tt.secret, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifySignedURL() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	tampered, _ := url.ParseQuery(rawQuery)
+	tampered.Set("expires", "9999999999")
+	if err := VerifySignedURL(path, tampered, "secret", now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignedURL() with extended expiry error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
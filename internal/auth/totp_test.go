@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// RFC 6238 appendix B test secret, "12345678901234567890" in base32.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name     string
+		code     string
+		now      time.Time
+		lastStep int64
+		wantStep int64
+		wantErr  bool
+	}{
+		{name: "RFC test vector", code: "287082", now: now, wantStep: 1},
+		{name: "Previous period within skew", code: "287082", now: now.Add(30 * time.Second), wantStep: 1},
+		{name: "Outside skew", code: "287082", now: now.Add(90 * time.Second), wantErr: true},
+		{name: "Already used step", code: "287082", now: now, lastStep: 1, wantErr: true},
+		{name: "Wrong code", code: "123456", now: now, wantErr: true},
+		{name: "Wrong length", code: "28708", now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, err := ValidateTOTP(secret, tt.code, tt.now, tt.lastStep)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTOTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if step != tt.wantStep {
+				t.Errorf("ValidateTOTP() step = %v, want %v", step, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	if HashRecoveryCode(codes[0]) != HashRecoveryCode(" "+codes[0][:5]+codes[0][6:]+" ") {
+		t.Errorf("HashRecoveryCode() should ignore dashes and surrounding space")
+	}
+	if HashRecoveryCode(codes[0]) == HashRecoveryCode(codes[1]) {
+		t.Errorf("HashRecoveryCode() returned the same hash for different codes")
+	}
+}
@@ -7,28 +7,75 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const addChirpHashtags = `-- name: AddChirpHashtags :exec
+INSERT INTO chirp_hashtags(chirp_id, tag, chirp_created_at)
+SELECT c.id, t.tag, c.created_at
+FROM chirps c, UNNEST($1::TEXT[]) AS t(tag)
+WHERE c.id = $2
+ON CONFLICT DO NOTHING
+`
+
+type AddChirpHashtagsParams struct {
+	Tags    []string
+	ChirpID uuid.UUID
+}
+
+// AddChirpHashtags indexes a chirp under each of the lowercased tags.
+func (q *Queries) AddChirpHashtags(ctx context.Context, arg AddChirpHashtagsParams) error {
+	_, err := q.db.ExecContext(ctx, addChirpHashtags, pq.Array(arg.Tags), arg.ChirpID)
+	return err
+}
+
+const countChirpsByUserSince = `-- name: CountChirpsByUserSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2
+`
+
+type CountChirpsByUserSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountChirpsByUserSince(ctx context.Context, arg CountChirpsByUserSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByUserSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, media_urls, reply_to_id)
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
-) RETURNING id, created_at, updated_at, body, user_id
+    $2,
+    $3,
+    $4
+) RETURNING id, created_at, updated_at, body, user_id, media_urls, reply_to_id
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	MediaUrls []string
+	ReplyToID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		pq.Array(arg.MediaUrls),
+		arg.ReplyToID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -36,6 +83,8 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		pq.Array(&i.MediaUrls),
+		&i.ReplyToID,
 	)
 	return i, err
 }
@@ -50,9 +99,22 @@ func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteChirpHashtags = `-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1
+`
+
+func (q *Queries) DeleteChirpHashtags(ctx context.Context, chirpID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChirpHashtags, chirpID)
+	return err
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, media_urls, reply_to_id FROM chirps
+WHERE id = $1 AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+)
 `
 
 func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -64,12 +126,18 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		pq.Array(&i.MediaUrls),
+		&i.ReplyToID,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, media_urls, reply_to_id FROM chirps
+WHERE NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+)
 ORDER BY created_at ASC
 `
 
@@ -88,6 +156,57 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			pq.Array(&i.MediaUrls),
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByHashtag = `-- name: GetChirpsByHashtag :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.media_urls, c.reply_to_id FROM chirp_hashtags h
+JOIN chirps c ON c.id = h.chirp_id
+WHERE h.tag = LOWER($1::TEXT)
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = c.user_id AND users.deletion_requested_at IS NOT NULL
+  )
+ORDER BY h.chirp_created_at DESC
+LIMIT $2
+`
+
+type GetChirpsByHashtagParams struct {
+	Tag        string
+	MaxResults int32
+}
+
+// GetChirpsByHashtag returns the newest chirps containing #tag in any case.
+func (q *Queries) GetChirpsByHashtag(ctx context.Context, arg GetChirpsByHashtagParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByHashtag, arg.Tag, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			pq.Array(&i.MediaUrls),
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -103,8 +222,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
+SELECT id, created_at, updated_at, body, user_id, media_urls, reply_to_id FROM chirps
+WHERE user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+)
 `
 
 func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
@@ -122,6 +244,54 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			pq.Array(&i.MediaUrls),
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNewestChirpsByUserID = `-- name: GetNewestChirpsByUserID :many
+SELECT id, created_at, updated_at, body, user_id, media_urls, reply_to_id FROM chirps
+WHERE user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+)
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type GetNewestChirpsByUserIDParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetNewestChirpsByUserID(ctx context.Context, arg GetNewestChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getNewestChirpsByUserID, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			pq.Array(&i.MediaUrls),
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -135,3 +305,31 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const updateChirp = `-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $2, media_urls = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, media_urls, reply_to_id
+`
+
+type UpdateChirpParams struct {
+	ID        uuid.UUID
+	Body      string
+	MediaUrls []string
+}
+
+func (q *Queries) UpdateChirp(ctx context.Context, arg UpdateChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirp, arg.ID, arg.Body, pq.Array(arg.MediaUrls))
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		pq.Array(&i.MediaUrls),
+		&i.ReplyToID,
+	)
+	return i, err
+}
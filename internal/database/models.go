@@ -6,17 +6,204 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ActivitypubFollower struct {
+	UserID    uuid.UUID
+	ActorID   string
+	Inbox     string
+	CreatedAt time.Time
+}
+
+type ActivitypubKey struct {
+	UserID        uuid.UUID
+	CreatedAt     time.Time
+	PublicKeyPem  string
+	PrivateKeyPem string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	MediaUrls []string
+	ReplyToID uuid.NullUUID
+}
+
+type ChirpHashtag struct {
+	ChirpID        uuid.UUID
+	Tag            string
+	ChirpCreatedAt time.Time
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Conversation struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	CreatedBy     uuid.NullUUID
+	IsGroup       bool
+	LastMessageAt time.Time
+}
+
+type ConversationParticipant struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	Status         string
+	LastReadAt     sql.NullTime
+}
+
+type DataExport struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	FilePath    sql.NullString
+	Error       sql.NullString
+	CompletedAt sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	DeletedAt      sql.NullTime
+}
+
+type MessageDeletion struct {
+	MessageID uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Type      string
+	ActorID   uuid.UUID
+	ChirpID   uuid.NullUUID
+	EventID   uuid.UUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID       uuid.UUID
+	UpdatedAt    time.Time
+	MutedTypes   []string
+	EmailDigest  bool
+	LastDigestAt sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scope        string
+}
+
+type OauthToken struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	ClientID         uuid.UUID
+	UserID           uuid.UUID
+	Scope            string
+	ExpiresAt        time.Time
+	RefreshTokenHash string
+	RefreshExpiresAt time.Time
+	RevokedAt        sql.NullTime
+}
+
+type OidcIdentity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Issuer    string
+	Subject   string
+}
+
+type OneTimeToken struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Purpose   string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type OutboxEvent struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	EventType     string
+	UserID        uuid.NullUUID
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	DispatchedAt  sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scope      string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
@@ -28,11 +215,89 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Subscription struct {
+	UserID           uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	ExpiresAt        time.Time
+}
+
+type SubscriptionEvent struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UserID           uuid.UUID
+	Event            string
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	ExpiresAt        time.Time
+	WebhookEventID   uuid.NullUUID
+}
+
 type User struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	TotpSecret          sql.NullString
+	TotpEnabled         bool
+	TotpLastStep        int64
+	EmailVerified       bool
+	PendingEmail        sql.NullString
+	DisplayName         sql.NullString
+	Bio                 sql.NullString
+	Location            sql.NullString
+	Website             sql.NullString
+	DeletionRequestedAt sql.NullTime
+	Username            sql.NullString
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	SubscriptionID uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Provider    string
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	Error       sql.NullString
+	ProcessedAt sql.NullTime
+}
+
+type WebhookSubscription struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.NullUUID
+	Url        string
+	Secret     string
+	EventTypes []string
 }
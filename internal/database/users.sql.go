@@ -7,10 +7,56 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const applyPendingEmail = `-- name: ApplyPendingEmail :one
+UPDATE users
+SET email = pending_email, pending_email = NULL, email_verified = TRUE, updated_at = NOW()
+WHERE id = $1 AND pending_email IS NOT NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, totp_last_step, email_verified, pending_email, display_name, bio, location, website, deletion_requested_at, username
+`
+
+func (q *Queries) ApplyPendingEmail(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, applyPendingEmail, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.DeletionRequestedAt,
+		&i.Username,
+	)
+	return i, err
+}
+
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL
+WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password) 
 VALUES (
@@ -19,7 +65,7 @@ VALUES (
     NOW(),
     $1,
     $2
-) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, totp_last_step, email_verified, pending_email, display_name, bio, location, website, deletion_requested_at, username
 `
 
 type CreateUserParams struct {
@@ -37,12 +83,50 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.DeletionRequestedAt,
+		&i.Username,
 	)
 	return i, err
 }
 
+const disableTOTP = `-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0
+WHERE id = $1
+`
+
+func (q *Queries) DisableTOTP(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, disableTOTP, id)
+	return err
+}
+
+const enableTOTP = `-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, totp_last_step = $2
+WHERE id = $1
+`
+
+type EnableTOTPParams struct {
+	ID           uuid.UUID
+	TotpLastStep int64
+}
+
+func (q *Queries) EnableTOTP(ctx context.Context, arg EnableTOTPParams) error {
+	_, err := q.db.ExecContext(ctx, enableTOTP, arg.ID, arg.TotpLastStep)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, totp_last_step, email_verified, pending_email, display_name, bio, location, website, deletion_requested_at, username FROM users
 WHERE email = $1
 `
 
@@ -56,10 +140,121 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.DeletionRequestedAt,
+		&i.Username,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, totp_last_step, email_verified, pending_email, display_name, bio, location, website, deletion_requested_at, username FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.DeletionRequestedAt,
+		&i.Username,
+	)
+	return i, err
+}
+
+const getUserIDsByUsernames = `-- name: GetUserIDsByUsernames :many
+SELECT id FROM users
+WHERE LOWER(username) = ANY($1::TEXT[]) AND deletion_requested_at IS NULL
+`
+
+// GetUserIDsByUsernames looks up users by lowercased username, skipping
+// accounts awaiting deletion.
+func (q *Queries) GetUserIDsByUsernames(ctx context.Context, usernames []string) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUserIDsByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isUserActive = `-- name: IsUserActive :one
+SELECT EXISTS(
+    SELECT 1 FROM users
+    WHERE id = $1 AND deletion_requested_at IS NULL
+)
+`
+
+// IsUserActive reports whether a user exists and has not asked for their
+// account to be deleted.
+func (q *Queries) IsUserActive(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserActive, id)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deletion_requested_at < $1
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletionRequestedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletionRequestedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, requestUserDeletion, id)
+	return err
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users
 `
@@ -69,21 +264,108 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
-const updatePasswordEmail = `-- name: UpdatePasswordEmail :one
+const setEmailVerified = `-- name: SetEmailVerified :exec
+UPDATE users
+SET email_verified = TRUE, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) SetEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, setEmailVerified, id)
+	return err
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :exec
+UPDATE users
+SET pending_email = $2
+WHERE id = $1
+`
+
+type SetPendingEmailParams struct {
+	ID           uuid.UUID
+	PendingEmail sql.NullString
+}
+
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setPendingEmail, arg.ID, arg.PendingEmail)
+	return err
+}
+
+const setTOTPSecret = `-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = 0
+WHERE id = $1
+`
+
+type SetTOTPSecretParams struct {
+	ID         uuid.UUID
+	TotpSecret sql.NullString
+}
+
+func (q *Queries) SetTOTPSecret(ctx context.Context, arg SetTOTPSecretParams) error {
+	_, err := q.db.ExecContext(ctx, setTOTPSecret, arg.ID, arg.TotpSecret)
+	return err
+}
+
+const updatePassword = `-- name: UpdatePassword :exec
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
-WHERE id= $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
 `
 
-type UpdatePasswordEmailParams struct {
+type UpdatePasswordParams struct {
 	ID             uuid.UUID
-	Email          string
 	HashedPassword string
 }
 
-func (q *Queries) UpdatePasswordEmail(ctx context.Context, arg UpdatePasswordEmailParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updatePasswordEmail, arg.ID, arg.Email, arg.HashedPassword)
+func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updatePassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updatePasswordHash = `-- name: UpdatePasswordHash :exec
+UPDATE users
+SET hashed_password = $2
+WHERE id = $1
+`
+
+type UpdatePasswordHashParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdatePasswordHash(ctx context.Context, arg UpdatePasswordHashParams) error {
+	_, err := q.db.ExecContext(ctx, updatePasswordHash, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET display_name = $3, bio = $4, location = $5, website = $6, username = $7, updated_at = NOW()
+WHERE id = $1 AND updated_at = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, totp_secret, totp_enabled, totp_last_step, email_verified, pending_email, display_name, bio, location, website, deletion_requested_at, username
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID
+	UpdatedAt   time.Time
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	Location    sql.NullString
+	Website     sql.NullString
+	Username    sql.NullString
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.UpdatedAt,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.Website,
+		arg.Username,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -92,17 +374,36 @@ func (q *Queries) UpdatePasswordEmail(ctx context.Context, arg UpdatePasswordEma
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.TotpSecret,
+		&i.TotpEnabled,
+		&i.TotpLastStep,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.Website,
+		&i.DeletionRequestedAt,
+		&i.Username,
 	)
 	return i, err
 }
 
-const upgradeUserByID = `-- name: UpgradeUserByID :exec
+const useTOTPStep = `-- name: UseTOTPStep :execrows
 UPDATE users
-SET is_chirpy_red = TRUE
-WHERE id = $1
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2
 `
 
-func (q *Queries) UpgradeUserByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserByID, id)
-	return err
+type UseTOTPStepParams struct {
+	ID           uuid.UUID
+	TotpLastStep int64
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, useTOTPStep, arg.ID, arg.TotpLastStep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,100 @@
+// Package entitlements describes what each subscription plan allows, so
+// handlers can ask what a user may do instead of checking plans directly.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Free is the plan of users without a live subscription.
+const Free = "free"
+
+// Entitlements are the limits and features of one plan.
+type Entitlements struct {
+	// MaxChirpLength is the longest chirp body, in bytes.
+	MaxChirpLength int `json:"max_chirp_length"`
+	// EditChirps allows changing a chirp after it was posted.
+	EditChirps bool `json:"edit_chirps"`
+	// MaxMediaPerChirp is how many media attachments a chirp may have.
+	MaxMediaPerChirp int `json:"max_media_per_chirp"`
+	// ChirpsPerHour limits how many chirps can be posted in an hour. Zero
+	// means no limit.
+	ChirpsPerHour int `json:"chirps_per_hour"`
+	// Badges are shown on the member's profile.
+	Badges []string `json:"badges"`
+}
+
+// Plans maps plan names to their entitlements.
+type Plans map[string]Entitlements
+
+// Defaults returns the built-in plans.
+func Defaults() Plans {
+	return Plans{
+		Free: {
+			MaxChirpLength:   140,
+			MaxMediaPerChirp: 1,
+			ChirpsPerHour:    50,
+		},
+		"red": {
+			MaxChirpLength:   280,
+			EditChirps:       true,
+			MaxMediaPerChirp: 4,
+			ChirpsPerHour:    500,
+			Badges:           []string{"chirpy_red"},
+		},
+	}
+}
+
+// For returns the entitlements of plan. Plans that aren't configured get
+// the free plan's entitlements.
+func (p Plans) For(plan string) Entitlements {
+	if e, ok := p[plan]; ok {
+		return e
+	}
+	return p[Free]
+}
+
+// Load reads plans from a JSON object keyed by plan name, on top of the
+// defaults. Settings left out of a plan keep their default value, or the
+// free plan's value for plans without defaults.
+func Load(r io.Reader) (Plans, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	plans := Defaults()
+	// Load the free plan first, since other plans start from it.
+	if settings, ok := raw[Free]; ok {
+		free := plans[Free]
+		if err := json.Unmarshal(settings, &free); err != nil {
+			return nil, fmt.Errorf("plan %s: %w", Free, err)
+		}
+		plans[Free] = free
+	}
+	for name, settings := range raw {
+		if name == Free {
+			continue
+		}
+		e, ok := plans[name]
+		if !ok {
+			e = plans[Free]
+		}
+		if err := json.Unmarshal(settings, &e); err != nil {
+			return nil, fmt.Errorf("plan %s: %w", name, err)
+		}
+		plans[name] = e
+	}
+
+	for name, e := range plans {
+		if e.MaxChirpLength <= 0 {
+			return nil, fmt.Errorf("plan %s: max_chirp_length must be positive", name)
+		}
+		if e.MaxMediaPerChirp < 0 || e.ChirpsPerHour < 0 {
+			return nil, fmt.Errorf("plan %s: limits cannot be negative", name)
+		}
+	}
+	return plans, nil
+}
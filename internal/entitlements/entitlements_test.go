@@ -0,0 +1,72 @@
+package entitlements
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		plan    string
+		want    Entitlements
+		wantErr bool
+	}{
+		{
+			name:   "empty config keeps defaults",
+			config: `{}`,
+			plan:   "red",
+			want:   Defaults()["red"],
+		},
+		{
+			name:   "override one setting",
+			config: `{"red": {"max_chirp_length": 500}}`,
+			plan:   "red",
+			want:   Entitlements{MaxChirpLength: 500, EditChirps: true, MaxMediaPerChirp: 4, ChirpsPerHour: 500, Badges: []string{"chirpy_red"}},
+		},
+		{
+			name:   "new plan starts from free",
+			config: `{"free": {"chirps_per_hour": 10}, "red_pro": {"edit_chirps": true, "badges": ["pro"]}}`,
+			plan:   "red_pro",
+			want:   Entitlements{MaxChirpLength: 140, EditChirps: true, MaxMediaPerChirp: 1, ChirpsPerHour: 10, Badges: []string{"pro"}},
+		},
+		{
+			name:   "unknown plan gets free",
+			config: `{"free": {"max_chirp_length": 100}}`,
+			plan:   "gold",
+			want:   Entitlements{MaxChirpLength: 100, MaxMediaPerChirp: 1, ChirpsPerHour: 50},
+		},
+		{
+			name:    "invalid length",
+			config:  `{"red": {"max_chirp_length": 0}}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative limit",
+			config:  `{"free": {"chirps_per_hour": -1}}`,
+			wantErr: true,
+		},
+		{
+			name:    "wrong type",
+			config:  `{"red": {"edit_chirps": "yes"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans, err := Load(strings.NewReader(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := plans.For(tt.plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("For(%q) = %+v, want %+v", tt.plan, got, tt.want)
+			}
+		})
+	}
+}
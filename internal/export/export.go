@@ -0,0 +1,441 @@
+// Package export assembles a user's personal data into a ZIP archive for
+// data portability requests.
+package export
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// Data is everything that goes into an archive.
+type Data struct {
+	User     database.User
+	Chirps   []database.Chirp
+	Sessions []database.RefreshToken
+	// Subscription is nil if the user has never subscribed.
+	Subscription       *database.Subscription
+	SubscriptionEvents []database.SubscriptionEvent
+	Conversations      []database.GetConversationsByUserIDRow
+	Messages           []database.Message
+	Following          []database.Follow
+	Followers          []database.Follow
+	Blocks             []database.UserBlock
+	Likes              []database.ChirpLike
+	Notifications      []database.Notification
+	AccessTokens       []database.PersonalAccessToken
+	OAuthGrants        []database.GetOAuthGrantsByUserIDRow
+	Identities         []database.OidcIdentity
+}
+
+type profile struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Username      *string   `json:"username"`
+	DisplayName   *string   `json:"display_name"`
+	Bio           *string   `json:"bio"`
+	Location      *string   `json:"location"`
+	Website       *string   `json:"website"`
+	TOTPEnabled   bool      `json:"two_factor_enabled"`
+}
+
+type chirp struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+	Media     []string  `json:"media"`
+}
+
+// session describes a refresh token without the token itself.
+type session struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type membership struct {
+	IsChirpyRed  bool                `json:"is_chirpy_red"`
+	Subscription *subscription       `json:"subscription"`
+	History      []subscriptionEvent `json:"history"`
+}
+
+type subscription struct {
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+type subscriptionEvent struct {
+	CreatedAt        time.Time `json:"created_at"`
+	Event            string    `json:"event"`
+	Plan             string    `json:"plan"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+type conversation struct {
+	ID           uuid.UUID   `json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	IsGroup      bool        `json:"is_group"`
+	Status       string      `json:"status"`
+	Participants []uuid.UUID `json:"participants"`
+	Messages     []message   `json:"messages"`
+}
+
+type message struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	SenderID  uuid.UUID  `json:"sender_id"`
+	Body      string     `json:"body"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type connections struct {
+	Following []connection `json:"following"`
+	Followers []connection `json:"followers"`
+	Blocked   []connection `json:"blocked"`
+}
+
+type connection struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type like struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+// access describes how the account can be reached without a password. It
+// holds no tokens or token hashes.
+type access struct {
+	PersonalAccessTokens []accessToken `json:"personal_access_tokens"`
+	OAuthGrants          []oauthGrant  `json:"oauth_grants"`
+	LinkedIdentities     []identity    `json:"linked_identities"`
+}
+
+type accessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type oauthGrant struct {
+	ID               uuid.UUID  `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ClientID         uuid.UUID  `json:"client_id"`
+	ClientName       string     `json:"client_name"`
+	Scope            string     `json:"scope"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshExpiresAt time.Time  `json:"refresh_expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+}
+
+type identity struct {
+	CreatedAt time.Time `json:"created_at"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+}
+
+const readme = `This archive contains the personal data Chirpy holds about you.
+
+profile.json        your account details
+chirps.json/.csv    every chirp you have posted
+sessions.json/.csv  the sign-in sessions on your account
+membership.json     your Chirpy Red membership and its history
+messages.json/.csv  your conversations and the messages in them
+connections.json    who you follow, who follows you and who you blocked
+likes.json/.csv     the chirps you liked
+notifications.json  your notifications
+access.json         your personal access tokens, the apps you authorized
+                    and the sign-in providers linked to your account
+
+Left out on purpose:
+- passwords, two-factor secrets, recovery codes and the values or hashes of
+  any token, which would let someone else sign in as you
+- messages you deleted for yourself, and the text of messages deleted for
+  everyone, which Chirpy no longer keeps
+- conversations you left, which are no longer linked to you
+- who has blocked you, which belongs to the people who blocked you
+`
+
+// Write writes the archive for data to w.
+func Write(w io.Writer, data Data) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeFile(zw, "README.txt", func(f io.Writer) error {
+		_, err := io.WriteString(f, readme)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	u := data.User
+	if err := writeJSON(zw, "profile.json", profile{
+		ID:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Username:      nullString(u.Username),
+		DisplayName:   nullString(u.DisplayName),
+		Bio:           nullString(u.Bio),
+		Location:      nullString(u.Location),
+		Website:       nullString(u.Website),
+		TOTPEnabled:   u.TotpEnabled,
+	}); err != nil {
+		return err
+	}
+
+	chirps := make([]chirp, 0, len(data.Chirps))
+	chirpRows := [][]string{{"id", "created_at", "updated_at", "body"}}
+	for _, c := range data.Chirps {
+		media := c.MediaUrls
+		if media == nil {
+			media = []string{}
+		}
+		chirps = append(chirps, chirp{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Body: c.Body, Media: media})
+		chirpRows = append(chirpRows, []string{c.ID.String(), formatTime(c.CreatedAt), formatTime(c.UpdatedAt), c.Body})
+	}
+	if err := writeJSON(zw, "chirps.json", chirps); err != nil {
+		return err
+	}
+	if err := writeCSV(zw, "chirps.csv", chirpRows); err != nil {
+		return err
+	}
+
+	sessions := make([]session, 0, len(data.Sessions))
+	sessionRows := [][]string{{"created_at", "expires_at", "revoked_at"}}
+	for _, t := range data.Sessions {
+		s := session{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+		revokedAt := ""
+		if t.RevokedAt.Valid {
+			s.RevokedAt = &t.RevokedAt.Time
+			revokedAt = formatTime(t.RevokedAt.Time)
+		}
+		sessions = append(sessions, s)
+		sessionRows = append(sessionRows, []string{formatTime(t.CreatedAt), formatTime(t.ExpiresAt), revokedAt})
+	}
+	if err := writeJSON(zw, "sessions.json", sessions); err != nil {
+		return err
+	}
+	if err := writeCSV(zw, "sessions.csv", sessionRows); err != nil {
+		return err
+	}
+
+	member := membership{IsChirpyRed: u.IsChirpyRed, History: make([]subscriptionEvent, 0, len(data.SubscriptionEvents))}
+	if s := data.Subscription; s != nil {
+		member.Subscription = &subscription{Plan: s.Plan, Status: s.Status, CurrentPeriodEnd: s.CurrentPeriodEnd, ExpiresAt: s.ExpiresAt}
+	}
+	for _, e := range data.SubscriptionEvents {
+		member.History = append(member.History, subscriptionEvent{
+			CreatedAt:        e.CreatedAt,
+			Event:            e.Event,
+			Plan:             e.Plan,
+			Status:           e.Status,
+			CurrentPeriodEnd: e.CurrentPeriodEnd,
+			ExpiresAt:        e.ExpiresAt,
+		})
+	}
+	if err := writeJSON(zw, "membership.json", member); err != nil {
+		return err
+	}
+
+	if err := writeMessages(zw, data); err != nil {
+		return err
+	}
+
+	conns := connections{
+		Following: make([]connection, 0, len(data.Following)),
+		Followers: make([]connection, 0, len(data.Followers)),
+		Blocked:   make([]connection, 0, len(data.Blocks)),
+	}
+	for _, f := range data.Following {
+		conns.Following = append(conns.Following, connection{UserID: f.FolloweeID, CreatedAt: f.CreatedAt})
+	}
+	for _, f := range data.Followers {
+		conns.Followers = append(conns.Followers, connection{UserID: f.FollowerID, CreatedAt: f.CreatedAt})
+	}
+	for _, b := range data.Blocks {
+		conns.Blocked = append(conns.Blocked, connection{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	if err := writeJSON(zw, "connections.json", conns); err != nil {
+		return err
+	}
+
+	likes := make([]like, 0, len(data.Likes))
+	likeRows := [][]string{{"chirp_id", "created_at"}}
+	for _, l := range data.Likes {
+		likes = append(likes, like{ChirpID: l.ChirpID, CreatedAt: l.CreatedAt})
+		likeRows = append(likeRows, []string{l.ChirpID.String(), formatTime(l.CreatedAt)})
+	}
+	if err := writeJSON(zw, "likes.json", likes); err != nil {
+		return err
+	}
+	if err := writeCSV(zw, "likes.csv", likeRows); err != nil {
+		return err
+	}
+
+	notifications := make([]notification, 0, len(data.Notifications))
+	for _, n := range data.Notifications {
+		item := notification{ID: n.ID, CreatedAt: n.CreatedAt, Type: n.Type, ActorID: n.ActorID, ReadAt: nullTime(n.ReadAt)}
+		if n.ChirpID.Valid {
+			item.ChirpID = &n.ChirpID.UUID
+		}
+		notifications = append(notifications, item)
+	}
+	if err := writeJSON(zw, "notifications.json", notifications); err != nil {
+		return err
+	}
+
+	acc := access{
+		PersonalAccessTokens: make([]accessToken, 0, len(data.AccessTokens)),
+		OAuthGrants:          make([]oauthGrant, 0, len(data.OAuthGrants)),
+		LinkedIdentities:     make([]identity, 0, len(data.Identities)),
+	}
+	for _, t := range data.AccessTokens {
+		acc.PersonalAccessTokens = append(acc.PersonalAccessTokens, accessToken{
+			ID:         t.ID,
+			CreatedAt:  t.CreatedAt,
+			Name:       t.Name,
+			Scope:      t.Scope,
+			ExpiresAt:  nullTime(t.ExpiresAt),
+			LastUsedAt: nullTime(t.LastUsedAt),
+		})
+	}
+	for _, g := range data.OAuthGrants {
+		acc.OAuthGrants = append(acc.OAuthGrants, oauthGrant{
+			ID:               g.ID,
+			CreatedAt:        g.CreatedAt,
+			ClientID:         g.ClientID,
+			ClientName:       g.ClientName,
+			Scope:            g.Scope,
+			ExpiresAt:        g.ExpiresAt,
+			RefreshExpiresAt: g.RefreshExpiresAt,
+			RevokedAt:        nullTime(g.RevokedAt),
+		})
+	}
+	for _, i := range data.Identities {
+		acc.LinkedIdentities = append(acc.LinkedIdentities, identity{CreatedAt: i.CreatedAt, Issuer: i.Issuer, Subject: i.Subject})
+	}
+	if err := writeJSON(zw, "access.json", acc); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writeMessages groups the messages under their conversations. Messages in
+// conversations missing from data.Conversations are left out.
+func writeMessages(zw *zip.Writer, data Data) error {
+	conversations := make([]conversation, 0, len(data.Conversations))
+	index := map[uuid.UUID]int{}
+	for _, c := range data.Conversations {
+		participants := c.ParticipantIds
+		if participants == nil {
+			participants = []uuid.UUID{}
+		}
+		index[c.ID] = len(conversations)
+		conversations = append(conversations, conversation{
+			ID:           c.ID,
+			CreatedAt:    c.CreatedAt,
+			IsGroup:      c.IsGroup,
+			Status:       c.Status,
+			Participants: participants,
+			Messages:     []message{},
+		})
+	}
+
+	rows := [][]string{{"conversation_id", "id", "created_at", "sender_id", "body", "deleted_at"}}
+	for _, m := range data.Messages {
+		i, ok := index[m.ConversationID]
+		if !ok {
+			continue
+		}
+		conversations[i].Messages = append(conversations[i].Messages, message{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			SenderID:  m.SenderID,
+			Body:      m.Body,
+			DeletedAt: nullTime(m.DeletedAt),
+		})
+		deletedAt := ""
+		if m.DeletedAt.Valid {
+			deletedAt = formatTime(m.DeletedAt.Time)
+		}
+		rows = append(rows, []string{m.ConversationID.String(), m.ID.String(), formatTime(m.CreatedAt), m.SenderID.String(), m.Body, deletedAt})
+	}
+
+	if err := writeJSON(zw, "messages.json", conversations); err != nil {
+		return err
+	}
+	return writeCSV(zw, "messages.csv", rows)
+}
+
+func writeFile(zw *zip.Writer, name string, write func(io.Writer) error) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	return write(f)
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	return writeFile(zw, name, func(f io.Writer) error {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	})
+}
+
+func writeCSV(zw *zip.Writer, name string, rows [][]string) error {
+	return writeFile(zw, name, func(f io.Writer) error {
+		cw := csv.NewWriter(f)
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	})
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func nullString(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
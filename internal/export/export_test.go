@@ -0,0 +1,134 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2025, 2, 17, 12, 0, 0, 0, time.UTC)
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          "user@example.com",
+		HashedPassword: "$argon2id$secret",
+		TotpSecret:     sql.NullString{String: "TOTPSECRET", Valid: true},
+		DisplayName:    sql.NullString{String: "User", Valid: true},
+		IsChirpyRed:    true,
+	}
+	conversationID, otherID := uuid.New(), uuid.New()
+	data := Data{
+		User: user,
+		Chirps: []database.Chirp{
+			{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Body: "hello, \"world\"", UserID: user.ID},
+		},
+		Sessions: []database.RefreshToken{
+			{Token: "refresh-token-value", CreatedAt: now, ExpiresAt: now.Add(time.Hour), UserID: user.ID},
+		},
+		Subscription: &database.Subscription{UserID: user.ID, Plan: "red", Status: "active", CurrentPeriodEnd: now.Add(24 * time.Hour), ExpiresAt: now.Add(48 * time.Hour)},
+		SubscriptionEvents: []database.SubscriptionEvent{
+			{ID: uuid.New(), CreatedAt: now, UserID: user.ID, Event: "upgraded", Plan: "red", Status: "active", CurrentPeriodEnd: now.Add(24 * time.Hour), ExpiresAt: now.Add(48 * time.Hour)},
+		},
+		Conversations: []database.GetConversationsByUserIDRow{
+			{ID: conversationID, CreatedAt: now, Status: "accepted", ParticipantIds: []uuid.UUID{user.ID, otherID}},
+		},
+		Messages: []database.Message{
+			{ID: uuid.New(), CreatedAt: now, ConversationID: conversationID, SenderID: otherID, Body: "hi there"},
+		},
+		Following: []database.Follow{{FollowerID: user.ID, FolloweeID: otherID, CreatedAt: now}},
+		Likes:     []database.ChirpLike{{UserID: user.ID, ChirpID: uuid.New(), CreatedAt: now}},
+		Notifications: []database.Notification{
+			{ID: uuid.New(), CreatedAt: now, UserID: user.ID, Type: "follow", ActorID: otherID, EventID: uuid.New()},
+		},
+		AccessTokens: []database.PersonalAccessToken{
+			{ID: uuid.New(), CreatedAt: now, UserID: user.ID, Name: "cli", TokenHash: "pat-token-hash", Scope: "chirps:read"},
+		},
+		OAuthGrants: []database.GetOAuthGrantsByUserIDRow{
+			{ID: uuid.New(), CreatedAt: now, ClientID: uuid.New(), ClientName: "Reader", Scope: "chirps:read", ExpiresAt: now, RefreshExpiresAt: now},
+		},
+		Identities: []database.OidcIdentity{{ID: uuid.New(), CreatedAt: now, UserID: user.ID, Issuer: "https://id.example.com", Subject: "1234"}},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("error opening %s: %v", f.Name, err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	for _, name := range []string{"README.txt", "profile.json", "chirps.json", "chirps.csv", "sessions.json", "sessions.csv", "membership.json", "messages.json", "messages.csv", "connections.json", "likes.json", "likes.csv", "notifications.json", "access.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("archive is missing %s", name)
+		}
+	}
+
+	for name, contents := range files {
+		for _, secret := range []string{"$argon2id$secret", "TOTPSECRET", "refresh-token-value", "pat-token-hash"} {
+			if bytes.Contains(contents, []byte(secret)) {
+				t.Errorf("%s leaks %q", name, secret)
+			}
+		}
+	}
+
+	var gotProfile profile
+	if err := json.Unmarshal(files["profile.json"], &gotProfile); err != nil {
+		t.Fatalf("error decoding profile.json: %v", err)
+	}
+	if gotProfile.Email != user.Email || gotProfile.DisplayName == nil || *gotProfile.DisplayName != "User" {
+		t.Errorf("profile.json = %+v", gotProfile)
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(files["chirps.csv"])).ReadAll()
+	if err != nil {
+		t.Fatalf("error reading chirps.csv: %v", err)
+	}
+	if len(rows) != 2 || rows[1][3] != "hello, \"world\"" {
+		t.Errorf("chirps.csv = %v", rows)
+	}
+
+	var gotMembership membership
+	if err := json.Unmarshal(files["membership.json"], &gotMembership); err != nil {
+		t.Fatalf("error decoding membership.json: %v", err)
+	}
+	if !gotMembership.IsChirpyRed || gotMembership.Subscription == nil || gotMembership.Subscription.Status != "active" || len(gotMembership.History) != 1 || gotMembership.History[0].Event != "upgraded" {
+		t.Errorf("membership.json = %+v", gotMembership)
+	}
+
+	var gotMessages []conversation
+	if err := json.Unmarshal(files["messages.json"], &gotMessages); err != nil {
+		t.Fatalf("error decoding messages.json: %v", err)
+	}
+	if len(gotMessages) != 1 || len(gotMessages[0].Messages) != 1 || gotMessages[0].Messages[0].Body != "hi there" {
+		t.Errorf("messages.json = %+v", gotMessages)
+	}
+
+	var gotAccess access
+	if err := json.Unmarshal(files["access.json"], &gotAccess); err != nil {
+		t.Fatalf("error decoding access.json: %v", err)
+	}
+	if len(gotAccess.PersonalAccessTokens) != 1 || len(gotAccess.OAuthGrants) != 1 || len(gotAccess.LinkedIdentities) != 1 || gotAccess.OAuthGrants[0].ClientName != "Reader" {
+		t.Errorf("access.json = %+v", gotAccess)
+	}
+}
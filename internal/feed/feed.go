@@ -0,0 +1,235 @@
+// Package feed renders lists of chirps as Atom (RFC 4287) and RSS 2.0
+// documents for feed readers.
+package feed
+
+import (
+	"encoding/xml"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const atomNS = "http://www.w3.org/2005/Atom"
+
+// maxTitleLength is how much of an entry's text is used as its title.
+const maxTitleLength = 60
+
+// Feed is a list of entries, newest first.
+type Feed struct {
+	// ID is a permanent, unique identifier for the feed, such as a URN.
+	ID       string
+	Title    string
+	Subtitle string
+	// Link is where the entries can be seen outside the feed, and Self is
+	// the URL of the feed itself.
+	Link string
+	Self string
+	// Author is the feed's author, if all of its entries share one.
+	Author string
+	// Created stands in for the time of the last update while the feed has
+	// no entries.
+	Created time.Time
+	Entries []Entry
+}
+
+type Entry struct {
+	// ID is a permanent, unique identifier for the entry, such as a URN.
+	ID        string
+	Link      string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+	// Text is the entry's plain text content, which is HTML-escaped in the
+	// feed.
+	Text string
+	// Images are URLs of images attached to the entry.
+	Images []string
+}
+
+// LastUpdated is when the most recently updated entry changed, or when the
+// feed was created if it has none.
+func (f Feed) LastUpdated() time.Time {
+	updated := f.Created
+	for _, e := range f.Entries {
+		if e.Updated.After(updated) {
+			updated = e.Updated
+		}
+	}
+	return updated.UTC()
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomPerson `xml:"author"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom renders the feed as an Atom document.
+func (f Feed) Atom() ([]byte, error) {
+	doc := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Subtitle,
+		Updated:  f.LastUpdated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Href: f.Link},
+		},
+	}
+	if f.Author != "" {
+		doc.Author = &atomPerson{Name: f.Author}
+	}
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:        e.ID,
+			Title:     title(e.Text),
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Href: e.Link}},
+			Content:   atomContent{Type: "html", Body: contentHTML(e)},
+		}
+		if e.Author != "" && e.Author != f.Author {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshal(doc)
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          rssLink   `xml:"http://www.w3.org/2005/Atom link"`
+	Items         []rssItem `xml:"item"`
+}
+
+// rssLink is an atom:link, which RSS readers use to find the feed's own
+// URL.
+type rssLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"http://purl.org/dc/elements/1.1/ creator,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders the feed as an RSS 2.0 document. RSS has no update time for
+// items, so edits only show in lastBuildDate.
+func (f Feed) RSS() ([]byte, error) {
+	description := f.Subtitle
+	if description == "" {
+		description = f.Title
+	}
+	doc := rssDocument{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   description,
+			LastBuildDate: f.LastUpdated().Format(time.RFC1123Z),
+			Self:          rssLink{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+		},
+	}
+	for _, e := range f.Entries {
+		author := e.Author
+		if author == "" {
+			author = f.Author
+		}
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       title(e.Text),
+			Link:        e.Link,
+			Description: contentHTML(e),
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+			Creator:     author,
+		})
+	}
+	return marshal(doc)
+}
+
+func marshal(doc any) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// title shortens text to a single line that fits a feed reader's list.
+func title(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxTitleLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+}
+
+// contentHTML turns an entry's text and images into HTML. Everything taken
+// from the entry is escaped, and the result is escaped again as XML text.
+func contentHTML(e Entry) string {
+	var b strings.Builder
+	b.WriteString("<p>")
+	for i, line := range strings.Split(e.Text, "\n") {
+		if i > 0 {
+			b.WriteString("<br>")
+		}
+		b.WriteString(html.EscapeString(line))
+	}
+	b.WriteString("</p>")
+	for _, src := range e.Images {
+		b.WriteString(`<p><img src="` + html.EscapeString(src) + `" alt=""></p>`)
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return Feed{
+		ID:      "urn:uuid:feed",
+		Title:   "Chirps by @alice",
+		Link:    "https://chirpy.example/api/chirps?author_id=alice",
+		Self:    "https://chirpy.example/users/alice/feed.atom",
+		Author:  "@alice",
+		Created: created,
+		Entries: []Entry{
+			{
+				ID:        "urn:uuid:second",
+				Link:      "https://chirpy.example/api/chirps/second",
+				Published: created.Add(2 * time.Hour),
+				Updated:   created.Add(2 * time.Hour),
+				Text:      "<script>alert(1)</script> & friends\nsecond line",
+				Images:    []string{`https://img.example/a.png?x="1"`},
+			},
+			{
+				ID:        "urn:uuid:first",
+				Link:      "https://chirpy.example/api/chirps/first",
+				Published: created.Add(time.Hour),
+				Updated:   created.Add(3 * time.Hour),
+				Text:      "edited later",
+			},
+		},
+	}
+}
+
+func TestLastUpdated(t *testing.T) {
+	f := testFeed()
+	if got, want := f.LastUpdated(), f.Created.Add(3*time.Hour); !got.Equal(want) {
+		t.Errorf("LastUpdated() = %v, want %v", got, want)
+	}
+	f.Entries = nil
+	if got := f.LastUpdated(); !got.Equal(f.Created) {
+		t.Errorf("LastUpdated() of empty feed = %v, want %v", got, f.Created)
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := testFeed().Atom()
+	if err != nil {
+		t.Fatalf("Atom() error = %v", err)
+	}
+
+	var doc struct {
+		ID      string `xml:"id"`
+		Updated string `xml:"updated"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Content struct {
+				Type string `xml:"type,attr"`
+				Body string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Atom() is not valid XML: %v\n%s", err, body)
+	}
+	if doc.ID != "urn:uuid:feed" || doc.Updated != "2024-01-01T03:00:00Z" || doc.Author.Name != "@alice" {
+		t.Errorf("feed = %+v", doc)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.ID != "urn:uuid:second" || entry.Updated != "2024-01-01T02:00:00Z" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.Title != "<script>alert(1)</script> & friends second line" {
+		t.Errorf("title = %q", entry.Title)
+	}
+	wantHTML := `<p>&lt;script&gt;alert(1)&lt;/script&gt; &amp; friends<br>second line</p>` +
+		`<p><img src="https://img.example/a.png?x=&#34;1&#34;" alt=""></p>`
+	if entry.Content.Type != "html" || entry.Content.Body != wantHTML {
+		t.Errorf("content = %q, want %q", entry.Content.Body, wantHTML)
+	}
+	if strings.Contains(string(body), "<script>") {
+		t.Errorf("markup from the entry reached the document unescaped:\n%s", body)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := testFeed().RSS()
+	if err != nil {
+		t.Fatalf("RSS() error = %v", err)
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Description   string `xml:"description"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				GUID struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+				Creator string `xml:"creator"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("RSS() is not valid XML: %v\n%s", err, body)
+	}
+	if doc.Version != "2.0" || doc.Channel.Description != "Chirps by @alice" {
+		t.Errorf("rss = %+v", doc)
+	}
+	if doc.Channel.LastBuildDate != "Mon, 01 Jan 2024 03:00:00 +0000" {
+		t.Errorf("lastBuildDate = %q", doc.Channel.LastBuildDate)
+	}
+	if len(doc.Channel.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[1]
+	if item.GUID.Value != "urn:uuid:first" || item.GUID.IsPermaLink != "false" {
+		t.Errorf("guid = %+v", item.GUID)
+	}
+	if item.PubDate != "Mon, 01 Jan 2024 01:00:00 +0000" || item.Creator != "@alice" {
+		t.Errorf("item = %+v", item)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	long := strings.Repeat("ab ", 40)
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"short", "short"},
+		{"  spread\n\tout  ", "spread out"},
+		{long, strings.TrimSpace(long[:maxTitleLength-1]) + "…"},
+	}
+	for _, tt := range tests {
+		if got := title(tt.text); got != tt.want {
+			t.Errorf("title(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
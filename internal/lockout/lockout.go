@@ -0,0 +1,97 @@
+// Package lockout throttles repeated failures (such as wrong passwords)
+// per key with exponential backoff.
+package lockout
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Attempts is the failure history stored for a single key.
+type Attempts struct {
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// Store persists failure counts. Increment must be atomic so that several
+// server instances sharing a store cannot lose failures.
+type Store interface {
+	Get(ctx context.Context, key string) (Attempts, error)
+	// Increment records a failure at now. Failures recorded before
+	// windowStart are discarded first.
+	Increment(ctx context.Context, key string, now, windowStart time.Time) (Attempts, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type Policy struct {
+	// FreeAttempts is the number of failures allowed before any delay.
+	FreeAttempts int
+	// BaseDelay is the lockout after the first failure past FreeAttempts;
+	// it doubles with every further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the lockout.
+	MaxDelay time.Duration
+	// Window is how long a failure is remembered.
+	Window time.Duration
+}
+
+func (p Policy) delay(failures int) time.Duration {
+	excess := failures - p.FreeAttempts
+	if excess <= 0 {
+		return 0
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(excess-1))
+	if delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// RetryAfter reports how long key is locked out for, or zero if it may try
+// again now.
+func (l *Limiter) RetryAfter(ctx context.Context, key string) (time.Duration, error) {
+	attempts, err := l.store.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	now := l.now().UTC()
+	if attempts.LastFailureAt.Before(now.Add(-l.policy.Window)) {
+		return 0, nil
+	}
+	lockedUntil := attempts.LastFailureAt.Add(l.policy.delay(attempts.Failures))
+	if !lockedUntil.After(now) {
+		return 0, nil
+	}
+	return lockedUntil.Sub(now), nil
+}
+
+// Fail records a failure for key and returns the resulting lockout.
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := l.now().UTC()
+	attempts, err := l.store.Increment(ctx, key, now, now.Add(-l.policy.Window))
+	if err != nil {
+		return 0, err
+	}
+	return l.policy.delay(attempts.Failures), nil
+}
+
+// Reset clears the failure history for key, e.g. after a successful login
+// or an admin unlock.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
@@ -0,0 +1,74 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 2, 17, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     4 * time.Second,
+		Window:       time.Hour,
+	})
+	limiter.now = func() time.Time { return now }
+
+	wantDelays := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	for i, want := range wantDelays {
+		got, err := limiter.Fail(ctx, "account:a@example.com")
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("failure %d: Fail() = %v, want %v", i+1, got, want)
+		}
+	}
+
+	retry, _ := limiter.RetryAfter(ctx, "account:a@example.com")
+	if retry != 4*time.Second {
+		t.Errorf("RetryAfter() = %v, want %v", retry, 4*time.Second)
+	}
+	retry, _ = limiter.RetryAfter(ctx, "account:b@example.com")
+	if retry != 0 {
+		t.Errorf("RetryAfter() for other key = %v, want 0", retry)
+	}
+
+	now = now.Add(3 * time.Second)
+	retry, _ = limiter.RetryAfter(ctx, "account:a@example.com")
+	if retry != time.Second {
+		t.Errorf("RetryAfter() after waiting = %v, want %v", retry, time.Second)
+	}
+
+	if err := limiter.Reset(ctx, "account:a@example.com"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	retry, _ = limiter.RetryAfter(ctx, "account:a@example.com")
+	if retry != 0 {
+		t.Errorf("RetryAfter() after reset = %v, want 0", retry)
+	}
+}
+
+func TestLimiterWindowExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 2, 17, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(NewMemoryStore(), Policy{
+		FreeAttempts: 1,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       10 * time.Minute,
+	})
+	limiter.now = func() time.Time { return now }
+
+	limiter.Fail(ctx, "ip:203.0.113.7")
+	limiter.Fail(ctx, "ip:203.0.113.7")
+	now = now.Add(11 * time.Minute)
+
+	delay, _ := limiter.Fail(ctx, "ip:203.0.113.7")
+	if delay != 0 {
+		t.Errorf("Fail() after window = %v, want 0", delay)
+	}
+}
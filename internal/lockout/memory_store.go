@@ -0,0 +1,62 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failures in process memory. It is only suitable for a
+// single server instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	attempts  map[string]Attempts
+	lastPrune time.Time
+}
+
+const pruneInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: map[string]Attempts{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[key], nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, now, windowStart time.Time) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attempts[key]
+	if a.LastFailureAt.Before(windowStart) {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailureAt = now
+	s.attempts[key] = a
+	if now.Sub(s.lastPrune) > pruneInterval {
+		s.pruneLocked(windowStart)
+		s.lastPrune = now
+	}
+	return a, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// pruneLocked drops keys whose failures have aged out so the map does not
+// grow without bound under a spray of distinct keys.
+func (s *MemoryStore) pruneLocked(windowStart time.Time) {
+	for key, a := range s.attempts {
+		if a.LastFailureAt.Before(windowStart) {
+			delete(s.attempts, key)
+		}
+	}
+}
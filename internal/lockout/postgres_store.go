@@ -0,0 +1,47 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// PostgresStore shares failure counts between server instances through the
+// login_attempts table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Attempts, error) {
+	row, err := s.db.GetLoginAttempts(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Attempts{}, nil
+	}
+	if err != nil {
+		return Attempts{}, err
+	}
+	return Attempts{Failures: int(row.Failures), LastFailureAt: row.LastFailureAt}, nil
+}
+
+func (s *PostgresStore) Increment(ctx context.Context, key string, now, windowStart time.Time) (Attempts, error) {
+	row, err := s.db.IncrementLoginAttempts(ctx, database.IncrementLoginAttemptsParams{
+		Key:         key,
+		Now:         now,
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		return Attempts{}, err
+	}
+	return Attempts{Failures: int(row.Failures), LastFailureAt: row.LastFailureAt}, nil
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.db.ResetLoginAttempts(ctx, key)
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "chirpy@example.com"}
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Verify your email",
+		Body:    "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 message file, got %d", len(files))
+	}
+	contents, _ := os.ReadFile(files[0])
+	for _, want := range []string{"To: user@example.com\r\n", "Subject: Verify your email\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(contents), want) {
+			t.Errorf("message missing %q:\n%s", want, contents)
+		}
+	}
+}
+
+func TestFileMailerRejectsHeaderInjection(t *testing.T) {
+	m := &FileMailer{Dir: t.TempDir(), From: "chirpy@example.com"}
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com\r\nBcc: victim@example.com",
+		Subject: "hi",
+	})
+	if err == nil {
+		t.Errorf("Send() expected error for header injection")
+	}
+}
@@ -0,0 +1,121 @@
+// Package notification holds the parts of notifications that don't touch
+// the database: the notification types, finding @mentions in chirps and
+// rendering the email digest.
+package notification
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Notification types.
+const (
+	TypeFollow  = "follow"
+	TypeLike    = "like"
+	TypeMention = "mention"
+	TypeReply   = "reply"
+)
+
+// Types lists every notification type.
+var Types = []string{TypeFollow, TypeLike, TypeMention, TypeReply}
+
+// ValidType reports whether t is a notification type.
+func ValidType(t string) bool {
+	return slices.Contains(Types, t)
+}
+
+// MaxUsernameLength is the longest username, and so the longest mention.
+const MaxUsernameLength = 30
+
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,30}$`)
+	// mentionPattern requires the @ to start a word, so that email
+	// addresses aren't taken for mentions.
+	mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_@.])@([A-Za-z0-9_]{1,30})\b`)
+)
+
+// ValidUsername reports whether s can be used as a username: letters,
+// digits and underscores only, so that a mention ends at the first other
+// character.
+func ValidUsername(s string) bool {
+	return usernamePattern.MatchString(s)
+}
+
+// Mentions returns the usernames mentioned in text, lowercased and in the
+// order they first appear. Usernames are unique regardless of case.
+func Mentions(text string) []string {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(match[1])
+		if !slices.Contains(usernames, username) {
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// DigestItem is one notification as listed in a digest email.
+type DigestItem struct {
+	Type string
+	// Actor is how to refer to the user who caused the notification.
+	Actor string
+	// ChirpBody is the chirp the notification is about, if any.
+	ChirpBody string
+}
+
+// maxQuoteLength is how much of a chirp a digest quotes.
+const maxQuoteLength = 80
+
+// Describe renders an item as a sentence, quoting the start of its chirp.
+func Describe(item DigestItem) string {
+	var action string
+	switch item.Type {
+	case TypeFollow:
+		return item.Actor + " followed you"
+	case TypeLike:
+		action = "liked your chirp"
+	case TypeMention:
+		action = "mentioned you"
+	case TypeReply:
+		action = "replied to your chirp"
+	default:
+		return item.Actor + " did something"
+	}
+	if item.ChirpBody == "" {
+		return item.Actor + " " + action
+	}
+	return fmt.Sprintf("%s %s: %q", item.Actor, action, quote(item.ChirpBody))
+}
+
+func quote(body string) string {
+	body = strings.Join(strings.Fields(body), " ")
+	if utf8.RuneCountInString(body) <= maxQuoteLength {
+		return body
+	}
+	runes := []rune(body)
+	return strings.TrimSpace(string(runes[:maxQuoteLength-1])) + "…"
+}
+
+// Digest renders the email listing items, the newest of the user's total
+// unread notifications.
+func Digest(items []DigestItem, total int) (subject, body string) {
+	if total == 1 {
+		subject = "You have 1 unread notification on Chirpy"
+	} else {
+		subject = fmt.Sprintf("You have %d unread notifications on Chirpy", total)
+	}
+
+	var b strings.Builder
+	b.WriteString("Here's what you missed:\n\n")
+	for _, item := range items {
+		fmt.Fprintf(&b, "- %s\n", Describe(item))
+	}
+	if more := total - len(items); more > 0 {
+		fmt.Fprintf(&b, "- and %d more\n", more)
+	}
+	b.WriteString("\nYou can turn off these emails in your notification preferences.\n")
+	return subject, b.String()
+}
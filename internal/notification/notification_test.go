@@ -0,0 +1,103 @@
+package notification
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "none", text: "hello world", want: nil},
+		{name: "one", text: "hi @alice", want: []string{"alice"}},
+		{name: "start of text", text: "@bob: thanks", want: []string{"bob"}},
+		{name: "several in order", text: "@carol and @Bob, meet @dave_2", want: []string{"carol", "bob", "dave_2"}},
+		{name: "repeated in any case", text: "@Alice @alice @ALICE", want: []string{"alice"}},
+		{name: "punctuation after", text: "(@alice) @bob!", want: []string{"alice", "bob"}},
+		{name: "email address", text: "mail me at me@example.com", want: nil},
+		{name: "double at", text: "@@alice", want: nil},
+		{name: "too long", text: "@" + strings.Repeat("a", MaxUsernameLength+1), want: nil},
+		{name: "longest", text: "@" + strings.Repeat("a", MaxUsernameLength), want: []string{strings.Repeat("a", MaxUsernameLength)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Mentions(tt.text)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Mentions(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidUsername(t *testing.T) {
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{"alice", true},
+		{"Alice_99", true},
+		{strings.Repeat("a", MaxUsernameLength), true},
+		{"", false},
+		{strings.Repeat("a", MaxUsernameLength+1), false},
+		{"al ice", false},
+		{"alice.b", false},
+		{"@alice", false},
+		{"ålice", false},
+	}
+	for _, tt := range tests {
+		if got := ValidUsername(tt.username); got != tt.want {
+			t.Errorf("ValidUsername(%q) = %v, want %v", tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	long := strings.Repeat("word ", 30)
+	tests := []struct {
+		name string
+		item DigestItem
+		want string
+	}{
+		{name: "follow", item: DigestItem{Type: TypeFollow, Actor: "@alice"}, want: "@alice followed you"},
+		{name: "like", item: DigestItem{Type: TypeLike, Actor: "@alice", ChirpBody: "hello"}, want: `@alice liked your chirp: "hello"`},
+		{name: "reply", item: DigestItem{Type: TypeReply, Actor: "Bob", ChirpBody: "same\nhere"}, want: `Bob replied to your chirp: "same here"`},
+		{name: "mention without chirp", item: DigestItem{Type: TypeMention, Actor: "Someone"}, want: "Someone mentioned you"},
+		{name: "long chirp", item: DigestItem{Type: TypeLike, Actor: "@alice", ChirpBody: long}, want: `@alice liked your chirp: "` + strings.TrimSpace(long[:79]) + `…"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Describe(tt.item); got != tt.want {
+				t.Errorf("Describe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	items := []DigestItem{
+		{Type: TypeFollow, Actor: "@alice"},
+		{Type: TypeLike, Actor: "@bob", ChirpBody: "hi"},
+	}
+
+	subject, body := Digest(items, 5)
+	if subject != "You have 5 unread notifications on Chirpy" {
+		t.Errorf("subject = %q", subject)
+	}
+	for _, want := range []string{"- @alice followed you\n", "- @bob liked your chirp: \"hi\"\n", "- and 3 more\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body is missing %q:\n%s", want, body)
+		}
+	}
+
+	subject, body = Digest(items[:1], 1)
+	if subject != "You have 1 unread notification on Chirpy" {
+		t.Errorf("subject = %q", subject)
+	}
+	if strings.Contains(body, "more") {
+		t.Errorf("body mentions more notifications than there are:\n%s", body)
+	}
+}
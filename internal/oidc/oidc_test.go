@@ -0,0 +1,285 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeProvider is a minimal in-process OpenID Connect provider. It issues
+// an ID token for whatever claims were queued for the next code.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu         sync.Mutex
+	keys       map[string]*rsa.PrivateKey
+	signingKid string
+	jwksHits   int
+	codes      map[string]fakeCode
+}
+
+type fakeCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	f := &fakeProvider{t: t, keys: map[string]*rsa.PrivateKey{}, codes: map[string]fakeCode{}}
+	f.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.jwksHits++
+		var keys []map[string]string
+		for kid, key := range f.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != "chirpy" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		f.mu.Lock()
+		code, ok := f.codes[r.PostFormValue("code")]
+		delete(f.codes, r.PostFormValue("code"))
+		f.mu.Unlock()
+		if !ok || CodeChallenge(r.PostFormValue("code_verifier")) != code.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "unused",
+			"token_type":   "Bearer",
+			"id_token":     f.sign(code.claims),
+		})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeProvider) jwksRequests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.jwksHits
+}
+
+func (f *fakeProvider) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		f.t.Fatalf("error generating key: %v", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys[kid] = key
+	f.signingKid = kid
+}
+
+func (f *fakeProvider) sign(claims jwt.MapClaims) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = f.signingKid
+	signed, err := token.SignedString(f.keys[f.signingKid])
+	if err != nil {
+		f.t.Fatalf("error signing token: %v", err)
+	}
+	return signed
+}
+
+// authorize stands in for the user signing in at the provider and returns
+// the code the provider would redirect back with.
+func (f *fakeProvider) authorize(authURL string, claims jwt.MapClaims) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatalf("invalid auth URL: %v", err)
+	}
+	q := u.Query()
+	if claims["nonce"] == nil {
+		claims["nonce"] = q.Get("nonce")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := "code-" + q.Get("state")
+	f.codes[code] = fakeCode{challenge: q.Get("code_challenge"), claims: claims}
+	return code
+}
+
+func (f *fakeProvider) claims(overrides jwt.MapClaims) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            f.server.URL,
+		"sub":            "user-123",
+		"aud":            "chirpy",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          "walt@example.com",
+		"email_verified": true,
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+	return claims
+}
+
+func (f *fakeProvider) newProvider() *Provider {
+	return NewProvider(Config{
+		Issuer:       f.server.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/api/login/oidc/callback",
+		HTTPClient:   f.server.Client(),
+	})
+}
+
+const testVerifier = "abcdefghijklmnopqrstuvwxyz0123456789-._~ABCDEFG"
+
+func TestExchange(t *testing.T) {
+	fake := newFakeProvider(t)
+
+	tests := []struct {
+		name      string
+		overrides jwt.MapClaims
+		want      Claims
+		wantErr   bool
+	}{
+		{
+			name: "Valid ID token",
+			want: Claims{Subject: "user-123", Email: "walt@example.com", EmailVerified: true},
+		},
+		{
+			name:      "email_verified as a string",
+			overrides: jwt.MapClaims{"email_verified": "true"},
+			want:      Claims{Subject: "user-123", Email: "walt@example.com", EmailVerified: true},
+		},
+		{
+			name:      "Unverified email",
+			overrides: jwt.MapClaims{"email_verified": false},
+			want:      Claims{Subject: "user-123", Email: "walt@example.com", EmailVerified: false},
+		},
+		{name: "Wrong audience", overrides: jwt.MapClaims{"aud": "someone-else"}, wantErr: true},
+		{name: "Wrong issuer", overrides: jwt.MapClaims{"iss": "https://evil.example.com"}, wantErr: true},
+		{name: "Expired", overrides: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, wantErr: true},
+		{name: "Wrong nonce", overrides: jwt.MapClaims{"nonce": "replayed"}, wantErr: true},
+		{
+			name:      "Multiple audiences without azp",
+			overrides: jwt.MapClaims{"aud": []string{"chirpy", "other"}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := fake.newProvider()
+			authURL, err := p.AuthCodeURL(context.Background(), tt.name, "nonce-1", testVerifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL() error = %v", err)
+			}
+			code := fake.authorize(authURL, fake.claims(tt.overrides))
+
+			got, err := p.Exchange(context.Background(), code, testVerifier, "nonce-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			tt.want.Issuer = fake.server.URL
+			if got != tt.want {
+				t.Errorf("Exchange() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	fake := newFakeProvider(t)
+	p := fake.newProvider()
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", testVerifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	code := fake.authorize(authURL, fake.claims(nil))
+
+	if _, err := p.Exchange(context.Background(), code, testVerifier+"x", "nonce"); err == nil {
+		t.Errorf("Exchange() expected error for wrong code verifier")
+	}
+}
+
+func TestKeyCaching(t *testing.T) {
+	fake := newFakeProvider(t)
+	p := fake.newProvider()
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.VerifyIDToken(ctx, fake.sign(fake.claims(jwt.MapClaims{"nonce": "n"})), "n"); err != nil {
+			t.Fatalf("VerifyIDToken() error = %v", err)
+		}
+	}
+	if hits := fake.jwksRequests(); hits != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", hits)
+	}
+
+	// A token signed with a new key is rejected until the refresh interval
+	// has passed, then the key set is fetched again.
+	fake.rotateKey("key-2")
+	rotated := fake.sign(fake.claims(jwt.MapClaims{"nonce": "n"}))
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("VerifyIDToken() error = %v, want %v", err, ErrUnknownKey)
+	}
+	now = now.Add(keyRefreshInterval)
+	if _, err := p.VerifyIDToken(ctx, rotated, "n"); err != nil {
+		t.Errorf("VerifyIDToken() after refresh interval error = %v", err)
+	}
+	if hits := fake.jwksRequests(); hits != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", hits)
+	}
+}
+
+func TestDiscoveryFailure(t *testing.T) {
+	fake := newFakeProvider(t)
+	p := NewProvider(Config{
+		Issuer:     fake.server.URL + "/tenant",
+		ClientID:   "chirpy",
+		HTTPClient: fake.server.Client(),
+	})
+	if _, err := p.AuthCodeURL(context.Background(), "s", "n", testVerifier); err == nil {
+		t.Errorf("AuthCodeURL() expected error when discovery fails")
+	}
+}
@@ -0,0 +1,143 @@
+// Package outbox implements a transactional outbox. Domain events are
+// written to the database in the same transaction as the change they
+// describe, and a Dispatcher later hands them to in-process subscribers.
+// Delivery is at least once: an event is retried until every subscriber
+// has accepted it in the same attempt, so subscribers must be idempotent.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a domain event read back from the outbox.
+type Event struct {
+	ID   uuid.UUID
+	Type string
+	// UserID is the user the event is about, if any.
+	UserID    uuid.NullUUID
+	Payload   json.RawMessage
+	CreatedAt time.Time
+	// Attempts counts dispatches of the event, including the current one.
+	Attempts int
+}
+
+// Handler reacts to an event. Returning an error makes the dispatcher try
+// the event again later.
+type Handler func(ctx context.Context, event Event) error
+
+// Store reads and updates stored events.
+type Store interface {
+	// Claim returns up to limit events that are due, and hides them from
+	// other claims until leaseUntil.
+	Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Event, error)
+	// Complete marks an event as dispatched.
+	Complete(ctx context.Context, id uuid.UUID) error
+	// Retry schedules another dispatch at retryAt.
+	Retry(ctx context.Context, id uuid.UUID, retryAt time.Time, reason string) error
+	// Abandon stops retrying an event.
+	Abandon(ctx context.Context, id uuid.UUID, reason string) error
+}
+
+type Policy struct {
+	// MaxAttempts is how many times an event is dispatched before it is
+	// abandoned.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with every
+	// further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait.
+	MaxDelay time.Duration
+	// Lease is how long a claimed event is hidden from other dispatchers.
+	// It must be longer than the slowest handler.
+	Lease time.Duration
+}
+
+func (p Policy) delay(attempts int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempts-1))
+	if delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+const claimBatch = 50
+
+type Dispatcher struct {
+	store    Store
+	policy   Policy
+	handlers map[string][]Handler
+	now      func() time.Time
+}
+
+func NewDispatcher(store Store, policy Policy) *Dispatcher {
+	return &Dispatcher{
+		store:    store,
+		policy:   policy,
+		handlers: map[string][]Handler{},
+		now:      time.Now,
+	}
+}
+
+// Subscribe registers h for events of eventType. It must be called before
+// dispatching starts.
+func (d *Dispatcher) Subscribe(eventType string, h Handler) {
+	d.handlers[eventType] = append(d.handlers[eventType], h)
+}
+
+// DispatchPending hands every due event to its subscribers, and returns
+// how many events were dispatched successfully.
+func (d *Dispatcher) DispatchPending(ctx context.Context) (int, error) {
+	dispatched := 0
+	for {
+		now := d.now().UTC()
+		events, err := d.store.Claim(ctx, now, now.Add(d.policy.Lease), claimBatch)
+		if err != nil {
+			return dispatched, err
+		}
+		if len(events) == 0 {
+			return dispatched, nil
+		}
+		for _, event := range events {
+			if d.dispatch(ctx, event) {
+				dispatched++
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event Event) bool {
+	var handlerErr error
+	for _, h := range d.handlers[event.Type] {
+		if err := h(ctx, event); err != nil {
+			handlerErr = err
+			break
+		}
+	}
+
+	if handlerErr == nil {
+		if err := d.store.Complete(ctx, event.ID); err != nil {
+			log.Printf("error completing outbox event %s: %v\n", event.ID, err)
+		}
+		return true
+	}
+
+	reason := handlerErr.Error()
+	if event.Attempts >= d.policy.MaxAttempts {
+		log.Printf("abandoning outbox event %s after %d attempts: %v\n", event.ID, event.Attempts, handlerErr)
+		if err := d.store.Abandon(ctx, event.ID, reason); err != nil {
+			log.Printf("error abandoning outbox event %s: %v\n", event.ID, err)
+		}
+		return false
+	}
+	retryAt := d.now().UTC().Add(d.policy.delay(event.Attempts))
+	if err := d.store.Retry(ctx, event.ID, retryAt, reason); err != nil {
+		log.Printf("error rescheduling outbox event %s: %v\n", event.ID, err)
+	}
+	return false
+}
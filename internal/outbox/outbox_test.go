@@ -0,0 +1,138 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memoryEvent struct {
+	Event
+	status string
+	due    time.Time
+	reason string
+}
+
+// memoryStore is a Store for tests.
+type memoryStore struct {
+	events []*memoryEvent
+}
+
+func (s *memoryStore) add(eventType string) *memoryEvent {
+	e := &memoryEvent{Event: Event{ID: uuid.New(), Type: eventType}, status: "pending"}
+	s.events = append(s.events, e)
+	return e
+}
+
+func (s *memoryStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Event, error) {
+	var claimed []Event
+	for _, e := range s.events {
+		if len(claimed) == limit {
+			break
+		}
+		if e.status == "pending" && !e.due.After(now) {
+			e.Attempts++
+			e.due = leaseUntil
+			claimed = append(claimed, e.Event)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) find(id uuid.UUID) *memoryEvent {
+	for _, e := range s.events {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Complete(ctx context.Context, id uuid.UUID) error {
+	s.find(id).status = "dispatched"
+	return nil
+}
+
+func (s *memoryStore) Retry(ctx context.Context, id uuid.UUID, retryAt time.Time, reason string) error {
+	e := s.find(id)
+	e.due, e.reason = retryAt, reason
+	return nil
+}
+
+func (s *memoryStore) Abandon(ctx context.Context, id uuid.UUID, reason string) error {
+	e := s.find(id)
+	e.status, e.reason = "failed", reason
+	return nil
+}
+
+func TestDispatchPending(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &memoryStore{}
+	d := NewDispatcher(store, Policy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Lease: 5 * time.Minute})
+	d.now = func() time.Time { return now }
+
+	var created, audited int
+	failing := true
+	d.Subscribe("chirp.created", func(ctx context.Context, e Event) error {
+		created++
+		return nil
+	})
+	d.Subscribe("chirp.created", func(ctx context.Context, e Event) error {
+		audited++
+		if failing {
+			return errors.New("audit log unavailable")
+		}
+		return nil
+	})
+
+	chirp := store.add("chirp.created")
+	other := store.add("user.upgraded") // no subscribers
+
+	n, err := d.DispatchPending(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("DispatchPending() = %d, %v, want 1", n, err)
+	}
+	if other.status != "dispatched" {
+		t.Errorf("event without subscribers is %s, want dispatched", other.status)
+	}
+	if chirp.status != "pending" || !chirp.due.Equal(now.Add(time.Minute)) || chirp.reason != "audit log unavailable" {
+		t.Errorf("failed event = %+v, want a retry in a minute", chirp)
+	}
+
+	// Not due yet.
+	if n, _ := d.DispatchPending(context.Background()); n != 0 {
+		t.Errorf("DispatchPending() before the retry = %d, want 0", n)
+	}
+
+	// The retry succeeds, and the first subscriber sees the event again.
+	now = now.Add(time.Minute)
+	failing = false
+	if n, _ := d.DispatchPending(context.Background()); n != 1 {
+		t.Errorf("DispatchPending() on retry = %d, want 1", n)
+	}
+	if chirp.status != "dispatched" || created != 2 || audited != 2 {
+		t.Errorf("after retry: status %s, created %d, audited %d", chirp.status, created, audited)
+	}
+}
+
+func TestDispatchPendingAbandons(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &memoryStore{}
+	d := NewDispatcher(store, Policy{MaxAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Lease: 5 * time.Minute})
+	d.now = func() time.Time { return now }
+	d.Subscribe("chirp.deleted", func(ctx context.Context, e Event) error {
+		return errors.New("boom")
+	})
+
+	e := store.add("chirp.deleted")
+	d.DispatchPending(context.Background())
+	now = now.Add(time.Hour)
+	d.DispatchPending(context.Background())
+
+	if e.status != "failed" || e.Attempts != 2 {
+		t.Errorf("event = %+v, want failed after 2 attempts", e)
+	}
+}
@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// Enqueue writes an event to the outbox_events table. Pass queries bound
+// to the transaction that makes the change the event describes, so that
+// the event is stored if and only if the change is.
+func Enqueue(ctx context.Context, q *database.Queries, eventType string, userID uuid.NullUUID, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("outbox: encoding %s: %w", eventType, err)
+	}
+	return q.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventType: eventType,
+		UserID:    userID,
+		Payload:   payload,
+	})
+}
+
+// PostgresStore keeps events in the outbox_events table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Event, error) {
+	rows, err := s.db.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{
+		LeaseUntil: leaseUntil,
+		Now:        now,
+		MaxEvents:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, Event{
+			ID:        row.ID,
+			Type:      row.EventType,
+			UserID:    row.UserID,
+			Payload:   row.Payload,
+			CreatedAt: row.CreatedAt,
+			Attempts:  int(row.Attempts),
+		})
+	}
+	return events, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, id uuid.UUID) error {
+	return s.db.CompleteOutboxEvent(ctx, id)
+}
+
+func (s *PostgresStore) Retry(ctx context.Context, id uuid.UUID, retryAt time.Time, reason string) error {
+	return s.db.FailOutboxEvent(ctx, database.FailOutboxEventParams{
+		ID:            id,
+		Status:        "pending",
+		NextAttemptAt: retryAt,
+		LastError:     sql.NullString{String: reason, Valid: true},
+	})
+}
+
+func (s *PostgresStore) Abandon(ctx context.Context, id uuid.UUID, reason string) error {
+	return s.db.FailOutboxEvent(ctx, database.FailOutboxEventParams{
+		ID:            id,
+		Status:        "failed",
+		NextAttemptAt: time.Now().UTC(),
+		LastError:     sql.NullString{String: reason, Valid: true},
+	})
+}
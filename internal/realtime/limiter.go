@@ -0,0 +1,34 @@
+package realtime
+
+import "time"
+
+// Limiter is a token bucket limiting how many messages a connection may
+// send. It is not safe for concurrent use; each connection has its own.
+type Limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter allows rate messages a second on average, and bursts of up
+// to burst messages.
+func NewLimiter(rate, burst int) *Limiter {
+	return &Limiter{rate: float64(rate), burst: float64(burst), tokens: float64(burst)}
+}
+
+// Allow takes a token if one is available at now.
+func (l *Limiter) Allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,62 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLimiter(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow(now) {
+			t.Fatalf("message %d of the burst was refused", i+1)
+		}
+	}
+	if l.Allow(now) {
+		t.Errorf("Allow() past the burst = true, want false")
+	}
+
+	// Two tokens a second: one is back after half a second.
+	now = now.Add(500 * time.Millisecond)
+	if !l.Allow(now) {
+		t.Errorf("Allow() after refill = false, want true")
+	}
+	if l.Allow(now) {
+		t.Errorf("Allow() with an empty bucket = true, want false")
+	}
+
+	// A long pause refills no more than the burst.
+	now = now.Add(time.Hour)
+	allowed := 0
+	for l.Allow(now) {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d messages after a pause, want 3", allowed)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	p := NewPresence()
+	user := uuid.New()
+
+	if !p.Join(user) {
+		t.Errorf("first Join() = false, want true")
+	}
+	if p.Join(user) {
+		t.Errorf("second Join() = true, want false")
+	}
+	if p.Leave(user) {
+		t.Errorf("Leave() with a connection left = true, want false")
+	}
+	if !p.Leave(user) {
+		t.Errorf("last Leave() = false, want true")
+	}
+	if p.Leave(user) {
+		t.Errorf("Leave() without connections = true, want false")
+	}
+}
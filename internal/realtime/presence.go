@@ -0,0 +1,44 @@
+package realtime
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Presence counts the open connections of each user on this server, so
+// that a user with several devices only goes offline when the last one
+// disconnects.
+type Presence struct {
+	mu     sync.Mutex
+	counts map[uuid.UUID]int
+}
+
+func NewPresence() *Presence {
+	return &Presence{counts: map[uuid.UUID]int{}}
+}
+
+// Join records a new connection, and reports whether it is the user's
+// first.
+func (p *Presence) Join(userID uuid.UUID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counts[userID]++
+	return p.counts[userID] == 1
+}
+
+// Leave records a closed connection, and reports whether it was the
+// user's last.
+func (p *Presence) Leave(userID uuid.UUID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.counts[userID] == 0 {
+		return false
+	}
+	p.counts[userID]--
+	if p.counts[userID] == 0 {
+		delete(p.counts, userID)
+		return true
+	}
+	return false
+}
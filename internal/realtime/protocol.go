@@ -0,0 +1,165 @@
+// Package realtime holds the transport-independent parts of the WebSocket
+// API: the message protocol, what each connection is subscribed to, rate
+// limiting and presence tracking.
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+)
+
+// Channels a connection can subscribe to.
+const (
+	// ChannelTimeline carries chirp.created and chirp.deleted events, from
+	// everyone or from the listed users.
+	ChannelTimeline = "timeline"
+	// ChannelNotifications carries events about the connected user.
+	ChannelNotifications = "notifications"
+	// ChannelPresence carries presence changes of the listed users.
+	ChannelPresence = "presence"
+	// ChannelTyping carries typing signals sent to the connected user.
+	ChannelTyping = "typing"
+)
+
+// Messages sent by clients.
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeAuth        = "auth"
+	TypeTyping      = "typing"
+	TypePing        = "ping"
+)
+
+// Messages sent by the server.
+const (
+	TypeEvent        = "event"
+	TypeAck          = "ack"
+	TypeError        = "error"
+	TypePong         = "pong"
+	TypeAuthExpiring = "auth_expiring"
+)
+
+// Signal events exchanged between connections. They are not stored.
+const (
+	EventTyping   = "typing"
+	EventPresence = "presence"
+)
+
+// MaxUserIDs caps the users a single subscription can list.
+const MaxUserIDs = 100
+
+// ClientMessage is a message from a client. ID is optional, and is echoed
+// in the ack or error that answers the message.
+type ClientMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Channel string      `json:"channel,omitempty"`
+	UserIDs []uuid.UUID `json:"user_ids,omitempty"`
+	Token   string      `json:"token,omitempty"`
+	To      uuid.UUID   `json:"to,omitempty"`
+}
+
+// ServerMessage is a message to a client.
+type ServerMessage struct {
+	ID        string        `json:"id,omitempty"`
+	Type      string        `json:"type"`
+	Channel   string        `json:"channel,omitempty"`
+	Event     *stream.Event `json:"event,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+}
+
+// TypingData is the payload of a typing signal.
+type TypingData struct {
+	From uuid.UUID `json:"from"`
+}
+
+// PresenceData is the payload of a presence signal.
+type PresenceData struct {
+	Online bool `json:"online"`
+}
+
+var (
+	ErrUnknownChannel  = errors.New("unknown channel")
+	ErrUserIDsRequired = errors.New("user_ids is required for this channel")
+	ErrTooManyUserIDs  = fmt.Errorf("at most %d user_ids are allowed", MaxUserIDs)
+)
+
+// Subscriptions is what a connection has asked to receive. The zero value
+// is subscribed to nothing.
+type Subscriptions struct {
+	// channels maps each subscribed channel to the users it is narrowed
+	// to, or to nil for every user.
+	channels map[string]map[uuid.UUID]bool
+}
+
+// Subscribe adds a channel, replacing any earlier subscription to it.
+// userIDs narrows the timeline to those authors, and is required for
+// presence.
+func (s *Subscriptions) Subscribe(channel string, userIDs []uuid.UUID) error {
+	switch channel {
+	case ChannelTimeline, ChannelNotifications, ChannelTyping:
+	case ChannelPresence:
+		if len(userIDs) == 0 {
+			return ErrUserIDsRequired
+		}
+	default:
+		return ErrUnknownChannel
+	}
+	if len(userIDs) > MaxUserIDs {
+		return ErrTooManyUserIDs
+	}
+
+	var users map[uuid.UUID]bool
+	if len(userIDs) > 0 {
+		users = make(map[uuid.UUID]bool, len(userIDs))
+		for _, id := range userIDs {
+			users[id] = true
+		}
+	}
+	if s.channels == nil {
+		s.channels = map[string]map[uuid.UUID]bool{}
+	}
+	s.channels[channel] = users
+	return nil
+}
+
+func (s *Subscriptions) Unsubscribe(channel string) {
+	delete(s.channels, channel)
+}
+
+func (s *Subscriptions) Has(channel string) bool {
+	_, ok := s.channels[channel]
+	return ok
+}
+
+// Route returns the channel an event belongs on for the connection of
+// user me, and whether the connection wants it.
+func (s *Subscriptions) Route(me uuid.UUID, event stream.Event) (string, bool) {
+	var channel string
+	switch event.Type {
+	case EventPresence:
+		channel = ChannelPresence
+	case EventTyping:
+		channel = ChannelTyping
+	case "chirp.created", "chirp.deleted":
+		channel = ChannelTimeline
+	default:
+		channel = ChannelNotifications
+	}
+
+	users, ok := s.channels[channel]
+	if !ok {
+		return channel, false
+	}
+	switch channel {
+	case ChannelTyping, ChannelNotifications:
+		return channel, event.UserID == me
+	default:
+		return channel, users == nil || users[event.UserID]
+	}
+}
@@ -0,0 +1,78 @@
+package realtime
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+)
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		userIDs []uuid.UUID
+		wantErr error
+	}{
+		{name: "timeline", channel: ChannelTimeline},
+		{name: "timeline narrowed", channel: ChannelTimeline, userIDs: []uuid.UUID{uuid.New()}},
+		{name: "presence", channel: ChannelPresence, userIDs: []uuid.UUID{uuid.New()}},
+		{name: "presence of everyone", channel: ChannelPresence, wantErr: ErrUserIDsRequired},
+		{name: "too many users", channel: ChannelTimeline, userIDs: make([]uuid.UUID, MaxUserIDs+1), wantErr: ErrTooManyUserIDs},
+		{name: "unknown channel", channel: "firehose", wantErr: ErrUnknownChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subs Subscriptions
+			err := subs.Subscribe(tt.channel, tt.userIDs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Subscribe() error = %v, want %v", err, tt.wantErr)
+			}
+			if subs.Has(tt.channel) != (tt.wantErr == nil) {
+				t.Errorf("Has(%q) = %v after Subscribe() error %v", tt.channel, subs.Has(tt.channel), err)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	me, friend, stranger := uuid.New(), uuid.New(), uuid.New()
+
+	var subs Subscriptions
+	subs.Subscribe(ChannelTimeline, []uuid.UUID{friend})
+	subs.Subscribe(ChannelPresence, []uuid.UUID{friend})
+	subs.Subscribe(ChannelTyping, nil)
+
+	tests := []struct {
+		name        string
+		event       stream.Event
+		wantChannel string
+		wantOK      bool
+	}{
+		{name: "friend's chirp", event: stream.Event{Type: "chirp.created", UserID: friend}, wantChannel: ChannelTimeline, wantOK: true},
+		{name: "stranger's chirp", event: stream.Event{Type: "chirp.deleted", UserID: stranger}, wantChannel: ChannelTimeline},
+		{name: "friend comes online", event: stream.Event{Type: EventPresence, UserID: friend}, wantChannel: ChannelPresence, wantOK: true},
+		{name: "stranger comes online", event: stream.Event{Type: EventPresence, UserID: stranger}, wantChannel: ChannelPresence},
+		{name: "typing to me", event: stream.Event{Type: EventTyping, UserID: me}, wantChannel: ChannelTyping, wantOK: true},
+		{name: "typing to someone else", event: stream.Event{Type: EventTyping, UserID: friend}, wantChannel: ChannelTyping},
+		{name: "not subscribed to notifications", event: stream.Event{Type: "user.upgraded", UserID: me}, wantChannel: ChannelNotifications},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, ok := subs.Route(me, tt.event)
+			if channel != tt.wantChannel || ok != tt.wantOK {
+				t.Errorf("Route() = %q, %v, want %q, %v", channel, ok, tt.wantChannel, tt.wantOK)
+			}
+		})
+	}
+
+	subs.Subscribe(ChannelTimeline, nil)
+	if _, ok := subs.Route(me, stream.Event{Type: "chirp.created", UserID: stranger}); !ok {
+		t.Errorf("Route() dropped a chirp on the full timeline")
+	}
+	subs.Unsubscribe(ChannelTimeline)
+	if _, ok := subs.Route(me, stream.Event{Type: "chirp.created", UserID: friend}); ok {
+		t.Errorf("Route() delivered a chirp after Unsubscribe()")
+	}
+}
@@ -0,0 +1,131 @@
+// Package stream fans live events out to connected clients. A Hub keeps a
+// bounded backlog of recent events so that a client that reconnects with
+// the id of the last event it saw can pick up where it left off.
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is one message on the stream. UserID is the user the event is
+// about, which subscribers can filter on.
+type Event struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	UserID uuid.UUID       `json:"user_id"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Filter decides whether a subscriber receives an event.
+type Filter func(Event) bool
+
+// Subscription receives events published after it was opened. Events is
+// closed when the subscription is closed, or when the subscriber falls so
+// far behind that its buffer fills up.
+type Subscription struct {
+	Events <-chan Event
+
+	hub    *Hub
+	events chan Event
+	filter Filter
+}
+
+// Close stops delivery to the subscription.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+type Hub struct {
+	mu          sync.Mutex
+	backlog     []Event
+	backlogSize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewHub returns a hub remembering the last backlogSize events, and
+// buffering up to bufferSize events for each subscriber.
+func NewHub(backlogSize, bufferSize int) *Hub {
+	return &Hub{
+		backlogSize: backlogSize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish records an event in the backlog and hands it to every matching
+// subscriber. An event whose id is already in the backlog is dropped, so
+// publishing the same event twice is harmless. Subscribers that cannot
+// keep up are disconnected rather than slowing down everyone else.
+func (h *Hub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.indexOf(event.ID) >= 0 {
+		return
+	}
+	h.backlog = append(h.backlog, event)
+	if len(h.backlog) > h.backlogSize {
+		h.backlog = h.backlog[len(h.backlog)-h.backlogSize:]
+	}
+
+	for sub := range h.subscribers {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe opens a subscription. If lastEventID is set, the matching
+// events published after it are returned for replay; resumed is false if
+// that event has already left the backlog, meaning the client may have
+// missed events.
+func (h *Hub) Subscribe(lastEventID string, filter Filter) (sub *Subscription, replay []Event, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan Event, h.bufferSize)
+	sub = &Subscription{Events: events, hub: h, events: events, filter: filter}
+	h.subscribers[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+	i := h.indexOf(lastEventID)
+	if i < 0 {
+		return sub, nil, false
+	}
+	for _, event := range h.backlog[i+1:] {
+		if filter == nil || filter(event) {
+			replay = append(replay, event)
+		}
+	}
+	return sub, replay, true
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (h *Hub) indexOf(id string) int {
+	for i := len(h.backlog) - 1; i >= 0; i-- {
+		if h.backlog[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func event(n int, userID uuid.UUID) Event {
+	return Event{ID: fmt.Sprint(n), Type: "chirp.created", UserID: userID}
+}
+
+func ids(events []Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestHubResume(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	hub := NewHub(3, 10)
+	for n := 1; n <= 5; n++ {
+		user := alice
+		if n%2 == 0 {
+			user = bob
+		}
+		hub.Publish(event(n, user))
+	}
+	// The backlog now holds events 3, 4 and 5.
+
+	onlyAlice := func(e Event) bool { return e.UserID == alice }
+	tests := []struct {
+		name        string
+		lastEventID string
+		filter      Filter
+		wantReplay  []string
+		wantResumed bool
+	}{
+		{name: "fresh connection", wantResumed: true},
+		{name: "resume", lastEventID: "3", wantReplay: []string{"4", "5"}, wantResumed: true},
+		{name: "resume filtered", lastEventID: "3", filter: onlyAlice, wantReplay: []string{"5"}, wantResumed: true},
+		{name: "up to date", lastEventID: "5", wantResumed: true},
+		{name: "fell out of backlog", lastEventID: "1", wantResumed: false},
+		{name: "unknown id", lastEventID: "nope", wantResumed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay, resumed := hub.Subscribe(tt.lastEventID, tt.filter)
+			defer sub.Close()
+			if got := ids(replay); fmt.Sprint(got) != fmt.Sprint(tt.wantReplay) {
+				t.Errorf("replay = %v, want %v", got, tt.wantReplay)
+			}
+			if resumed != tt.wantResumed {
+				t.Errorf("resumed = %v, want %v", resumed, tt.wantResumed)
+			}
+		})
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	hub := NewHub(10, 2)
+
+	all, _, _ := hub.Subscribe("", nil)
+	defer all.Close()
+	filtered, _, _ := hub.Subscribe("", func(e Event) bool { return e.UserID == bob })
+	defer filtered.Close()
+
+	hub.Publish(event(1, alice))
+	hub.Publish(event(1, alice)) // duplicate
+	hub.Publish(event(2, bob))
+
+	if got := (<-all.Events).ID; got != "1" {
+		t.Errorf("first event = %s, want 1", got)
+	}
+	if got := (<-all.Events).ID; got != "2" {
+		t.Errorf("second event = %s, want 2", got)
+	}
+	if got := (<-filtered.Events).ID; got != "2" {
+		t.Errorf("filtered event = %s, want 2", got)
+	}
+
+	// A subscriber that stops reading is cut off once its buffer is full.
+	hub.Publish(event(3, bob))
+	hub.Publish(event(4, bob))
+	hub.Publish(event(5, bob))
+	var received []Event
+	for e := range filtered.Events {
+		received = append(received, e)
+	}
+	if got := ids(received); fmt.Sprint(got) != "[3 4]" {
+		t.Errorf("slow subscriber received %v before being dropped, want [3 4]", got)
+	}
+
+	// Closing twice is harmless.
+	filtered.Close()
+}
@@ -0,0 +1,76 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// Postgres caps NOTIFY payloads at 8000 bytes.
+const maxNotifyPayload = 8000
+
+// Notify broadcasts an event on a Postgres channel, reaching the hub of
+// every server instance that is listening on it. An event too large to
+// notify is sent without its data, which clients take as a cue to reload.
+func Notify(ctx context.Context, q *database.Queries, channel string, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("stream: encoding %s: %w", event.Type, err)
+	}
+	if len(payload) >= maxNotifyPayload {
+		log.Printf("stream: %s event %s is too large to notify (%d bytes), sending it without data\n", event.Type, event.ID, len(payload))
+		event.Data = nil
+		if payload, err = json.Marshal(event); err != nil {
+			return fmt.Errorf("stream: encoding %s: %w", event.Type, err)
+		}
+		if len(payload) >= maxNotifyPayload {
+			log.Printf("stream: dropping %s event %s\n", event.Type, event.ID)
+			return nil
+		}
+	}
+	return q.Notify(ctx, database.NotifyParams{Channel: channel, Payload: string(payload)})
+}
+
+// Listen publishes events notified on channel to hub until ctx is
+// cancelled. It reconnects on its own when the connection drops; events
+// notified while it is disconnected are lost.
+func Listen(ctx context.Context, dbURL, channel string, hub *Hub) error {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("stream listener on %s: %v\n", channel, err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				log.Printf("error decoding %s notification: %v\n", channel, err)
+				continue
+			}
+			hub.Publish(event)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// notifyDB is a database.DBTX that records the payloads passed to
+// pg_notify.
+type notifyDB struct {
+	payloads []string
+}
+
+func (db *notifyDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	db.payloads = append(db.payloads, args[1].(string))
+	return driver.RowsAffected(1), nil
+}
+
+func (db *notifyDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *notifyDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *notifyDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	panic("not supported")
+}
+
+func TestNotify(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name     string
+		event    Event
+		wantData bool
+		wantSent bool
+	}{
+		{
+			name:     "small event",
+			event:    Event{ID: "1", Type: "chirp.created", UserID: userID, Data: json.RawMessage(`{"body":"hi"}`)},
+			wantData: true,
+			wantSent: true,
+		},
+		{
+			name:     "data too large",
+			event:    Event{ID: "2", Type: "chirp.created", UserID: userID, Data: json.RawMessage(`"` + strings.Repeat("a", maxNotifyPayload) + `"`)},
+			wantSent: true,
+		},
+		{
+			name:  "too large without data",
+			event: Event{ID: strings.Repeat("3", maxNotifyPayload), Type: "chirp.created", UserID: userID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &notifyDB{}
+			if err := Notify(context.Background(), database.New(db), "chirp_stream", tt.event); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			if !tt.wantSent {
+				if len(db.payloads) != 0 {
+					t.Errorf("notified %d events, want none", len(db.payloads))
+				}
+				return
+			}
+			if len(db.payloads) != 1 {
+				t.Fatalf("notified %d events, want 1", len(db.payloads))
+			}
+			var got Event
+			if err := json.Unmarshal([]byte(db.payloads[0]), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.event.ID || got.Type != tt.event.Type || got.UserID != userID {
+				t.Errorf("notified %+v, want %+v", got, tt.event)
+			}
+			if hasData := string(got.Data) != "null" && len(got.Data) > 0; hasData != tt.wantData {
+				t.Errorf("notified data = %.40s, want data %v", got.Data, tt.wantData)
+			}
+		})
+	}
+}
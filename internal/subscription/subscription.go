@@ -0,0 +1,149 @@
+// Package subscription tracks the lifecycle of Chirpy Red memberships. It
+// turns billing events into subscription state and leaves storage to the
+// caller.
+package subscription
+
+import (
+	"errors"
+	"time"
+)
+
+// Status is where a subscription is in its lifecycle.
+type Status string
+
+const (
+	// StatusActive is a paid-up subscription.
+	StatusActive Status = "active"
+	// StatusPastDue is a subscription whose last payment failed. It keeps
+	// its benefits until ExpiresAt, in case the payment is retried.
+	StatusPastDue Status = "past_due"
+	// StatusCanceled is a subscription that will not renew. It keeps its
+	// benefits until the end of the period that was paid for.
+	StatusCanceled Status = "canceled"
+	// StatusExpired is a subscription that no longer grants anything.
+	StatusExpired Status = "expired"
+	// StatusLegacy is a membership from before subscriptions were tracked.
+	// It has no paid period and lasts until a billing event changes it.
+	StatusLegacy Status = "legacy"
+)
+
+// Events that change a subscription.
+const (
+	EventUpgraded      = "upgraded"
+	EventRenewed       = "renewed"
+	EventDowngraded    = "downgraded"
+	EventPaymentFailed = "payment_failed"
+	EventExpired       = "expired"
+)
+
+// DefaultPlan is the plan used when a billing event does not name one.
+const DefaultPlan = "red"
+
+// DefaultPeriod is the billing period assumed when an event does not say
+// when the new period ends.
+const DefaultPeriod = 30 * 24 * time.Hour
+
+// ErrNotSubscribed is returned for events that need a live subscription
+// when the user doesn't have one.
+var ErrNotSubscribed = errors.New("user has no active subscription")
+
+// ErrUnknownEvent is returned for event types Apply doesn't handle.
+var ErrUnknownEvent = errors.New("unknown subscription event")
+
+// Subscription is the state of one user's membership.
+type Subscription struct {
+	Plan             string
+	Status           Status
+	CurrentPeriodEnd time.Time
+	// ExpiresAt is when the benefits stop unless the subscription is
+	// renewed first. For active subscriptions it is the end of the period
+	// plus the grace period.
+	ExpiresAt time.Time
+}
+
+// Event is a billing event for a user.
+type Event struct {
+	Type string
+	// Plan is optional. Upgrades default to DefaultPlan, and renewals keep
+	// the current plan.
+	Plan string
+	// PeriodEnd is optional. When it is zero the new period runs for
+	// DefaultPeriod.
+	PeriodEnd time.Time
+	At        time.Time
+}
+
+// Active reports whether the subscription grants its plan's benefits at
+// time now.
+func (s Subscription) Active(now time.Time) bool {
+	return s.Status != StatusExpired && now.Before(s.ExpiresAt)
+}
+
+// Apply returns the subscription that results from event. current is nil
+// when the user has never subscribed. grace is how long an active
+// subscription keeps its benefits after the period ends without a renewal.
+// Apply is not idempotent: a renewal applied twice extends the period twice,
+// so callers must make sure each billing event is applied once.
+func Apply(current *Subscription, event Event, grace time.Duration) (Subscription, error) {
+	live := current != nil && current.Active(event.At)
+
+	switch event.Type {
+	case EventUpgraded, EventRenewed:
+		plan := event.Plan
+		if plan == "" && event.Type == EventRenewed && current != nil {
+			plan = current.Plan
+		}
+		if plan == "" {
+			plan = DefaultPlan
+		}
+		periodEnd := event.PeriodEnd
+		if periodEnd.IsZero() {
+			// A renewal extends the period that was paid for, an upgrade
+			// starts a new one.
+			start := event.At
+			if event.Type == EventRenewed && live && current.Status != StatusLegacy && current.CurrentPeriodEnd.After(start) {
+				start = current.CurrentPeriodEnd
+			}
+			periodEnd = start.Add(DefaultPeriod)
+		}
+		return Subscription{
+			Plan:             plan,
+			Status:           StatusActive,
+			CurrentPeriodEnd: periodEnd,
+			ExpiresAt:        periodEnd.Add(grace),
+		}, nil
+
+	case EventPaymentFailed:
+		if !live {
+			return Subscription{}, ErrNotSubscribed
+		}
+		next := *current
+		switch next.Status {
+		case StatusActive:
+			next.Status = StatusPastDue
+		case StatusLegacy:
+			// Without a paid period the grace period starts now.
+			next.Status = StatusPastDue
+			next.CurrentPeriodEnd = event.At
+			next.ExpiresAt = event.At.Add(grace)
+		}
+		return next, nil
+
+	case EventDowngraded:
+		if !live {
+			return Subscription{}, ErrNotSubscribed
+		}
+		next := *current
+		if next.Status == StatusLegacy {
+			// There is no paid period left to run out.
+			next.CurrentPeriodEnd = event.At
+		}
+		next.Status = StatusCanceled
+		// A canceled subscription has no grace period.
+		if next.CurrentPeriodEnd.Before(next.ExpiresAt) {
+			next.ExpiresAt = next.CurrentPeriodEnd
+		}
+		return next, nil
+	}
+	return Subscription{}, ErrUnknownEvent
+}
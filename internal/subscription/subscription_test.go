@@ -0,0 +1,131 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	grace := 3 * 24 * time.Hour
+	periodEnd := now.Add(10 * 24 * time.Hour)
+
+	active := &Subscription{Plan: "red", Status: StatusActive, CurrentPeriodEnd: periodEnd, ExpiresAt: periodEnd.Add(grace)}
+	legacyEnd := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	legacy := &Subscription{Plan: "red", Status: StatusLegacy, CurrentPeriodEnd: legacyEnd, ExpiresAt: legacyEnd}
+	lapsed := &Subscription{Plan: "red", Status: StatusExpired, CurrentPeriodEnd: now.Add(-grace * 2), ExpiresAt: now.Add(-grace)}
+
+	tests := []struct {
+		name    string
+		current *Subscription
+		event   Event
+		want    Subscription
+		wantErr error
+	}{
+		{
+			name:  "upgrade starts a default period",
+			event: Event{Type: EventUpgraded, At: now},
+			want:  Subscription{Plan: DefaultPlan, Status: StatusActive, CurrentPeriodEnd: now.Add(DefaultPeriod), ExpiresAt: now.Add(DefaultPeriod + grace)},
+		},
+		{
+			name:  "upgrade uses the event's plan and period",
+			event: Event{Type: EventUpgraded, Plan: "red_annual", PeriodEnd: periodEnd, At: now},
+			want:  Subscription{Plan: "red_annual", Status: StatusActive, CurrentPeriodEnd: periodEnd, ExpiresAt: periodEnd.Add(grace)},
+		},
+		{
+			name:    "renewal extends the paid period",
+			current: active,
+			event:   Event{Type: EventRenewed, At: now},
+			want:    Subscription{Plan: "red", Status: StatusActive, CurrentPeriodEnd: periodEnd.Add(DefaultPeriod), ExpiresAt: periodEnd.Add(DefaultPeriod + grace)},
+		},
+		{
+			name:    "renewal of an expired subscription starts now",
+			current: lapsed,
+			event:   Event{Type: EventRenewed, At: now},
+			want:    Subscription{Plan: "red", Status: StatusActive, CurrentPeriodEnd: now.Add(DefaultPeriod), ExpiresAt: now.Add(DefaultPeriod + grace)},
+		},
+		{
+			name:    "payment failure keeps the grace period",
+			current: active,
+			event:   Event{Type: EventPaymentFailed, At: now},
+			want:    Subscription{Plan: "red", Status: StatusPastDue, CurrentPeriodEnd: periodEnd, ExpiresAt: periodEnd.Add(grace)},
+		},
+		{
+			name:    "downgrade ends at the period end",
+			current: active,
+			event:   Event{Type: EventDowngraded, At: now},
+			want:    Subscription{Plan: "red", Status: StatusCanceled, CurrentPeriodEnd: periodEnd, ExpiresAt: periodEnd},
+		},
+		{
+			name:    "renewal of a legacy membership starts now",
+			current: legacy,
+			event:   Event{Type: EventRenewed, At: now},
+			want:    Subscription{Plan: "red", Status: StatusActive, CurrentPeriodEnd: now.Add(DefaultPeriod), ExpiresAt: now.Add(DefaultPeriod + grace)},
+		},
+		{
+			name:    "payment failure on a legacy membership starts the grace period",
+			current: legacy,
+			event:   Event{Type: EventPaymentFailed, At: now},
+			want:    Subscription{Plan: "red", Status: StatusPastDue, CurrentPeriodEnd: now, ExpiresAt: now.Add(grace)},
+		},
+		{
+			name:    "downgrade of a legacy membership ends it",
+			current: legacy,
+			event:   Event{Type: EventDowngraded, At: now},
+			want:    Subscription{Plan: "red", Status: StatusCanceled, CurrentPeriodEnd: now, ExpiresAt: now},
+		},
+		{
+			name:    "downgrade without a subscription",
+			event:   Event{Type: EventDowngraded, At: now},
+			wantErr: ErrNotSubscribed,
+		},
+		{
+			name:    "payment failure on an expired subscription",
+			current: lapsed,
+			event:   Event{Type: EventPaymentFailed, At: now},
+			wantErr: ErrNotSubscribed,
+		},
+		{
+			name:    "unknown event",
+			current: active,
+			event:   Event{Type: "refunded", At: now},
+			wantErr: ErrUnknownEvent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.current, tt.event, grace)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActive(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		sub  Subscription
+		want bool
+	}{
+		{"active", Subscription{Status: StatusActive, ExpiresAt: now.Add(time.Hour)}, true},
+		{"in grace period", Subscription{Status: StatusPastDue, CurrentPeriodEnd: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}, true},
+		{"past expiry", Subscription{Status: StatusCanceled, ExpiresAt: now.Add(-time.Hour)}, false},
+		{"expired", Subscription{Status: StatusExpired, ExpiresAt: now.Add(time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sub.Active(now); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
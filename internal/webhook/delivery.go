@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers set on outgoing webhook requests.
+const (
+	HeaderEvent     = "X-Chirpy-Event"
+	HeaderDelivery  = "X-Chirpy-Delivery"
+	HeaderTimestamp = "X-Chirpy-Timestamp"
+	HeaderSignature = "X-Chirpy-Signature"
+)
+
+// Delivery is one signed request to a subscriber.
+type Delivery struct {
+	ID     string
+	Event  string
+	URL    string
+	Secret string
+	Body   []byte
+}
+
+// Sender posts deliveries to subscribers.
+type Sender struct {
+	Client *http.Client
+	Now    func() time.Time
+}
+
+// StatusError is returned when the subscriber answered with a status
+// outside 2xx.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook: subscriber responded with %d", e.StatusCode)
+}
+
+// Send signs d and posts it. It returns the response status code, or zero
+// if no response was received.
+func (s *Sender) Send(ctx context.Context, d Delivery) (int, error) {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	timestamp := now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, d.Body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp.StatusCode, nil
+}
+
+// RetryPolicy decides when a failed delivery is tried again.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with every
+	// further failure.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait.
+	MaxDelay time.Duration
+}
+
+// Backoff returns how long to wait after the given number of failed
+// attempts, and false if the delivery should be given up on.
+func (p RetryPolicy) Backoff(attempts int) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(attempts-1))
+	if delay > float64(p.MaxDelay) {
+		return p.MaxDelay, true
+	}
+	return time.Duration(delay), true
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"type":"chirp.created"}`)
+
+	var received *http.Request
+	var receivedBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		receivedBody, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	s := &Sender{Client: receiver.Client(), Now: func() time.Time { return now }}
+	d := Delivery{ID: "dlv_1", Event: "chirp.created", URL: receiver.URL + "/hook", Secret: "shh", Body: body}
+
+	status, err := s.Send(context.Background(), d)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send() = %d, %v", status, err)
+	}
+	if received.Header.Get(HeaderEvent) != "chirp.created" || received.Header.Get(HeaderDelivery) != "dlv_1" {
+		t.Errorf("headers = %v", received.Header)
+	}
+	v := &Verifier{Secrets: []string{"shh"}, Tolerance: time.Minute, Now: func() time.Time { return now }}
+	if err := v.Verify(received.Header.Get(HeaderTimestamp), received.Header.Get(HeaderSignature), receivedBody); err != nil {
+		t.Errorf("receiver could not verify the signature: %v", err)
+	}
+
+	d.URL = receiver.URL + "/fail"
+	status, err = s.Send(context.Background(), d)
+	var statusErr *StatusError
+	if status != http.StatusServiceUnavailable || !errors.As(err, &statusErr) {
+		t.Errorf("Send() to a failing receiver = %d, %v", status, err)
+	}
+
+	receiver.Close()
+	if status, err := s.Send(context.Background(), d); status != 0 || err == nil {
+		t.Errorf("Send() to a closed receiver = %d, %v", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempts  int
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{1, time.Minute, true},
+		{2, 2 * time.Minute, true},
+		{3, 4 * time.Minute, true},
+		{4, 5 * time.Minute, true},
+		{5, 0, false},
+	}
+
+	for _, tt := range tests {
+		delay, retry := p.Backoff(tt.attempts)
+		if delay != tt.wantDelay || retry != tt.wantRetry {
+			t.Errorf("Backoff(%d) = %v, %v, want %v, %v", tt.attempts, delay, retry, tt.wantDelay, tt.wantRetry)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	v := &Verifier{
+		Secrets:   []string{"new-secret", "old-secret"},
+		Tolerance: 5 * time.Minute,
+		Now:       func() time.Time { return now },
+	}
+
+	tests := []struct {
+		name       string
+		timestamp  string
+		signatures string
+		body       []byte
+		wantErr    error
+	}{
+		{name: "Signed with current secret", timestamp: ts, signatures: Sign("new-secret", now, body), body: body},
+		{name: "Signed with previous secret", timestamp: ts, signatures: Sign("old-secret", now, body), body: body},
+		{
+			name:       "One of several signatures valid",
+			timestamp:  ts,
+			signatures: Sign("retired", now, body) + ", " + Sign("new-secret", now, body),
+			body:       body,
+		},
+		{name: "Unknown secret", timestamp: ts, signatures: Sign("retired", now, body), body: body, wantErr: ErrInvalidSignature},
+		{name: "Tampered body", timestamp: ts, signatures: Sign("new-secret", now, body), body: []byte(`{}`), wantErr: ErrInvalidSignature},
+		{
+			name:       "Signature for another timestamp",
+			timestamp:  strconv.FormatInt(now.Unix()+1, 10),
+			signatures: Sign("new-secret", now, body),
+			body:       body,
+			wantErr:    ErrInvalidSignature,
+		},
+		{
+			name:       "Too old",
+			timestamp:  strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10),
+			signatures: Sign("new-secret", now.Add(-6*time.Minute), body),
+			body:       body,
+			wantErr:    ErrTimestampOutOfRange,
+		},
+		{
+			name:       "Too far in the future",
+			timestamp:  strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10),
+			signatures: Sign("new-secret", now.Add(6*time.Minute), body),
+			body:       body,
+			wantErr:    ErrTimestampOutOfRange,
+		},
+		{name: "Missing signature", timestamp: ts, signatures: "", body: body, wantErr: ErrMissingSignature},
+		{name: "Malformed timestamp", timestamp: "yesterday", signatures: Sign("new-secret", now, body), body: body, wantErr: ErrInvalidTimestamp},
+		{name: "Wrong version", timestamp: ts, signatures: "v0=" + Sign("new-secret", now, body)[3:], body: body, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := v.Verify(tt.timestamp, tt.signatures, tt.body); err != tt.wantErr {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
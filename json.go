@@ -22,6 +22,21 @@ func respondWithError(w http.ResponseWriter, errorStatus int, errorString string
 	w.Write(res)
 }
 
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type response_field_errors struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+func respondWithFieldErrors(w http.ResponseWriter, errorStatus int, errorString string, fields []FieldError) {
+	respondWithJSON(w, errorStatus, response_field_errors{Error: errorString, Fields: fields})
+}
+
 func respondWithJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Add("Content-Type", "application/json; charset=utf-8")
 	res, err := json.Marshal(v)
@@ -1,43 +1,145 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/mu7ammad1951/chirpy/internal/activitypub"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
 	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/entitlements"
+	"github.com/mu7ammad1951/chirpy/internal/lockout"
+	"github.com/mu7ammad1951/chirpy/internal/mailer"
+	"github.com/mu7ammad1951/chirpy/internal/oidc"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+	"github.com/mu7ammad1951/chirpy/internal/realtime"
+	"github.com/mu7ammad1951/chirpy/internal/safehttp"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+	"github.com/mu7ammad1951/chirpy/internal/webhook"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      *database.Queries
 	platform       string
 	secretString   string
-	polkaApiKey    string
+	polkaVerifier  *webhook.Verifier
+	passwordPolicy auth.PasswordPolicy
+	adminApiKey    string
+	accountLimiter *lockout.Limiter
+	ipLimiter      *lockout.Limiter
+	mailer         mailer.Mailer
+	baseURL        string
+	oidcProvider   *oidc.Provider
+
+	deletionGracePeriod time.Duration
+	exportDir           string
+	exportRetention     time.Duration
+	exportWake          chan struct{}
+	subscriptionGrace   time.Duration
+	entitlements        entitlements.Plans
+	webhookSender       *webhook.Sender
+	adminWebhookSender  *webhook.Sender
+	webhookRetry        webhook.RetryPolicy
+	webhookWake         chan struct{}
+	outbox              *outbox.Dispatcher
+	outboxWake          chan struct{}
+
+	chirpStream          *stream.Hub
+	chirpStreamHeartbeat time.Duration
+	chirpStreamRetry     time.Duration
+	userStream           *stream.Hub
+	presence             *realtime.Presence
+	realtimeRate         int
+	realtimeBurst        int
+
+	apClient *activitypub.Client
+	apInbox  *activitypub.Inbox
 }
 
 func main() {
 
 	cfg := apiConfig{
 		fileserverHits: atomic.Int32{},
+		exportWake:     make(chan struct{}, 1),
+		webhookWake:    make(chan struct{}, 1),
+		outboxWake:     make(chan struct{}, 1),
 	}
 
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
 	cfg.platform = os.Getenv("PLATFORM")
 	cfg.secretString = os.Getenv("SECRET_STRING")
-	cfg.polkaApiKey = os.Getenv("POLKA_KEY")
+	cfg.polkaVerifier = loadPolkaVerifier()
+	cfg.passwordPolicy = loadPasswordPolicy()
+	cfg.adminApiKey = os.Getenv("ADMIN_KEY")
+	cfg.mailer = loadMailer()
+	cfg.deletionGracePeriod = envDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour)
+	cfg.exportDir = os.Getenv("EXPORT_DIR")
+	if cfg.exportDir == "" {
+		cfg.exportDir = "exports"
+	}
+	cfg.exportRetention = envDuration("EXPORT_RETENTION", 7*24*time.Hour)
+	cfg.subscriptionGrace = envDuration("SUBSCRIPTION_GRACE_PERIOD", 7*24*time.Hour)
+	cfg.entitlements = loadEntitlements()
+	webhookTimeout := envDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	cfg.webhookSender = &webhook.Sender{Client: safehttp.NewClient(webhookTimeout)}
+	cfg.adminWebhookSender = &webhook.Sender{Client: &http.Client{
+		Timeout: webhookTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+	cfg.webhookRetry = loadWebhookRetryPolicy()
+	cfg.baseURL = strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if cfg.baseURL == "" {
+		cfg.baseURL = "http://localhost:8080"
+	}
+	cfg.oidcProvider = loadOIDCProvider(cfg.baseURL)
+	cfg.apClient = &activitypub.Client{
+		HTTP:      safehttp.NewClient(envDuration("ACTIVITYPUB_TIMEOUT", 10*time.Second)),
+		UserAgent: "Chirpy (+" + cfg.baseURL + ")",
+	}
+	cfg.chirpStream = stream.NewHub(envInt("CHIRP_STREAM_BACKLOG", 1000), chirpStreamBuffer)
+	cfg.chirpStreamHeartbeat = envDuration("CHIRP_STREAM_HEARTBEAT", 15*time.Second)
+	cfg.chirpStreamRetry = envDuration("CHIRP_STREAM_RETRY", 3*time.Second)
+	cfg.userStream = stream.NewHub(realtimeDedupe, realtimeBuffer)
+	cfg.presence = realtime.NewPresence()
+	cfg.realtimeRate = envInt("REALTIME_RATE_LIMIT", 5)
+	cfg.realtimeBurst = envInt("REALTIME_RATE_BURST", 20)
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatal("error connecting to database")
 	}
 
+	cfg.db = db
 	cfg.dbQueries = database.New(db)
+	cfg.apInbox = &activitypub.Inbox{Client: cfg.apClient, Store: federationStore{q: cfg.dbQueries}}
+	cfg.outbox = outbox.NewDispatcher(outbox.NewPostgresStore(cfg.dbQueries), loadOutboxPolicy())
+	cfg.subscribeOutbox()
+	cfg.accountLimiter, cfg.ipLimiter = loadLoginLimiters(cfg.dbQueries)
+
+	go cfg.runAccountPurger(context.Background(), envDuration("ACCOUNT_PURGE_INTERVAL", time.Hour))
+	go cfg.runDataExporter(context.Background(), time.Minute)
+	go cfg.runChirpStreamListener(context.Background(), dbURL)
+	go cfg.runUserStreamListener(context.Background(), dbURL)
+	go cfg.runOutboxDispatcher(context.Background(), 30*time.Second, envDuration("OUTBOX_RETENTION", 7*24*time.Hour))
+	go cfg.runWebhookDispatcher(context.Background(), 30*time.Second)
+	go cfg.runSubscriptionExpirer(context.Background(), envDuration("SUBSCRIPTION_EXPIRY_INTERVAL", 10*time.Minute))
+	if interval := envDuration("NOTIFICATION_DIGEST_INTERVAL", 24*time.Hour); interval > 0 {
+		go cfg.runNotificationDigests(context.Background(), interval)
+	}
 
 	const filePathRoot = "."
 	const port = "8080"
@@ -47,16 +149,94 @@ func main() {
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
 	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
-	mux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
-	mux.HandleFunc("GET /api/chirps", cfg.handlerGetChirps)
+	mux.HandleFunc("POST /admin/lockouts/unlock", cfg.middlewareAdmin(cfg.handlerUnlock))
+	mux.HandleFunc("POST /admin/oauth/clients", cfg.middlewareAdmin(cfg.handlerCreateOAuthClient))
+	mux.HandleFunc("GET /admin/oauth/clients", cfg.middlewareAdmin(cfg.handlerGetOAuthClients))
+	mux.HandleFunc("DELETE /admin/oauth/clients/{clientID}", cfg.middlewareAdmin(cfg.handlerDeleteOAuthClient))
+	mux.HandleFunc("GET /admin/webhooks/events", cfg.middlewareAdmin(cfg.handlerGetWebhookEvents))
+	mux.HandleFunc("GET /admin/webhooks/events/{eventID}", cfg.middlewareAdmin(cfg.handlerGetWebhookEvent))
+	mux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", cfg.middlewareAdmin(cfg.handlerReplayWebhookEvent))
+	mux.HandleFunc("POST /admin/webhooks/subscriptions", cfg.middlewareAdmin(cfg.handlerCreateWebhook))
+	mux.HandleFunc("GET /admin/webhooks/subscriptions", cfg.middlewareAdmin(cfg.handlerGetWebhooks))
+	mux.HandleFunc("DELETE /admin/webhooks/subscriptions/{webhookID}", cfg.middlewareAdmin(cfg.handlerDeleteWebhook))
+	mux.HandleFunc("GET /admin/webhooks/subscriptions/{webhookID}/deliveries", cfg.middlewareAdmin(cfg.handlerGetWebhookDeliveries))
+	mux.HandleFunc("POST /admin/webhooks/subscriptions/{webhookID}/deliveries/{deliveryID}/retry", cfg.middlewareAdmin(cfg.handlerRetryWebhookDelivery))
+	mux.HandleFunc("GET /oauth/authorize", cfg.handlerOAuthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.handlerOAuthConsent)
+	mux.HandleFunc("POST /oauth/token", cfg.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/introspect", cfg.handlerOAuthIntrospect)
+	mux.HandleFunc("POST /oauth/revoke", cfg.handlerOAuthRevoke)
+	mux.HandleFunc("POST /api/chirps", cfg.RequireScope(auth.ScopeWrite, cfg.handlerCreateChirp))
+	mux.HandleFunc("GET /api/chirps", cfg.OptionalAuth(cfg.handlerGetChirps))
+	mux.HandleFunc("GET /api/chirps/stream", cfg.OptionalAuth(cfg.handlerChirpStream))
+	mux.HandleFunc("GET /api/realtime", cfg.RequireScope(auth.ScopeRead, cfg.handlerRealtime))
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetChirpByID)
 	mux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
 	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/mfa", cfg.handlerLoginMFA)
+	mux.HandleFunc("GET /api/login/oidc", cfg.handlerOIDCLogin)
+	mux.HandleFunc("GET /api/login/oidc/callback", cfg.handlerOIDCCallback)
 	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
-	mux.HandleFunc("PUT /api/users", cfg.handlerUserUpdate)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirp)
-	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerUpgrade)
+	mux.HandleFunc("GET /api/users/me", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetMe))
+	mux.HandleFunc("GET /api/users/me/subscription", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetSubscription))
+	mux.HandleFunc("PATCH /api/users/me", cfg.RequireScope(auth.ScopeWrite, cfg.handlerPatchMe))
+	mux.HandleFunc("DELETE /api/users/me", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerDeleteMe))
+	mux.HandleFunc("PUT /api/users/me/password", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerPasswordChange))
+	mux.HandleFunc("PUT /api/users", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerPasswordChangeDeprecated))
+	mux.HandleFunc("POST /api/users/me/export", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerCreateExport))
+	mux.HandleFunc("GET /api/users/me/export/{exportID}", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerGetExport))
+	mux.HandleFunc("GET /api/exports/{exportID}/download", cfg.handlerDownloadExport)
+	mux.HandleFunc("POST /api/tokens", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerCreateToken))
+	mux.HandleFunc("GET /api/tokens", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerGetTokens))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerDeleteToken))
+	mux.HandleFunc("POST /api/webhooks", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerCreateWebhook))
+	mux.HandleFunc("GET /api/webhooks", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerGetWebhooks))
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerDeleteWebhook))
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerGetWebhookDeliveries))
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/retry", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerRetryWebhookDelivery))
+	mux.HandleFunc("POST /api/users/me/email", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerEmailChangeRequest))
+	mux.HandleFunc("POST /api/users/me/email/confirm", cfg.handlerEmailChangeConfirm)
+	mux.HandleFunc("POST /api/users/me/email/cancel", cfg.handlerEmailChangeCancel)
+	mux.HandleFunc("POST /api/users/me/mfa/totp", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerTOTPEnroll))
+	mux.HandleFunc("POST /api/users/me/mfa/totp/confirm", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerTOTPConfirm))
+	mux.HandleFunc("DELETE /api/users/me/mfa/totp", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerTOTPDisable))
+	mux.HandleFunc("POST /api/users/me/verify-email", cfg.RequireScope(auth.ScopeAdmin, cfg.handlerVerifyEmailRequest))
+	mux.HandleFunc("POST /api/verify-email", cfg.handlerVerifyEmailConfirm)
+	mux.HandleFunc("POST /api/password-reset", cfg.handlerPasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", cfg.handlerPasswordResetConfirm)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.RequireScope(auth.ScopeWrite, cfg.handlerUpdateChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.RequireScope(auth.ScopeWrite, cfg.handlerDeleteChirp))
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.RequireScope(auth.ScopeFollow, cfg.handlerFollow))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.RequireScope(auth.ScopeFollow, cfg.handlerUnfollow))
+	mux.HandleFunc("POST /api/users/{userID}/block", cfg.RequireScope(auth.ScopeFollow, cfg.handlerBlock))
+	mux.HandleFunc("DELETE /api/users/{userID}/block", cfg.RequireScope(auth.ScopeFollow, cfg.handlerUnblock))
+	mux.HandleFunc("POST /api/conversations", cfg.RequireScope(auth.ScopeWrite, cfg.handlerCreateConversation))
+	mux.HandleFunc("GET /api/conversations", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetConversations))
+	mux.HandleFunc("DELETE /api/conversations/{conversationID}", cfg.RequireScope(auth.ScopeWrite, cfg.handlerLeaveConversation))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/accept", cfg.RequireScope(auth.ScopeWrite, cfg.handlerAcceptConversation))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", cfg.RequireScope(auth.ScopeWrite, cfg.handlerMarkConversationRead))
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetMessages))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", cfg.RequireScope(auth.ScopeWrite, cfg.handlerSendMessage))
+	mux.HandleFunc("DELETE /api/conversations/{conversationID}/messages/{messageID}", cfg.RequireScope(auth.ScopeWrite, cfg.handlerDeleteMessage))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", cfg.RequireScope(auth.ScopeWrite, cfg.handlerLikeChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.RequireScope(auth.ScopeWrite, cfg.handlerUnlikeChirp))
+	mux.HandleFunc("GET /api/notifications", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetNotifications))
+	mux.HandleFunc("GET /api/notifications/unread_count", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetUnreadNotificationCount))
+	mux.HandleFunc("POST /api/notifications/read", cfg.RequireScope(auth.ScopeWrite, cfg.handlerMarkNotificationsRead))
+	mux.HandleFunc("GET /api/users/me/notification-preferences", cfg.RequireScope(auth.ScopeRead, cfg.handlerGetNotificationPreferences))
+	mux.HandleFunc("PATCH /api/users/me/notification-preferences", cfg.RequireScope(auth.ScopeWrite, cfg.handlerUpdateNotificationPreferences))
+	mux.HandleFunc("GET /users/{userID}/feed.atom", cfg.handlerUserFeed)
+	mux.HandleFunc("GET /users/{userID}/feed.rss", cfg.handlerUserFeed)
+	mux.HandleFunc("GET /hashtags/{tag}/feed.atom", cfg.handlerHashtagFeed)
+	mux.HandleFunc("GET /hashtags/{tag}/feed.rss", cfg.handlerHashtagFeed)
+	mux.HandleFunc("GET /.well-known/webfinger", cfg.handlerWebFinger)
+	mux.HandleFunc("GET /ap/users/{userID}", cfg.handlerGetActor)
+	mux.HandleFunc("GET /ap/users/{userID}/outbox", cfg.handlerGetOutbox)
+	mux.HandleFunc("GET /ap/users/{userID}/followers", cfg.handlerGetFollowers)
+	mux.HandleFunc("POST /ap/users/{userID}/inbox", cfg.handlerPostInbox)
+	mux.HandleFunc("GET /ap/chirps/{chirpID}", cfg.handlerGetNote)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerPolkaWebhook)
 
 	server := &http.Server{
 		Handler: mux,
@@ -73,3 +253,24 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, req)
 	})
 }
+
+// middlewareAdmin only lets through requests carrying ADMIN_KEY as an
+// "ApiKey" authorization header. If ADMIN_KEY is unset every request is
+// rejected.
+func (cfg *apiConfig) middlewareAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		key, err := auth.GetAPIKey(req.Header)
+		if err != nil {
+			log.Printf("error retrieving admin key: %v\n", err)
+			respondWithError(w, http.StatusUnauthorized, "")
+			return
+		}
+		if cfg.adminApiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(cfg.adminApiKey)) != 1 {
+			log.Printf("mismatched admin key\n")
+			respondWithError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		principal := Principal{Roles: []string{RoleAdmin}, Scopes: auth.AllScopes(), Method: AuthMethodAdminKey}
+		next(w, req.WithContext(withPrincipal(req.Context(), principal)))
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/mailer"
+	"github.com/mu7ammad1951/chirpy/internal/notification"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+)
+
+// eventNotificationCreated is sent to the recipient's open connections
+// when a notification is stored.
+const eventNotificationCreated = "notification.created"
+
+const (
+	// maxMentionsPerChirp caps how many users one chirp can notify by
+	// mentioning them.
+	maxMentionsPerChirp = 10
+	// digestCheckInterval is how often the digest job looks for users due
+	// a digest.
+	digestCheckInterval = 10 * time.Minute
+	digestBatchSize     = 100
+	// maxDigestItems is how many notifications one digest lists.
+	maxDigestItems = 10
+)
+
+type NotificationResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id"`
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+func newNotificationResponse(n database.Notification) NotificationResponse {
+	res := NotificationResponse{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+		ActorID:   n.ActorID,
+	}
+	if n.ChirpID.Valid {
+		res.ChirpID = &n.ChirpID.UUID
+	}
+	if n.ReadAt.Valid {
+		res.ReadAt = &n.ReadAt.Time
+	}
+	return res
+}
+
+// createNotifications turns likes, follows, replies and mentions into
+// notifications for the users they concern.
+func (cfg *apiConfig) createNotifications(ctx context.Context, event outbox.Event) error {
+	var pending []database.CreateNotificationParams
+	switch event.Type {
+	case eventChirpLiked:
+		var data likedChirpData
+		if err := json.Unmarshal(event.Payload, &data); err != nil {
+			return err
+		}
+		pending = append(pending, database.CreateNotificationParams{
+			UserID:  data.AuthorID,
+			Type:    notification.TypeLike,
+			ActorID: data.UserID,
+			ChirpID: uuid.NullUUID{UUID: data.ChirpID, Valid: true},
+		})
+	case eventUserFollowed:
+		var data followData
+		if err := json.Unmarshal(event.Payload, &data); err != nil {
+			return err
+		}
+		pending = append(pending, database.CreateNotificationParams{
+			UserID:  data.FolloweeID,
+			Type:    notification.TypeFollow,
+			ActorID: data.FollowerID,
+		})
+	case eventChirpCreated:
+		var chirp ChirpResponse
+		if err := json.Unmarshal(event.Payload, &chirp); err != nil {
+			return err
+		}
+		var err error
+		if pending, err = cfg.chirpNotifications(ctx, chirp); err != nil {
+			return err
+		}
+	}
+
+	for _, params := range pending {
+		params.EventID = event.ID
+		if err := cfg.notify(ctx, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chirpNotifications notifies the author of the chirp being replied to and
+// the users mentioned. A user both replied to and mentioned is only told
+// about the reply.
+func (cfg *apiConfig) chirpNotifications(ctx context.Context, chirp ChirpResponse) ([]database.CreateNotificationParams, error) {
+	chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+	var pending []database.CreateNotificationParams
+	var repliedTo uuid.UUID
+	if chirp.ReplyToID != nil {
+		parent, err := cfg.dbQueries.GetChirpByID(ctx, *chirp.ReplyToID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if err == nil {
+			repliedTo = parent.UserID
+			pending = append(pending, database.CreateNotificationParams{
+				UserID:  parent.UserID,
+				Type:    notification.TypeReply,
+				ActorID: chirp.UserID,
+				ChirpID: chirpID,
+			})
+		}
+	}
+
+	usernames := notification.Mentions(chirp.Body)
+	if len(usernames) == 0 {
+		return pending, nil
+	}
+	if len(usernames) > maxMentionsPerChirp {
+		usernames = usernames[:maxMentionsPerChirp]
+	}
+	mentioned, err := cfg.dbQueries.GetUserIDsByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range mentioned {
+		if userID == repliedTo {
+			continue
+		}
+		pending = append(pending, database.CreateNotificationParams{
+			UserID:  userID,
+			Type:    notification.TypeMention,
+			ActorID: chirp.UserID,
+			ChirpID: chirpID,
+		})
+	}
+	return pending, nil
+}
+
+// notify stores a notification and pushes it to the recipient's open
+// connections. Nothing is stored if users act on their own things, if
+// either has blocked the other or if the recipient muted the type.
+func (cfg *apiConfig) notify(ctx context.Context, params database.CreateNotificationParams) error {
+	if params.UserID == params.ActorID {
+		return nil
+	}
+	blocked, err := cfg.dbQueries.HasBlockWithAny(ctx, database.HasBlockWithAnyParams{
+		UserID:   params.UserID,
+		OtherIds: []uuid.UUID{params.ActorID},
+	})
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return nil
+	}
+	prefs, err := cfg.dbQueries.GetNotificationPreferences(ctx, params.UserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if slices.Contains(prefs.MutedTypes, params.Type) {
+		return nil
+	}
+
+	n, err := cfg.dbQueries.CreateNotification(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already created when the event was dispatched before.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The notification is stored, so a failed push is only logged: the
+	// event is not retried for it.
+	payload, err := json.Marshal(newNotificationResponse(n))
+	if err != nil {
+		log.Printf("error encoding notification: %v\n", err)
+		return nil
+	}
+	err = stream.Notify(ctx, cfg.dbQueries, userStreamChannel, stream.Event{
+		ID:     n.ID.String(),
+		Type:   eventNotificationCreated,
+		UserID: n.UserID,
+		Data:   payload,
+	})
+	if err != nil {
+		log.Printf("error pushing notification: %v\n", err)
+	}
+	return nil
+}
+
+// runNotificationDigests emails users who asked for it a summary of their
+// unread notifications, at most once per interval. A user is recorded as
+// sent a digest before it goes out, so a failed send is not retried until
+// the next interval.
+func (cfg *apiConfig) runNotificationDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(digestCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			due, err := cfg.dbQueries.ClaimDueDigests(ctx, database.ClaimDueDigestsParams{
+				DueBefore:  sql.NullTime{Time: time.Now().UTC().Add(-interval), Valid: true},
+				MaxResults: digestBatchSize,
+			})
+			if err != nil {
+				log.Printf("error claiming notification digests: %v\n", err)
+				break
+			}
+			for _, user := range due {
+				if err := cfg.sendDigest(ctx, user); err != nil {
+					log.Printf("error sending notification digest: %v\n", err)
+				}
+			}
+			if len(due) < digestBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) sendDigest(ctx context.Context, user database.ClaimDueDigestsRow) error {
+	rows, err := cfg.dbQueries.GetDigestNotifications(ctx, database.GetDigestNotificationsParams{
+		UserID:     user.UserID,
+		Since:      user.PreviousDigestAt,
+		MaxResults: maxDigestItems,
+	})
+	if err != nil {
+		return err
+	}
+	counts, err := cfg.dbQueries.CountUnreadNotifications(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, count := range counts {
+		total += int(count.Count)
+	}
+
+	items := make([]notification.DigestItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, notification.DigestItem{
+			Type:      row.Type,
+			Actor:     digestActor(row),
+			ChirpBody: row.ChirpBody.String,
+		})
+	}
+	subject, body := notification.Digest(items, total)
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// digestActor names a user the way they chose to be known.
+func digestActor(row database.GetDigestNotificationsRow) string {
+	if row.ActorUsername.Valid {
+		return "@" + row.ActorUsername.String
+	}
+	if row.ActorDisplayName.Valid {
+		return row.ActorDisplayName.String
+	}
+	return "Someone"
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// oauthError is an error response defined by RFC 6749, returned either as
+// JSON from the token endpoint or as query parameters on a redirect.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+var errInvalidClient = &oauthError{Code: "invalid_client", Description: "client authentication failed"}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, err *oauthError) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, err)
+}
+
+var scopeDescriptions = map[string]string{
+	auth.ScopeRead:   "See your profile and account details",
+	auth.ScopeWrite:  "Post and delete chirps and edit your profile",
+	auth.ScopeFollow: "Follow, unfollow, block and unblock other users",
+	auth.ScopeAdmin:  "Manage your account: password, email, two-factor authentication, access tokens, data exports and deletion",
+}
+
+// authorizeRequest is a validated authorization request. RedirectURI is
+// only set once the client and redirect URI have been checked, which is
+// when errors may be sent back to the client instead of shown to the user.
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	State         string
+	Scopes        []string
+	CodeChallenge string
+}
+
+func (cfg *apiConfig) parseAuthorizeRequest(ctx context.Context, form url.Values) (authorizeRequest, error) {
+	var ar authorizeRequest
+
+	clientID, err := uuid.Parse(form.Get("client_id"))
+	if err != nil {
+		return ar, errors.New("unknown client")
+	}
+	ar.Client, err = cfg.dbQueries.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return ar, errors.New("unknown client")
+	}
+	// Redirect URIs must match a registered URI exactly.
+	redirectURI := form.Get("redirect_uri")
+	if redirectURI == "" && len(ar.Client.RedirectUris) == 1 {
+		redirectURI = ar.Client.RedirectUris[0]
+	}
+	if !slices.Contains(ar.Client.RedirectUris, redirectURI) {
+		return ar, errors.New("redirect_uri is not registered for this client")
+	}
+	ar.RedirectURI = redirectURI
+	ar.State = form.Get("state")
+
+	if form.Get("response_type") != "code" {
+		return ar, &oauthError{Code: "unsupported_response_type", Description: "only the code response type is supported"}
+	}
+	// PKCE is required for every client, including confidential ones.
+	ar.CodeChallenge = form.Get("code_challenge")
+	if ar.CodeChallenge == "" || form.Get("code_challenge_method") != "S256" {
+		return ar, &oauthError{Code: "invalid_request", Description: "code_challenge with code_challenge_method S256 is required"}
+	}
+
+	scope := form.Get("scope")
+	if scope == "" {
+		scope = auth.ScopeRead
+	}
+	ar.Scopes, err = auth.ParseScope(scope)
+	if err != nil {
+		return ar, &oauthError{Code: "invalid_scope", Description: err.Error()}
+	}
+	allowed, err := auth.ParseScope(ar.Client.Scope)
+	if err != nil || !auth.ScopeSubset(ar.Scopes, allowed) {
+		return ar, &oauthError{Code: "invalid_scope", Description: "client may not request " + scope}
+	}
+	return ar, nil
+}
+
+// redirectToClient sends the user agent back to the client with either an
+// authorization code or an error.
+func redirectToClient(w http.ResponseWriter, req *http.Request, ar authorizeRequest, params url.Values) {
+	u, err := url.Parse(ar.RedirectURI)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid redirect_uri")
+		return
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if ar.State != "" {
+		q.Set("state", ar.State)
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(w, req, u.String(), http.StatusFound)
+}
+
+func redirectWithOAuthError(w http.ResponseWriter, req *http.Request, ar authorizeRequest, err error) {
+	var oe *oauthError
+	if !errors.As(err, &oe) {
+		oe = &oauthError{Code: "server_error"}
+	}
+	params := url.Values{"error": {oe.Code}}
+	if oe.Description != "" {
+		params.Set("error_description", oe.Description)
+	}
+	redirectToClient(w, req, ar, params)
+}
+
+type consentPage struct {
+	authorizeRequest
+	ScopeDescriptions []string
+	Email             string
+	Error             string
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Authorize {{.Client.Name}} - Chirpy</title>
+</head>
+<body>
+    <h1>{{.Client.Name}} wants to access your Chirpy account</h1>
+    <p>If you allow it, {{.Client.Name}} will be able to:</p>
+    <ul>
+        {{range .ScopeDescriptions}}<li>{{.}}</li>
+        {{end}}
+    </ul>
+    {{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+    <form method="post" action="/oauth/authorize">
+        <input type="hidden" name="response_type" value="code">
+        <input type="hidden" name="client_id" value="{{.Client.ID}}">
+        <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+        <input type="hidden" name="state" value="{{.State}}">
+        <input type="hidden" name="scope" value="{{range $i, $s := .Scopes}}{{if $i}} {{end}}{{$s}}{{end}}">
+        <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+        <input type="hidden" name="code_challenge_method" value="S256">
+        <p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username"></label></p>
+        <p><label>Password <input type="password" name="password" autocomplete="current-password"></label></p>
+        <p><label>Two-factor code, if enabled <input type="text" name="totp_code" inputmode="numeric" autocomplete="one-time-code"></label></p>
+        <p><label>or a recovery code <input type="text" name="recovery_code" autocomplete="off"></label></p>
+        <button type="submit" name="decision" value="allow">Allow</button>
+        <button type="submit" name="decision" value="deny">Deny</button>
+    </form>
+</body>
+</html>
+`))
+
+// renderConsent shows the consent screen. It must not be framed by another
+// site, otherwise the Allow button could be clickjacked.
+func renderConsent(w http.ResponseWriter, status int, page consentPage) {
+	for _, s := range page.Scopes {
+		page.ScopeDescriptions = append(page.ScopeDescriptions, scopeDescriptions[s])
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+	consentTemplate.Execute(w, page)
+}
+
+// authenticateOAuthClient accepts client credentials with HTTP Basic
+// authentication or as client_id and client_secret form parameters. Public
+// clients, which have no secret, only need to identify themselves.
+func (cfg *apiConfig) authenticateOAuthClient(req *http.Request) (database.OauthClient, error) {
+	clientID, secret, ok := req.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 form-encodes both values before Basic.
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = req.PostForm.Get("client_id")
+		secret = req.PostForm.Get("client_secret")
+	}
+
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return database.OauthClient{}, errInvalidClient
+	}
+	client, err := cfg.dbQueries.GetOAuthClient(req.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.SecretHash.Valid {
+		if secret == "" || subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidClient
+		}
+	}
+	return client, nil
+}
+
+// lookupOAuthToken finds the grant behind either an access token or a
+// refresh token.
+func (cfg *apiConfig) lookupOAuthToken(ctx context.Context, tokenString string) (database.OauthToken, error) {
+	if token, err := auth.ValidateOAuthAccessToken(tokenString, cfg.secretString); err == nil {
+		return cfg.dbQueries.GetOAuthToken(ctx, token.TokenID)
+	}
+	return cfg.dbQueries.GetOAuthTokenByRefreshHash(ctx, auth.HashToken(tokenString))
+}
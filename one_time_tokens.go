@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+const (
+	verifyEmailTokenTTL   = 24 * time.Hour
+	resetPasswordTokenTTL = time.Hour
+	changeEmailTokenTTL   = 24 * time.Hour
+)
+
+var errInvalidOneTimeToken = errors.New("invalid, expired or already used token")
+
+// issueOneTimeToken records a single-use token for userID and returns its
+// signed form to be emailed to the user.
+func (cfg *apiConfig) issueOneTimeToken(ctx context.Context, userID uuid.UUID, purpose string, ttl time.Duration) (string, error) {
+	tokenID := uuid.New()
+	err := cfg.dbQueries.CreateOneTimeToken(ctx, database.CreateOneTimeTokenParams{
+		ID:        tokenID,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return auth.MakeOneTimeToken(userID, tokenID, purpose, cfg.secretString, ttl)
+}
+
+// redeemOneTimeToken validates tokenString and marks it used, returning the
+// user it was issued to. Each token is accepted at most once.
+func (cfg *apiConfig) redeemOneTimeToken(ctx context.Context, tokenString, purpose string) (uuid.UUID, error) {
+	userID, tokenID, err := auth.ValidateOneTimeToken(tokenString, purpose, cfg.secretString)
+	if err != nil {
+		return uuid.Nil, errInvalidOneTimeToken
+	}
+	used, err := cfg.dbQueries.UseOneTimeToken(ctx, database.UseOneTimeTokenParams{
+		ID:      tokenID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if used == 0 {
+		return uuid.Nil, errInvalidOneTimeToken
+	}
+	return userID, nil
+}
+
+func (cfg *apiConfig) linkURL(path, token string) string {
+	return cfg.baseURL + path + "?token=" + token
+}
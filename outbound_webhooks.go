@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/webhook"
+)
+
+// outboundEventTypes are the events that webhook subscriptions can ask
+// for.
+var outboundEventTypes = map[string]bool{
+	eventChirpCreated:   true,
+	eventChirpUpdated:   true,
+	eventChirpDeleted:   true,
+	eventUserUpgraded:   true,
+	eventUserDowngraded: true,
+}
+
+// Delivery statuses.
+const (
+	deliveryStatusPending   = "pending"
+	deliveryStatusDelivered = "delivered"
+	deliveryStatusDead      = "dead"
+)
+
+const (
+	webhookDeliveryBatch = 20
+	// webhookDeliveryLease is how long a claimed delivery is left alone
+	// before another worker may try it. It must be longer than the
+	// sender's timeout.
+	webhookDeliveryLease = 5 * time.Minute
+)
+
+type outboundEvent struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// wakeWebhookDispatcher asks the dispatcher to send queued deliveries now
+// rather than at its next tick.
+func (cfg *apiConfig) wakeWebhookDispatcher() {
+	select {
+	case cfg.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// runWebhookDispatcher sends queued deliveries, retrying failures with
+// exponential backoff until they succeed or are dead-lettered.
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.sendWebhookDeliveries(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-cfg.webhookWake:
+		}
+	}
+}
+
+func (cfg *apiConfig) sendWebhookDeliveries(ctx context.Context) {
+	for {
+		now := time.Now().UTC()
+		deliveries, err := cfg.dbQueries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeaseUntil:    now.Add(webhookDeliveryLease),
+			Now:           now,
+			MaxDeliveries: webhookDeliveryBatch,
+		})
+		if err != nil {
+			log.Printf("error claiming webhook deliveries: %v\n", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		for _, delivery := range deliveries {
+			cfg.sendWebhookDelivery(ctx, delivery)
+		}
+	}
+}
+
+func (cfg *apiConfig) sendWebhookDelivery(ctx context.Context, delivery database.WebhookDelivery) {
+	sub, err := cfg.dbQueries.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The subscription was deleted, taking its deliveries with it.
+		return
+	}
+	if err != nil {
+		log.Printf("error retrieving webhook subscription: %v\n", err)
+		return
+	}
+
+	// Users' subscriptions may only reach public addresses. Instance-wide
+	// ones are set up with the admin key and may point at the operator's
+	// own network.
+	sender := cfg.webhookSender
+	if !sub.UserID.Valid {
+		sender = cfg.adminWebhookSender
+	}
+	status, sendErr := sender.Send(ctx, webhook.Delivery{
+		ID:     delivery.ID.String(),
+		Event:  delivery.EventType,
+		URL:    sub.Url,
+		Secret: sub.Secret,
+		Body:   delivery.Payload,
+	})
+
+	params := database.FinishWebhookDeliveryParams{
+		ID:            delivery.ID,
+		Status:        deliveryStatusDelivered,
+		NextAttemptAt: delivery.NextAttemptAt,
+	}
+	if status != 0 {
+		params.LastStatusCode = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	if sendErr != nil {
+		params.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+		delay, retry := cfg.webhookRetry.Backoff(int(delivery.Attempts))
+		if retry {
+			params.Status = deliveryStatusPending
+			params.NextAttemptAt = time.Now().UTC().Add(delay)
+		} else {
+			params.Status = deliveryStatusDead
+			log.Printf("webhook delivery %s dead-lettered after %d attempts: %v\n", delivery.ID, delivery.Attempts, sendErr)
+		}
+	}
+	if err := cfg.dbQueries.FinishWebhookDelivery(ctx, params); err != nil {
+		log.Printf("error recording webhook delivery: %v\n", err)
+	}
+}
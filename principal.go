@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/auth"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// AuthMethod records how a request was authenticated.
+type AuthMethod string
+
+const (
+	AuthMethodSession             AuthMethod = "session"
+	AuthMethodOAuth               AuthMethod = "oauth"
+	AuthMethodPersonalAccessToken AuthMethod = "personal_access_token"
+	AuthMethodAdminKey            AuthMethod = "admin_key"
+)
+
+// Roles carried by a Principal.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal is the authenticated caller of a request. First-party sessions
+// hold every scope; OAuth and personal access tokens hold the scopes they
+// were granted. ExpiresAt is when the credentials stop working, and is
+// zero for personal access tokens without an expiry.
+type Principal struct {
+	UserID    uuid.UUID
+	Roles     []string
+	Scopes    []string
+	Method    AuthMethod
+	ExpiresAt time.Time
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+type principalContextKey struct{}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+func principalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(Principal)
+	return p, ok
+}
+
+// requestPrincipal returns the caller of a request behind RequireAuth or
+// RequireScope.
+func requestPrincipal(req *http.Request) Principal {
+	p, _ := principalFromContext(req.Context())
+	return p
+}
+
+var errNoCredentials = errors.New("no credentials")
+
+// resolvePrincipal authenticates the bearer token on a request. It returns
+// errNoCredentials when there is no Authorization header at all.
+func (cfg *apiConfig) resolvePrincipal(req *http.Request) (Principal, error) {
+	if req.Header.Get("Authorization") == "" {
+		return Principal{}, errNoCredentials
+	}
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return Principal{}, err
+	}
+	return cfg.authenticateToken(req.Context(), tokenString)
+}
+
+var errAccountDeleted = errors.New("account deleted or pending deletion")
+
+// authenticateToken resolves a bearer token of any kind to its Principal.
+// Tokens of accounts awaiting deletion are refused: refresh, OAuth and
+// personal access tokens are revoked when deletion is requested, but
+// access JWTs cannot be, and logging in is the way to cancel.
+func (cfg *apiConfig) authenticateToken(ctx context.Context, tokenString string) (Principal, error) {
+	principal, err := cfg.resolveToken(ctx, tokenString)
+	if err != nil {
+		return Principal{}, err
+	}
+	active, err := cfg.dbQueries.IsUserActive(ctx, principal.UserID)
+	if err != nil {
+		return Principal{}, err
+	}
+	if !active {
+		return Principal{}, errAccountDeleted
+	}
+	return principal, nil
+}
+
+func (cfg *apiConfig) resolveToken(ctx context.Context, tokenString string) (Principal, error) {
+	if auth.IsPersonalAccessToken(tokenString) {
+		pat, err := cfg.dbQueries.GetPersonalAccessTokenByHash(ctx, auth.HashToken(tokenString))
+		if err != nil {
+			return Principal{}, err
+		}
+		if err := cfg.dbQueries.TouchPersonalAccessToken(ctx, pat.ID); err != nil {
+			log.Printf("error recording personal access token use: %v\n", err)
+		}
+		scopes, _ := auth.ParseScope(pat.Scope)
+		return Principal{UserID: pat.UserID, Roles: []string{RoleUser}, Scopes: scopes, Method: AuthMethodPersonalAccessToken, ExpiresAt: pat.ExpiresAt.Time}, nil
+	}
+
+	if userID, expiresAt, err := auth.ValidateJWTWithExpiry(tokenString, cfg.secretString); err == nil {
+		return Principal{UserID: userID, Roles: []string{RoleUser}, Scopes: auth.AllScopes(), Method: AuthMethodSession, ExpiresAt: expiresAt}, nil
+	}
+
+	token, err := auth.ValidateOAuthAccessToken(tokenString, cfg.secretString)
+	if err != nil {
+		return Principal{}, err
+	}
+	grant, err := cfg.dbQueries.GetOAuthToken(ctx, token.TokenID)
+	if err != nil {
+		return Principal{}, err
+	}
+	if grant.RevokedAt.Valid {
+		return Principal{}, errors.New("oauth token revoked")
+	}
+	return Principal{UserID: token.UserID, Roles: []string{RoleUser}, Scopes: token.Scopes, Method: AuthMethodOAuth, ExpiresAt: token.ExpiresAt}, nil
+}
+
+// revokeUserCredentials signs a user out everywhere: their refresh tokens,
+// the OAuth grants of every app they authorized and their personal access
+// tokens stop working. q should be bound to the transaction making the
+// change that calls for it.
+func revokeUserCredentials(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	if err := q.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := q.RevokeUserOAuthTokens(ctx, userID); err != nil {
+		return err
+	}
+	return q.DeleteUserPersonalAccessTokens(ctx, userID)
+}
+
+func respondUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="chirpy"`)
+	respondWithError(w, http.StatusUnauthorized, "")
+}
+
+// OptionalAuth adds the caller's Principal to the request context when
+// valid credentials are present. Missing or invalid credentials leave the
+// request anonymous, so a stale token never locks a client out of a public
+// route.
+func (cfg *apiConfig) OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		principal, err := cfg.resolvePrincipal(req)
+		if errors.Is(err, errNoCredentials) {
+			next(w, req)
+			return
+		}
+		if err != nil {
+			log.Printf("ignoring invalid credentials on optional route: %v\n", err)
+			next(w, req)
+			return
+		}
+		next(w, req.WithContext(withPrincipal(req.Context(), principal)))
+	}
+}
+
+// RequireAuth rejects requests without valid credentials.
+func (cfg *apiConfig) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		principal, err := cfg.resolvePrincipal(req)
+		if err != nil {
+			if !errors.Is(err, errNoCredentials) {
+				log.Printf("error authenticating request: %v\n", err)
+			}
+			respondUnauthorized(w)
+			return
+		}
+		next(w, req.WithContext(withPrincipal(req.Context(), principal)))
+	}
+}
+
+// RequireScope rejects requests without valid credentials, or whose
+// credentials were not granted scope.
+func (cfg *apiConfig) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.RequireAuth(func(w http.ResponseWriter, req *http.Request) {
+		if !requestPrincipal(req).HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			respondWithError(w, http.StatusForbidden, "token is missing the "+scope+" scope")
+			return
+		}
+		next(w, req)
+	})
+}
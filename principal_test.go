@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+)
+
+// recordingDB is a database.DBTX that records the statements executed
+// against it, failing the one named in failOn.
+type recordingDB struct {
+	failOn string
+	execs  []recordedExec
+}
+
+type recordedExec struct {
+	name string
+	args []any
+}
+
+func (db *recordingDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(query, "-- name: "), " ")
+	db.execs = append(db.execs, recordedExec{name: name, args: args})
+	if name == db.failOn {
+		return nil, errors.New("exec failed")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (db *recordingDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *recordingDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *recordingDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	panic("not supported")
+}
+
+func TestRevokeUserCredentials(t *testing.T) {
+	userID := uuid.New()
+	tests := []struct {
+		name      string
+		failOn    string
+		wantExecs []string
+		wantErr   bool
+	}{
+		{
+			name:      "revokes every kind of credential",
+			wantExecs: []string{"RevokeUserRefreshTokens", "RevokeUserOAuthTokens", "DeleteUserPersonalAccessTokens"},
+		},
+		{
+			name:      "an OAuth failure stops before the tokens",
+			failOn:    "RevokeUserOAuthTokens",
+			wantExecs: []string{"RevokeUserRefreshTokens", "RevokeUserOAuthTokens"},
+			wantErr:   true,
+		},
+		{
+			name:      "a refresh token failure stops everything",
+			failOn:    "RevokeUserRefreshTokens",
+			wantExecs: []string{"RevokeUserRefreshTokens"},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &recordingDB{failOn: tt.failOn}
+			err := revokeUserCredentials(context.Background(), database.New(db), userID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("revokeUserCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(db.execs) != len(tt.wantExecs) {
+				t.Fatalf("executed %v, want %v", db.execs, tt.wantExecs)
+			}
+			for i, exec := range db.execs {
+				if exec.name != tt.wantExecs[i] {
+					t.Errorf("statement %d = %s, want %s", i, exec.name, tt.wantExecs[i])
+				}
+				if len(exec.args) != 1 || exec.args[0] != userID {
+					t.Errorf("%s args = %v, want the user id", exec.name, exec.args)
+				}
+			}
+		})
+	}
+}
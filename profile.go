@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/notification"
+)
+
+type profileField struct {
+	maxLength  int
+	isURL      bool
+	isUsername bool
+	column     func(*database.UpdateUserProfileParams) *sql.NullString
+}
+
+// profileFields are the user fields that PATCH /api/users/me can change.
+var profileFields = map[string]profileField{
+	"display_name": {maxLength: 50, column: func(p *database.UpdateUserProfileParams) *sql.NullString { return &p.DisplayName }},
+	"bio":          {maxLength: 160, column: func(p *database.UpdateUserProfileParams) *sql.NullString { return &p.Bio }},
+	"location":     {maxLength: 30, column: func(p *database.UpdateUserProfileParams) *sql.NullString { return &p.Location }},
+	"website":      {maxLength: 200, isURL: true, column: func(p *database.UpdateUserProfileParams) *sql.NullString { return &p.Website }},
+	"username":     {maxLength: notification.MaxUsernameLength, isUsername: true, column: func(p *database.UpdateUserProfileParams) *sql.NullString { return &p.Username }},
+}
+
+// readOnlyProfileFields are returned by GET /api/users/me but changed
+// elsewhere, if at all.
+var readOnlyProfileFields = map[string]string{
+	"id":             "id cannot be changed",
+	"created_at":     "created_at cannot be changed",
+	"updated_at":     "updated_at cannot be changed",
+	"email":          "use POST /api/users/me/email to change your email",
+	"email_verified": "email_verified cannot be changed",
+	"password":       "use PUT /api/users/me/password to change your password",
+	"is_chirpy_red":  "is_chirpy_red cannot be changed",
+	"subscription":   "subscriptions are managed through Polka",
+	"badges":         "badges come with your plan",
+}
+
+var errPatchNotObject = errors.New("request body must be a JSON object")
+
+// applyProfilePatch applies a JSON merge patch (RFC 7396) to the user's
+// profile: absent fields are left alone, null clears a field and a string
+// replaces it.
+func applyProfilePatch(user database.User, body []byte) (database.UpdateUserProfileParams, []FieldError, error) {
+	params := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		UpdatedAt:   user.UpdatedAt,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Location:    user.Location,
+		Website:     user.Website,
+		Username:    user.Username,
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return params, nil, errPatchNotObject
+	}
+
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fieldErrors []FieldError
+	for _, name := range names {
+		field, ok := profileFields[name]
+		if !ok {
+			if message, readOnly := readOnlyProfileFields[name]; readOnly {
+				fieldErrors = append(fieldErrors, FieldError{Field: name, Rule: "read_only", Message: message})
+			} else {
+				fieldErrors = append(fieldErrors, FieldError{Field: name, Rule: "unknown_field", Message: "unknown field"})
+			}
+			continue
+		}
+
+		column := field.column(&params)
+		raw := patch[name]
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			*column = sql.NullString{}
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Rule: "type", Message: "must be a string or null"})
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if fieldError, ok := field.validate(name, value); !ok {
+			fieldErrors = append(fieldErrors, fieldError)
+			continue
+		}
+		*column = sql.NullString{String: value, Valid: value != ""}
+	}
+	return params, fieldErrors, nil
+}
+
+func (f profileField) validate(name, value string) (FieldError, bool) {
+	if utf8.RuneCountInString(value) > f.maxLength {
+		return FieldError{
+			Field:   name,
+			Rule:    "max_length",
+			Message: fmt.Sprintf("must be at most %d characters", f.maxLength),
+		}, false
+	}
+	if f.isURL && value != "" {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return FieldError{Field: name, Rule: "url", Message: "must be an http or https URL"}, false
+		}
+	}
+	if f.isUsername && value != "" && !notification.ValidUsername(value) {
+		return FieldError{Field: name, Rule: "format", Message: "may only contain letters, digits and underscores"}, false
+	}
+	return FieldError{}, true
+}
+
+// userETag derives a strong entity tag from updated_at and whether the
+// user's subscription is active. Every query that changes a field of
+// UserResponse bumps updated_at, including the subscription queries that
+// set is_chirpy_red, so that a cached copy is never served after a
+// membership change. A subscription also lapses when expires_at passes,
+// with no write at all, so its active state is part of the tag as well.
+func userETag(user database.User, active bool) string {
+	tag := strconv.FormatInt(user.UpdatedAt.UnixMicro(), 36)
+	if active {
+		tag += "-active"
+	}
+	return `"` + tag + `"`
+}
+
+// etagMatches reports whether header (an If-Match or If-None-Match value)
+// lists etag or is "*".
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/outbox"
+	"github.com/mu7ammad1951/chirpy/internal/realtime"
+	"github.com/mu7ammad1951/chirpy/internal/stream"
+)
+
+// userStreamChannel is the Postgres channel that carries notifications and
+// signals addressed to users between server instances.
+const userStreamChannel = "user_stream"
+
+// notifyUserStream passes an event about a user to that user's open
+// connections on every server instance. As with notifyChirpStream, a
+// failure is logged rather than failing the outbox event.
+func (cfg *apiConfig) notifyUserStream(ctx context.Context, event outbox.Event) error {
+	err := stream.Notify(ctx, cfg.dbQueries, userStreamChannel, stream.Event{
+		ID:     event.ID.String(),
+		Type:   event.Type,
+		UserID: event.UserID.UUID,
+		Data:   event.Payload,
+	})
+	if err != nil {
+		log.Printf("error streaming %s event %s: %v\n", event.Type, event.ID, err)
+	}
+	return nil
+}
+
+// sendSignal broadcasts a typing or presence signal. Signals are not
+// stored, so users who aren't connected never see them.
+func (cfg *apiConfig) sendSignal(ctx context.Context, signalType string, userID uuid.UUID, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return stream.Notify(ctx, cfg.dbQueries, userStreamChannel, stream.Event{
+		ID:     uuid.NewString(),
+		Type:   signalType,
+		UserID: userID,
+		Data:   payload,
+	})
+}
+
+// setPresence announces that a user came online or went offline. A user
+// connected to more than one server instance is shown offline as soon as
+// they leave any of them.
+func (cfg *apiConfig) setPresence(ctx context.Context, userID uuid.UUID, online bool) {
+	if err := cfg.sendSignal(ctx, realtime.EventPresence, userID, realtime.PresenceData{Online: online}); err != nil {
+		log.Printf("error sending presence: %v\n", err)
+	}
+}
+
+// runUserStreamListener feeds user events notified by any instance into
+// this instance's hub.
+func (cfg *apiConfig) runUserStreamListener(ctx context.Context, dbURL string) {
+	if err := stream.Listen(ctx, dbURL, userStreamChannel, cfg.userStream); err != nil {
+		log.Printf("error listening for user events: %v\n", err)
+	}
+}
@@ -0,0 +1,28 @@
+-- name: CreateActorKey :one
+-- CreateActorKey stores a user's signing key, keeping the existing one if
+-- another request stored it first.
+INSERT INTO activitypub_keys(user_id, created_at, public_key_pem, private_key_pem)
+VALUES ($1, NOW(), $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET user_id = activitypub_keys.user_id
+RETURNING *;
+
+-- name: GetActorKey :one
+SELECT * FROM activitypub_keys
+WHERE user_id = $1;
+
+-- name: AddRemoteFollower :exec
+INSERT INTO activitypub_followers(user_id, actor_id, inbox, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, actor_id) DO UPDATE SET inbox = EXCLUDED.inbox;
+
+-- name: RemoveRemoteFollower :execrows
+DELETE FROM activitypub_followers
+WHERE user_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowerInboxes :many
+SELECT DISTINCT inbox FROM activitypub_followers
+WHERE user_id = $1;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM activitypub_followers
+WHERE user_id = $1;
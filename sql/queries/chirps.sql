@@ -1,20 +1,29 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, media_urls, reply_to_id)
 VALUES(
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 ) RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
+WHERE NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+)
 ORDER BY created_at ASC;
 
 -- name: GetChirpByID :one
 SELECT * FROM chirps
-WHERE id = $1;
+WHERE id = $1 AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+);
 
 -- name: DeleteChirpByID :exec
 DELETE FROM chirps
@@ -22,4 +31,50 @@ WHERE id = $1;
 
 -- name: GetChirpsByUserID :many
 SELECT * FROM chirps
-WHERE user_id = $1;
\ No newline at end of file
+WHERE user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+);
+
+-- name: GetNewestChirpsByUserID :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = chirps.user_id AND users.deletion_requested_at IS NOT NULL
+)
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $2, media_urls = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountChirpsByUserSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: GetChirpsByHashtag :many
+-- GetChirpsByHashtag returns the newest chirps containing #tag in any case.
+SELECT c.* FROM chirp_hashtags h
+JOIN chirps c ON c.id = h.chirp_id
+WHERE h.tag = LOWER(sqlc.arg(tag)::TEXT)
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = c.user_id AND users.deletion_requested_at IS NOT NULL
+  )
+ORDER BY h.chirp_created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: AddChirpHashtags :exec
+-- AddChirpHashtags indexes a chirp under each of the lowercased tags.
+INSERT INTO chirp_hashtags(chirp_id, tag, chirp_created_at)
+SELECT c.id, t.tag, c.created_at
+FROM chirps c, UNNEST(sqlc.arg(tags)::TEXT[]) AS t(tag)
+WHERE c.id = sqlc.arg(chirp_id)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,60 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports(id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    'pending'
+) RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1;
+
+-- name: GetActiveDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1 AND status IN ('pending', 'running')
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ClaimPendingDataExport :one
+-- ClaimPendingDataExport also reclaims jobs left running for 15 minutes,
+-- whose worker has most likely died.
+UPDATE data_exports
+SET status = 'running', updated_at = NOW()
+WHERE id = (
+    SELECT id FROM data_exports
+    WHERE status = 'pending'
+       OR (status = 'running' AND updated_at < NOW() - INTERVAL '15 minutes')
+    ORDER BY created_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDataExport :execrows
+UPDATE data_exports
+SET status = 'completed', file_path = $2, completed_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetExpiredDataExports :many
+SELECT * FROM data_exports
+WHERE status = 'completed' AND completed_at < $1;
+
+-- name: ExpireDataExport :exec
+UPDATE data_exports
+SET status = 'expired', file_path = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetDataExportFilesOfDeletedUsers :many
+-- GetDataExportFilesOfDeletedUsers lists the export archives of accounts
+-- that PurgeDeletedUsers would remove with the same cutoff.
+SELECT data_exports.file_path FROM data_exports
+JOIN users ON users.id = data_exports.user_id
+WHERE users.deletion_requested_at < $1 AND data_exports.file_path IS NOT NULL;
@@ -0,0 +1,160 @@
+-- name: CreateConversation :one
+INSERT INTO conversations(id, created_at, updated_at, created_by, is_group, last_message_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    NOW()
+)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants(conversation_id, user_id, joined_at, status)
+VALUES ($1, $2, NOW(), $3);
+
+-- name: GetDirectConversation :one
+-- GetDirectConversation finds the one-to-one conversation between two
+-- users, if both are still in it.
+SELECT c.* FROM conversations c
+WHERE NOT c.is_group
+  AND EXISTS (SELECT 1 FROM conversation_participants p WHERE p.conversation_id = c.id AND p.user_id = sqlc.arg(user_id))
+  AND EXISTS (SELECT 1 FROM conversation_participants p WHERE p.conversation_id = c.id AND p.user_id = sqlc.arg(other_id))
+ORDER BY c.created_at
+LIMIT 1;
+
+-- name: GetConversationParticipant :one
+SELECT * FROM conversation_participants
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationParticipants :many
+SELECT * FROM conversation_participants
+WHERE conversation_id = $1
+ORDER BY joined_at, user_id;
+
+-- name: GetConversationsForUser :many
+-- GetConversationsForUser lists the user's conversations with the given
+-- participant status, most recently active first.
+SELECT
+    c.id,
+    c.created_at,
+    c.is_group,
+    c.last_message_at,
+    p.status,
+    p.last_read_at,
+    (SELECT array_agg(o.user_id ORDER BY o.joined_at, o.user_id) FROM conversation_participants o WHERE o.conversation_id = c.id)::UUID[] AS participant_ids,
+    (
+        SELECT COUNT(*) FROM messages m
+        WHERE m.conversation_id = c.id
+          AND m.sender_id <> p.user_id
+          AND m.deleted_at IS NULL
+          AND (p.last_read_at IS NULL OR m.created_at > p.last_read_at)
+          AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = m.id AND d.user_id = p.user_id)
+    ) AS unread_count
+FROM conversations c
+JOIN conversation_participants p ON p.conversation_id = c.id
+WHERE p.user_id = $1 AND p.status = $2
+ORDER BY c.last_message_at DESC;
+
+-- name: GetConversationsByUserID :many
+-- GetConversationsByUserID lists every conversation the user takes part in,
+-- whatever their participant status.
+SELECT
+    c.id,
+    c.created_at,
+    c.is_group,
+    p.status,
+    (SELECT array_agg(o.user_id ORDER BY o.joined_at, o.user_id) FROM conversation_participants o WHERE o.conversation_id = c.id)::UUID[] AS participant_ids
+FROM conversations c
+JOIN conversation_participants p ON p.conversation_id = c.id
+WHERE p.user_id = $1
+ORDER BY c.created_at, c.id;
+
+-- name: AcceptConversation :execrows
+-- AcceptConversation accepts a message request, or takes back a decline.
+UPDATE conversation_participants
+SET status = 'accepted'
+WHERE conversation_id = $1 AND user_id = $2 AND status IN ('requested', 'declined');
+
+-- name: DeclineConversation :execrows
+-- DeclineConversation keeps the declined participant in the conversation
+-- so that the sender cannot simply ask again.
+UPDATE conversation_participants
+SET status = 'declined'
+WHERE conversation_id = $1 AND user_id = $2 AND status = 'requested';
+
+-- name: HasDeclinedDirectConversation :one
+-- HasDeclinedDirectConversation reports whether other_id declined a
+-- one-to-one message request from user_id, even if user_id has since left
+-- it.
+SELECT EXISTS (
+    SELECT 1 FROM conversations c
+    JOIN conversation_participants p ON p.conversation_id = c.id
+    WHERE NOT c.is_group
+      AND c.created_by = sqlc.arg(user_id)
+      AND p.user_id = sqlc.arg(other_id)
+      AND p.status = 'declined'
+);
+
+-- name: LeaveConversation :execrows
+DELETE FROM conversation_participants
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_participants
+SET last_read_at = sqlc.arg(read_at)
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id)
+  AND (last_read_at IS NULL OR last_read_at < sqlc.arg(read_at));
+
+-- name: CreateMessage :one
+INSERT INTO messages(id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET last_message_at = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE id = $1 AND conversation_id = $2;
+
+-- name: GetMessages :many
+-- GetMessages pages through a conversation newest first, as seen by
+-- user_id. before_id continues from the last message of the previous page.
+SELECT m.* FROM messages m
+WHERE m.conversation_id = sqlc.arg(conversation_id)
+  AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = m.id AND d.user_id = sqlc.arg(user_id))
+  AND (
+      sqlc.narg(before_id)::UUID IS NULL
+      OR (m.created_at, m.id) < (SELECT b.created_at, b.id FROM messages b WHERE b.id = sqlc.narg(before_id))
+  )
+ORDER BY m.created_at DESC, m.id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetMessagesByUserID :many
+-- GetMessagesByUserID returns the messages in the user's conversations,
+-- oldest first, leaving out those the user deleted for themselves.
+SELECT m.* FROM messages m
+JOIN conversation_participants p ON p.conversation_id = m.conversation_id
+WHERE p.user_id = sqlc.arg(user_id)
+  AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = m.id AND d.user_id = sqlc.arg(user_id))
+ORDER BY m.created_at, m.id;
+
+-- name: DeleteMessageForEveryone :execrows
+UPDATE messages
+SET body = '', deleted_at = NOW()
+WHERE id = $1 AND sender_id = $2 AND deleted_at IS NULL;
+
+-- name: DeleteMessageForUser :exec
+INSERT INTO message_deletions(message_id, user_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
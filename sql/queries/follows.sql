@@ -0,0 +1,59 @@
+-- name: FollowUser :execrows
+INSERT INTO follows(follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: IsFollowing :one
+SELECT EXISTS(
+    SELECT 1 FROM follows
+    WHERE follower_id = $1 AND followee_id = $2
+);
+
+-- name: GetVisibleFollowees :many
+-- GetVisibleFollowees narrows other_ids to the users that user_id follows
+-- and who have not blocked them.
+SELECT f.followee_id FROM follows f
+WHERE f.follower_id = sqlc.arg(user_id)
+  AND f.followee_id = ANY(sqlc.arg(other_ids)::UUID[])
+  AND NOT EXISTS (
+      SELECT 1 FROM user_blocks b
+      WHERE b.blocker_id = f.followee_id AND b.blocked_id = f.follower_id
+  )
+ORDER BY f.followee_id;
+
+-- name: BlockUser :exec
+INSERT INTO user_blocks(blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: HasBlockWithAny :one
+-- HasBlockWithAny reports whether the user has blocked, or been blocked by,
+-- any of the other users.
+SELECT EXISTS(
+    SELECT 1 FROM user_blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::UUID[]))
+       OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::UUID[]))
+);
+
+-- name: GetFollowsByFollowerID :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at;
+
+-- name: GetFollowsByFolloweeID :many
+SELECT * FROM follows
+WHERE followee_id = $1
+ORDER BY created_at;
+
+-- name: GetUserBlocksByBlockerID :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at;
@@ -0,0 +1,13 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes(user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetChirpLikesByUserID :many
+SELECT * FROM chirp_likes
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,18 @@
+-- name: GetLoginAttempts :one
+SELECT * FROM login_attempts
+WHERE key = $1;
+
+-- name: IncrementLoginAttempts :one
+INSERT INTO login_attempts(key, failures, last_failure_at)
+VALUES (sqlc.arg(key), 1, sqlc.arg(now))
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_attempts.last_failure_at < sqlc.arg(window_start) THEN 1
+        ELSE login_attempts.failures + 1
+    END,
+    last_failure_at = sqlc.arg(now)
+RETURNING *;
+
+-- name: ResetLoginAttempts :exec
+DELETE FROM login_attempts
+WHERE key = $1;
@@ -0,0 +1,93 @@
+-- name: CreateNotification :one
+-- CreateNotification returns sql.ErrNoRows if the event has already
+-- notified the user.
+INSERT INTO notifications(id, created_at, user_id, type, actor_id, chirp_id, event_id)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5)
+ON CONFLICT (event_id, user_id) DO NOTHING
+RETURNING *;
+
+-- name: GetNotifications :many
+-- GetNotifications pages through a user's notifications newest first.
+-- before_id continues from the last notification of the previous page.
+SELECT n.* FROM notifications n
+WHERE n.user_id = sqlc.arg(user_id)
+  AND (NOT sqlc.arg(unread_only)::BOOLEAN OR n.read_at IS NULL)
+  AND (
+      sqlc.narg(before_id)::UUID IS NULL
+      OR (n.created_at, n.id) < (SELECT b.created_at, b.id FROM notifications b WHERE b.id = sqlc.narg(before_id))
+  )
+ORDER BY n.created_at DESC, n.id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CountUnreadNotifications :many
+SELECT type, COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL
+GROUP BY type;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND id = ANY(sqlc.arg(ids)::UUID[]) AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :one
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: UpsertNotificationPreferences :one
+INSERT INTO notification_preferences(user_id, updated_at, muted_types, email_digest)
+VALUES ($1, NOW(), $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET muted_types = EXCLUDED.muted_types, email_digest = EXCLUDED.email_digest, updated_at = NOW()
+RETURNING *;
+
+-- name: ClaimDueDigests :many
+-- ClaimDueDigests records a digest as sent now to up to max_results users
+-- whose last one went out before due_before, returning when that was.
+-- Users with nothing unread since their last digest, or without a verified
+-- email, are skipped.
+WITH due AS (
+    SELECT np.user_id, np.last_digest_at
+    FROM notification_preferences np
+    JOIN users u ON u.id = np.user_id
+    WHERE np.email_digest
+      AND u.email_verified
+      AND u.deletion_requested_at IS NULL
+      AND (np.last_digest_at IS NULL OR np.last_digest_at < sqlc.arg(due_before))
+      AND EXISTS (
+          SELECT 1 FROM notifications n
+          WHERE n.user_id = np.user_id
+            AND n.read_at IS NULL
+            AND (np.last_digest_at IS NULL OR n.created_at > np.last_digest_at)
+      )
+    ORDER BY np.user_id
+    LIMIT sqlc.arg(max_results)
+    FOR UPDATE OF np SKIP LOCKED
+)
+UPDATE notification_preferences
+SET last_digest_at = NOW()
+FROM due, users
+WHERE notification_preferences.user_id = due.user_id AND users.id = due.user_id
+RETURNING notification_preferences.user_id, users.email, due.last_digest_at AS previous_digest_at;
+
+-- name: GetDigestNotifications :many
+-- GetDigestNotifications lists unread notifications created after since,
+-- newest first, with what an email needs to describe them.
+SELECT n.type, n.created_at, actor.username AS actor_username, actor.display_name AS actor_display_name, c.body AS chirp_body
+FROM notifications n
+JOIN users actor ON actor.id = n.actor_id
+LEFT JOIN chirps c ON c.id = n.chirp_id
+WHERE n.user_id = sqlc.arg(user_id)
+  AND n.read_at IS NULL
+  AND (sqlc.narg(since)::TIMESTAMP IS NULL OR n.created_at > sqlc.narg(since))
+ORDER BY n.created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: GetNotificationsByUserID :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at, id;
@@ -0,0 +1,96 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients(id, created_at, updated_at, name, secret_hash, redirect_uris, scope)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+) RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: GetOAuthClients :many
+SELECT * FROM oauth_clients
+ORDER BY created_at ASC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes(code_hash, created_at, expires_at, client_id, user_id, redirect_uri, scope, code_challenge)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: CreateOAuthToken :one
+INSERT INTO oauth_tokens(id, created_at, client_id, user_id, scope, expires_at, refresh_token_hash, refresh_expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+) RETURNING *;
+
+-- name: GetOAuthToken :one
+SELECT * FROM oauth_tokens
+WHERE id = $1;
+
+-- name: GetOAuthGrantsByUserID :many
+-- GetOAuthGrantsByUserID lists the tokens issued to OAuth clients for a user,
+-- without the refresh token hashes.
+SELECT
+    t.id,
+    t.created_at,
+    t.client_id,
+    c.name AS client_name,
+    t.scope,
+    t.expires_at,
+    t.refresh_expires_at,
+    t.revoked_at
+FROM oauth_tokens t
+JOIN oauth_clients c ON c.id = t.client_id
+WHERE t.user_id = $1
+ORDER BY t.created_at;
+
+-- name: GetOAuthTokenByRefreshHash :one
+SELECT * FROM oauth_tokens
+WHERE refresh_token_hash = $1;
+
+-- name: UseOAuthRefreshToken :one
+UPDATE oauth_tokens
+SET revoked_at = NOW()
+WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND refresh_expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeOAuthToken :exec
+UPDATE oauth_tokens
+SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserOAuthTokens :exec
+UPDATE oauth_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,18 @@
+-- name: CreateOIDCIdentity :one
+INSERT INTO oidc_identities(id, created_at, user_id, issuer, subject)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+) RETURNING *;
+
+-- name: GetOIDCIdentity :one
+SELECT * FROM oidc_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: GetOIDCIdentitiesByUserID :many
+SELECT * FROM oidc_identities
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,19 @@
+-- name: CreateOneTimeToken :exec
+INSERT INTO one_time_tokens(id, created_at, user_id, purpose, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4
+);
+
+-- name: UseOneTimeToken :execrows
+UPDATE one_time_tokens
+SET used_at = NOW()
+WHERE id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW();
+
+-- name: RevokeOneTimeTokens :exec
+UPDATE one_time_tokens
+SET used_at = NOW()
+WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
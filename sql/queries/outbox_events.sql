@@ -0,0 +1,38 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events(id, created_at, updated_at, event_type, user_id, payload, status, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    'pending',
+    NOW()
+);
+
+-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until), updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY created_at
+    LIMIT sqlc.arg(max_events)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteOutboxEvent :exec
+UPDATE outbox_events
+SET status = 'dispatched', dispatched_at = NOW(), last_error = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailOutboxEvent :exec
+UPDATE outbox_events
+SET status = $2, next_attempt_at = $3, last_error = $4, updated_at = NOW()
+WHERE id = $1;
+
+-- name: PurgeDispatchedOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE status = 'dispatched' AND dispatched_at < $1;
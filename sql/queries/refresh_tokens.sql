@@ -22,4 +22,14 @@ WHERE token = $1;
 -- name: UpdateRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at  = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUserID :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,61 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: GetSubscriptionForUpdate :one
+-- GetSubscriptionForUpdate locks the subscription until the end of the
+-- transaction, so that concurrent billing events are applied one at a time.
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: SaveSubscription :one
+WITH member AS (
+    UPDATE users
+    SET is_chirpy_red = (sqlc.arg(status)::TEXT <> 'expired'), updated_at = NOW()
+    WHERE id = sqlc.arg(user_id)
+    RETURNING id
+)
+INSERT INTO subscriptions(user_id, created_at, updated_at, plan, status, current_period_end, expires_at)
+SELECT member.id, NOW(), NOW(), sqlc.arg(plan), sqlc.arg(status), sqlc.arg(current_period_end), sqlc.arg(expires_at)
+FROM member
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    expires_at = EXCLUDED.expires_at,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: ExpireSubscriptions :many
+WITH lapsed AS (
+    UPDATE users
+    SET is_chirpy_red = FALSE, updated_at = NOW()
+    WHERE id IN (
+        SELECT user_id FROM subscriptions
+        WHERE status <> 'expired' AND expires_at <= $1
+    )
+)
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status <> 'expired' AND expires_at <= $1
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events(id, created_at, user_id, event, plan, status, current_period_end, expires_at, webhook_event_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: GetSubscriptionEvents :many
+SELECT * FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at ASC;
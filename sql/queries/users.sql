@@ -15,13 +15,86 @@ DELETE FROM users;
 SELECT * FROM users
 WHERE email = $1;
 
--- name: UpdatePasswordEmail :one 
+-- name: UpdatePasswordHash :exec
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
-WHERE id= $1
+SET hashed_password = $2
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserIDsByUsernames :many
+-- GetUserIDsByUsernames looks up users by lowercased username, skipping
+-- accounts awaiting deletion.
+SELECT id FROM users
+WHERE LOWER(username) = ANY(sqlc.arg(usernames)::TEXT[]) AND deletion_requested_at IS NULL;
+
+-- name: SetTOTPSecret :exec
+UPDATE users
+SET totp_secret = $2, totp_enabled = FALSE, totp_last_step = 0
+WHERE id = $1;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, totp_last_step = $2
+WHERE id = $1;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_step = $2
+WHERE id = $1 AND totp_last_step < $2;
+
+-- name: SetEmailVerified :exec
+UPDATE users
+SET email_verified = TRUE, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdatePassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetPendingEmail :exec
+UPDATE users
+SET pending_email = $2
+WHERE id = $1;
+
+-- name: ApplyPendingEmail :one
+UPDATE users
+SET email = pending_email, pending_email = NULL, email_verified = TRUE, updated_at = NOW()
+WHERE id = $1 AND pending_email IS NOT NULL
 RETURNING *;
 
--- name: UpgradeUserByID :exec
+-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = TRUE
-WHERE id = $1;
\ No newline at end of file
+SET display_name = $3, bio = $4, location = $5, website = $6, username = $7, updated_at = NOW()
+WHERE id = $1 AND updated_at = $2
+RETURNING *;
+
+-- name: RequestUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NOW()
+WHERE id = $1;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_requested_at = NULL
+WHERE id = $1;
+
+-- name: IsUserActive :one
+-- IsUserActive reports whether a user exists and has not asked for their
+-- account to be deleted.
+SELECT EXISTS(
+    SELECT 1 FROM users
+    WHERE id = $1 AND deletion_requested_at IS NULL
+);
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deletion_requested_at < $1;
@@ -0,0 +1,52 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events(id, created_at, updated_at, provider, event_id, event_type, payload, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    'received'
+)
+ON CONFLICT (provider, event_id) DO UPDATE SET updated_at = webhook_events.updated_at
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.arg(provider)::TEXT = '' OR provider = sqlc.arg(provider))
+  AND (sqlc.arg(status)::TEXT = '' OR status = sqlc.arg(status))
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+WHERE id = $1 AND (
+    status IN ('received', 'failed')
+    OR (status = 'processing' AND updated_at < NOW() - INTERVAL '5 minutes')
+)
+RETURNING *;
+
+-- name: ClaimWebhookEventForReplay :one
+-- ClaimWebhookEventForReplay claims an event that was received but not
+-- applied, or that failed. Processed and ignored events are never applied
+-- again.
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+WHERE id = $1 AND status IN ('received', 'failed')
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET status = $2,
+    error = $3,
+    processed_at = CASE WHEN $2 IN ('processed', 'ignored') THEN NOW() ELSE processed_at END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
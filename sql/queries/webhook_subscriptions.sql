@@ -0,0 +1,70 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions(id, created_at, updated_at, user_id, url, secret, event_types)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: GetWebhookSubscriptionsByOwner :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id IS NOT DISTINCT FROM $1
+ORDER BY created_at ASC;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Events about a user go to that user's subscriptions and to every admin
+-- subscription for the event type.
+INSERT INTO webhook_deliveries(id, created_at, updated_at, subscription_id, event_type, payload, status, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), id, sqlc.arg(event_type), sqlc.arg(payload), 'pending', NOW()
+FROM webhook_subscriptions
+WHERE sqlc.arg(event_type)::TEXT = ANY(event_types)
+  AND (user_id IS NULL OR user_id = sqlc.arg(user_id));
+
+-- name: ClaimWebhookDeliveries :many
+-- Claimed deliveries are leased rather than locked, so a delivery whose
+-- worker died is picked up again once the lease runs out.
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = sqlc.arg(lease_until), updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(max_deliveries)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: FinishWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() ELSE delivered_at END,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = $1 AND (sqlc.arg(status)::TEXT = '' OR status = sqlc.arg(status))
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND subscription_id = $2 AND status = 'dead'
+RETURNING *;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN display_name TEXT,
+ADD COLUMN bio TEXT,
+ADD COLUMN location TEXT,
+ADD COLUMN website TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN display_name,
+DROP COLUMN bio,
+DROP COLUMN location,
+DROP COLUMN website;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    processed_at TIMESTAMP,
+    UNIQUE(provider, event_id)
+);
+
+CREATE INDEX webhook_events_created_at_idx ON webhook_events(created_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
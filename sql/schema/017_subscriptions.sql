@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_expires_at_idx ON subscriptions(expires_at) WHERE status <> 'expired';
+
+CREATE TABLE subscription_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    webhook_event_id UUID REFERENCES webhook_events(id) ON DELETE SET NULL
+);
+
+CREATE INDEX subscription_events_user_id_idx ON subscription_events(user_id, created_at);
+
+-- Memberships from before subscriptions were tracked have no known billing
+-- period. They become 'legacy' subscriptions that never lapse on their own
+-- and last until Polka sends an event for them.
+INSERT INTO subscriptions(user_id, created_at, updated_at, plan, status, current_period_end, expires_at)
+SELECT id, NOW(), NOW(), 'red', 'legacy', '9999-12-31', '9999-12-31'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
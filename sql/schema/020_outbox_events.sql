@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE outbox_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    event_type TEXT NOT NULL,
+    -- No foreign key: events about a user are still dispatched after the
+    -- user is deleted.
+    user_id UUID,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events(next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE outbox_events;
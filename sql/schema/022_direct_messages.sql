@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE conversations(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    is_group BOOLEAN NOT NULL,
+    last_message_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE conversation_participants(
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP NOT NULL,
+    -- 'requested' until the user accepts a conversation started by someone
+    -- they don't follow.
+    status TEXT NOT NULL,
+    last_read_at TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_participants_user_id_idx ON conversation_participants(user_id);
+
+CREATE TABLE messages(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    -- Set when the sender deletes the message for everyone. The body is
+    -- cleared at the same time.
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX messages_conversation_id_idx ON messages(conversation_id, created_at, id);
+
+-- Messages a participant deleted for themselves only.
+CREATE TABLE message_deletions(
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (message_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE message_deletions;
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
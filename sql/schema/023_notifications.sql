@@ -0,0 +1,50 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN username TEXT;
+
+CREATE UNIQUE INDEX users_username_idx ON users(LOWER(username));
+
+ALTER TABLE chirps
+ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+CREATE TABLE chirp_likes(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX chirp_likes_chirp_id_idx ON chirp_likes(chirp_id);
+
+-- event_id is the outbox event that caused the notification, so that an
+-- event dispatched twice notifies each user once.
+CREATE TABLE notifications(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    read_at TIMESTAMP,
+    UNIQUE (event_id, user_id)
+);
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications(user_id, created_at DESC, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications(user_id) WHERE read_at IS NULL;
+
+CREATE TABLE notification_preferences(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    updated_at TIMESTAMP NOT NULL,
+    muted_types TEXT[] NOT NULL DEFAULT '{}',
+    email_digest BOOLEAN NOT NULL DEFAULT FALSE,
+    last_digest_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
+DROP TABLE chirp_likes;
+ALTER TABLE chirps DROP COLUMN reply_to_id;
+DROP INDEX users_username_idx;
+ALTER TABLE users DROP COLUMN username;
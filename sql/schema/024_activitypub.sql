@@ -0,0 +1,23 @@
+-- +goose Up
+-- The key each user's ActivityPub actor signs deliveries with, created the
+-- first time it is needed.
+CREATE TABLE activitypub_keys(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL
+);
+
+-- Actors on other servers following local users. inbox is where the
+-- user's activities are delivered for them, usually a shared inbox.
+CREATE TABLE activitypub_followers(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL,
+    inbox TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, actor_id)
+);
+
+-- +goose Down
+DROP TABLE activitypub_followers;
+DROP TABLE activitypub_keys;
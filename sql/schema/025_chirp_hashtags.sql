@@ -0,0 +1,27 @@
+-- +goose Up
+-- The lowercased hashtags of each chirp, so that hashtag feeds don't have
+-- to scan every chirp body. chirp_created_at is copied from the chirp to
+-- keep the newest chirps for a tag in index order.
+CREATE TABLE chirp_hashtags(
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    chirp_created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, tag)
+);
+
+CREATE INDEX chirp_hashtags_tag_idx ON chirp_hashtags(tag, chirp_created_at DESC);
+
+-- The patterns are bodyHashtagPattern and hashtagPattern in chirp.go.
+-- They spell characters out rather than use [:alnum:], whose meaning
+-- depends on the database locale.
+INSERT INTO chirp_hashtags(chirp_id, tag, chirp_created_at)
+SELECT DISTINCT c.id, LOWER(m[2]), c.created_at
+FROM chirps c, regexp_matches(
+    c.body,
+    '(^|[\x01-\x22\x24-\x2F\x3A-\x40\x5B-\x5E\x60\x7B-\x7F])#([^\x01-\x2F\x3A-\x40\x5B-\x5E\x60\x7B-\x7F]+)',
+    'g'
+) AS m
+WHERE m[2] ~ '^[A-Za-z0-9_]{1,50}$';
+
+-- +goose Down
+DROP TABLE chirp_hashtags;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Lets user feeds read a user's newest chirps without sorting all of them.
+CREATE INDEX chirps_user_id_created_at_idx ON chirps(user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
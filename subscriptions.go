@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mu7ammad1951/chirpy/internal/database"
+	"github.com/mu7ammad1951/chirpy/internal/subscription"
+)
+
+var errSubscriptionUserNotFound = errors.New("user not found")
+
+func toSubscription(sub database.Subscription) subscription.Subscription {
+	return subscription.Subscription{
+		Plan:             sub.Plan,
+		Status:           subscription.Status(sub.Status),
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		ExpiresAt:        sub.ExpiresAt,
+	}
+}
+
+// applySubscriptionEvent moves a user's subscription along its lifecycle,
+// keeps is_chirpy_red in step with it and records the change in the
+// user's subscription history. q must be bound to a transaction, which the
+// caller also uses to record that the webhook event was applied, so that
+// an event is never applied twice.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, event subscription.Event, webhookEventID uuid.NullUUID) (database.Subscription, error) {
+	var current *subscription.Subscription
+	stored, err := q.GetSubscriptionForUpdate(ctx, userID)
+	if err == nil {
+		sub := toSubscription(stored)
+		current = &sub
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, err
+	}
+
+	next, err := subscription.Apply(current, event, cfg.subscriptionGrace)
+	if err != nil {
+		return database.Subscription{}, err
+	}
+
+	saved, err := q.SaveSubscription(ctx, database.SaveSubscriptionParams{
+		UserID:           userID,
+		Plan:             next.Plan,
+		Status:           string(next.Status),
+		CurrentPeriodEnd: next.CurrentPeriodEnd,
+		ExpiresAt:        next.ExpiresAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, errSubscriptionUserNotFound
+	}
+	if err != nil {
+		return database.Subscription{}, err
+	}
+
+	if err := recordSubscriptionEvent(ctx, q, saved, event.Type, webhookEventID); err != nil {
+		return database.Subscription{}, err
+	}
+	wasActive := current != nil && current.Active(event.At)
+	if !wasActive && next.Active(event.At) {
+		if err := publishEvent(ctx, q, eventUserUpgraded, userID, userEventData{UserID: userID, Plan: saved.Plan}); err != nil {
+			return database.Subscription{}, err
+		}
+	}
+	return saved, nil
+}
+
+func recordSubscriptionEvent(ctx context.Context, q *database.Queries, sub database.Subscription, event string, webhookEventID uuid.NullUUID) error {
+	return q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		UserID:           sub.UserID,
+		Event:            event,
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		ExpiresAt:        sub.ExpiresAt,
+		WebhookEventID:   webhookEventID,
+	})
+}
+
+// runSubscriptionExpirer ends memberships that were neither renewed nor
+// paid for by the end of their grace period.
+func (cfg *apiConfig) runSubscriptionExpirer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var expired []database.Subscription
+		err := cfg.withTx(ctx, func(q *database.Queries) error {
+			var err error
+			expired, err = q.ExpireSubscriptions(ctx, time.Now().UTC())
+			if err != nil {
+				return err
+			}
+			for _, sub := range expired {
+				if err := recordSubscriptionEvent(ctx, q, sub, subscription.EventExpired, uuid.NullUUID{}); err != nil {
+					return err
+				}
+				if err := publishEvent(ctx, q, eventUserDowngraded, sub.UserID, userEventData{UserID: sub.UserID, Plan: sub.Plan}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("error expiring subscriptions: %v\n", err)
+		} else if len(expired) > 0 {
+			log.Printf("expired %d subscriptions\n", len(expired))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}